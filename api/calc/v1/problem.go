@@ -0,0 +1,10 @@
+package v1
+
+// ImportProblemResp 导入题目包响应
+type ImportProblemResp struct {
+	ProblemID   string `json:"problem_id"`
+	Version     string `json:"version"`
+	Name        string `json:"name"`
+	CheckPoints int    `json:"check_points"` // 测试点数量
+	Subtasks    int    `json:"subtasks"`     // 子任务数量
+}
@@ -1,20 +1,45 @@
 package v1
 
 type TaskReq struct {
-	CPULimit            int64        `json:"cpu_limit" binding:"required"`
-	MemLimit            int64        `json:"mem_limit" binding:"required"`
+	CPULimit            int64        `json:"cpu_limit"`
+	MemLimit            int64        `json:"mem_limit"`
 	StackLimit          int64        `json:"stack_limit"`
 	ProcLimit           int64        `json:"proc_limit"`
 	CodeFile            string       `json:"code_file" binding:"required"`
 	CodeLanguage        string       `json:"code_language" binding:"required"`
-	JudgeType           string       `json:"judge_type" binding:"required"`
+	JudgeType           string       `json:"judge_type"`
+	JudgeMode           string       `json:"judge_mode"`    // first_failure（遇错即停）/all（全部运行），为空时使用服务端默认配置
+	CompareMode         string       `json:"compare_mode"`  // strict（严格）/line（逐行）/token（按词，默认）/float（浮点误差），strict与line模式下仅格式不同判为PE
+	FloatEps            *float64     `json:"float_eps"`     // float模式的绝对误差，未指定时默认1e-6，为0时不按绝对误差比较
+	FloatRelEps         *float64     `json:"float_rel_eps"` // float模式的相对误差，未指定时默认1e-6，为0时不按相对误差比较
+	RevealDiff          string       `json:"reveal_diff"`   // 答案错误时公开第一处差异的范围：none（默认）/sample（仅样例）/all
 	SpecialCodeFile     string       `json:"special_code_file" `
 	SpecialCodeFileName string       `json:"special_code_file_name" `
-	Bucket              string       `json:"bucket" binding:"required"`
-	CheckPoints         []checkPoint `json:"check_points" binding:"required"`
+	Bucket              string       `json:"bucket"`
+	CheckPoints         []checkPoint `json:"check_points"`
+	Subtasks            []subtask    `json:"subtasks"`
+	CallbackURL         string       `json:"callback_url"`    // 异步评测完成后POST最终结果的地址（可选）
+	ProblemID           string       `json:"problem_id"`      // 题目包ID，指定时评测配置、测试点与子任务均取自题目包，忽略请求中的对应字段
+	ProblemVersion      string       `json:"problem_version"` // 题目包版本，为空时使用当前版本
 }
 
 type checkPoint struct {
 	InputFile  string `json:"input"`
 	OutputFile string `json:"output"`
+	Score      int    `json:"score"`  // 测试点分值，全部为0时平均分配100分
+	Sample     bool   `json:"sample"` // 是否为样例测试点（reveal_diff为sample时公开其差异）
+}
+
+type subtask struct {
+	Name         string   `json:"name"`
+	Score        int      `json:"score"`
+	Policy       string   `json:"policy"`       // min（默认）/sum/min_ratio
+	CheckPoints  []int    `json:"check_points"` // 测试点在check_points中的下标
+	Dependencies []string `json:"dependencies"` // 依赖的子任务名称（须在本子任务之前声明）
+}
+
+// SubmitTaskResp 异步提交评测任务响应
+type SubmitTaskResp struct {
+	TaskID int64  `json:"task_id"`
+	Status string `json:"status"` // 提交成功时为PENDING
 }
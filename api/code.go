@@ -10,6 +10,7 @@ const (
 	CodeUserExist       ResCode = 4010
 	CodeUserNotExist    ResCode = 4011
 	CodeInvalidPassword ResCode = 4020
+	CodeTaskNotExist    ResCode = 4030
 
 	CodeNeedLogin    ResCode = 4100
 	CodeInvalidToken ResCode = 4200
@@ -25,6 +26,7 @@ var codeMsgMap = map[ResCode]string{
 	CodeUserExist:       "用户名已存在",
 	CodeUserNotExist:    "用户名不存在",
 	CodeInvalidPassword: "用户名或密码错误",
+	CodeTaskNotExist:    "评测任务不存在",
 	CodeServerBusy:      "服务繁忙",
 
 	CodeNeedLogin:    "需要登录",
@@ -8,7 +8,12 @@ import (
 	"time"
 
 	"hitwh-judge/internal/conf"
+	"hitwh-judge/internal/event"
+	"hitwh-judge/internal/queue"
 	"hitwh-judge/internal/server"
+	"hitwh-judge/internal/service"
+	"hitwh-judge/internal/store"
+	"hitwh-judge/internal/testdata"
 	"hitwh-judge/pkg/jwt"
 	"hitwh-judge/pkg/logging"
 	"hitwh-judge/pkg/snowflake"
@@ -121,14 +126,35 @@ func main() {
 
 	//dao.MustInitMySQL(cfg)  // 初始化 MySQL 连接
 	//dao.MustInitRedis(cfg)  // 初始化 Redis
+	if cfg.GetString("queue.type") == queue.TypeRedis ||
+		cfg.GetString("result_store.type") == store.TypeRedis ||
+		cfg.GetString("events.type") == event.TypeRedisBroker ||
+		cfg.GetString("webhook.dead_letter_store") == "redis" {
+		dao.MustInitRedis(cfg) // 使用Redis队列、结果存储、事件分发或死信存储时初始化 Redis
+	}
 	dao.MustInitPostgres(cfg) // 初始化 Postgres 连接
-	dao.MustInitMinIO(cfg)    // 初始化 MinIO 连接
-	jwt.MustInit(cfg)         // 初始化 jwt
-	snowflake.MustInit(cfg)   // 初始化 snowflake
+	useMinIO := cfg.GetString("testdata.type") == "" || cfg.GetString("testdata.type") == testdata.TypeMinIO
+	if useMinIO {
+		dao.MustInitMinIO(cfg) // 测试数据存储在MinIO时初始化 MinIO 连接
+	}
+	jwt.MustInit(cfg)                                                       // 初始化 jwt
+	snowflake.MustInit(cfg)                                                 // 初始化 snowflake
+	service.MustInitJudgeConfig(cfg)                                        // 初始化评测配置
+	service.StartSandboxPool()                                              // 清理遗留沙箱并预热沙箱池
+	service.MustInitTestFileCache(cfg)                                      // 初始化测试用例缓存
+	service.MustInitTestDataStore(cfg)                                      // 初始化测试数据存储
+	service.MustInitProblemStore(cfg)                                       // 初始化题目包存储
+	service.MustInitResultStore(cfg)                                        // 初始化评测结果存储
+	service.MustInitQueue(cfg)                                              // 初始化评测队列
+	service.MustInitEventBroker(cfg)                                        // 初始化评测进度事件分发
+	service.MustInitNotifier(cfg)                                           // 初始化评测结果回调
+	service.StartWorkers(context.Background(), cfg.GetInt("queue.workers")) // 启动评测工作协程
 
 	// 查询PostgreSQL所有表
 	listPostgresTables(cfg, logger)
-	listMinIOBuckets(logger)
+	if useMinIO {
+		listMinIOBuckets(logger)
+	}
 
 	// 初始化路由
 	r := server.SetupRoutes(cfg)
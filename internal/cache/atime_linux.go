@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime 返回文件的最后访问时间
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package cache
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime 返回文件的最后访问时间，非Linux平台使用修改时间代替
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -1,16 +1,24 @@
 package cache
 
 import (
+	"context"
 	md5Package "crypto/md5"
+	"errors"
 	"fmt"
-	"hitwh-judge/internal/dao/minio"
+	"hitwh-judge/internal/conf"
+	"hitwh-judge/internal/testdata"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 // EnhancedTestFileCache 增强版测试用例文件缓存
@@ -23,14 +31,34 @@ type EnhancedTestFileCache struct {
 	maxDiskUsage int64  // 最大磁盘使用量（字节）
 	currentUsage int64  // 当前磁盘使用量
 	emptyFile    string // 空文件
+
+	highWatermark float64 // 使用量超过 maxDiskUsage 的该比例时开始淘汰，为0时等同于1
+	lowWatermark  float64 // 淘汰到不超过 maxDiskUsage 的该比例为止，为0时只淘汰到能放下新文件
+	evictions     int64   // 因空间不足被淘汰的文件数
+
+	downloader *testdata.Downloader // 缓存未命中时从测试数据存储下载
+	inflight   singleflight.Group   // 合并同一文件的并发下载
+	downloads  int64                // 实际下载次数
+	coalesced  int64                // 与其他并发调用共享同一次下载结果的调用次数
 }
 
+// md5Pattern 测试数据文件名（MD5）的合法格式
+var md5Pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
 type cachedFile struct {
 	filePath   string    // 缓存文件的路径
-	expireTime time.Time // 过期时间
+	expireTime time.Time // 过期时间（最后访问时间加TTL）
 	size       int64     // 文件大小
 	accessTime time.Time // 最后访问时间
 	MD5Hash    string    // 文件的MD5哈希值
+	bucket     string    // 所属存储桶
+	refs       int       // 正在使用该文件的评测数，大于0时不会被淘汰或过期删除
+	generation int       // 条目被原地替换的次数，用于判断锁外校验期间文件是否被替换
+}
+
+// expired 判断缓存文件是否已过期，被固定的文件不会过期
+func (f *cachedFile) expired(now time.Time) bool {
+	return f.refs == 0 && now.After(f.expireTime)
 }
 
 var (
@@ -42,34 +70,208 @@ func GetEmptyFile() string {
 	return enhancedInstance.emptyFile
 }
 
+// emptyFileName 缓存目录下空文件的文件名
+const emptyFileName = "empty"
+
 // GetEnhancedTestFileCache 获取增强版单例缓存实例
+// 未调用 InitEnhancedTestFileCache 时使用默认缓存配置
 func GetEnhancedTestFileCache() *EnhancedTestFileCache {
 	enhancedOnce.Do(func() {
-		// 创建本地缓存目录
-		// cacheDir := "~/tmp/judge-cache-enhanced"
-		// if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		// 如果临时目录不可用，回退到系统临时目录
-		cacheDir := filepath.Join(os.TempDir(), "judge-cache-enhanced")
-		os.MkdirAll(cacheDir, 0755)
-		// }
-
-		// 创建空文件
-		emptyFile := filepath.Join(cacheDir, "empty")
-		os.WriteFile(emptyFile, []byte{}, 0644)
-
-		enhancedInstance = &EnhancedTestFileCache{
-			cache:        make(map[string]*cachedFile),
-			ttl:          30 * time.Minute, // 默认缓存30分钟
-			cleanFreq:    10 * time.Minute, // 每10分钟清理一次过期数据
-			cacheDir:     cacheDir,
-			maxDiskUsage: 1024 * 1024 * 1024, // 默认最大1GB
-			emptyFile:    emptyFile,
+		var err error
+		enhancedInstance, err = newEnhancedTestFileCache(conf.GetDefaultCacheConfig())
+		if err != nil {
+			zap.L().Error("初始化测试用例缓存失败", zap.Error(err))
 		}
 		go enhancedInstance.startCleaner()
 	})
 	return enhancedInstance
 }
 
+// InitEnhancedTestFileCache 按配置初始化单例缓存，并从缓存目录恢复重启前的缓存索引
+// 须在首次调用 GetEnhancedTestFileCache 之前调用
+func InitEnhancedTestFileCache(config *conf.CacheConfig) error {
+	err := errors.New("测试用例缓存已初始化")
+	enhancedOnce.Do(func() {
+		enhancedInstance, err = newEnhancedTestFileCache(config)
+		go enhancedInstance.startCleaner()
+	})
+	return err
+}
+
+// newEnhancedTestFileCache 创建缓存实例并恢复缓存索引
+// 缓存目录不可用时返回错误，此时实例仍可使用（每次未命中都会重新下载）
+func newEnhancedTestFileCache(config *conf.CacheConfig) (*EnhancedTestFileCache, error) {
+	c := &EnhancedTestFileCache{
+		cache:         make(map[string]*cachedFile),
+		ttl:           config.TestCaseTTL,
+		cleanFreq:     config.CleanFrequency,
+		cacheDir:      config.Dir,
+		maxDiskUsage:  config.MaxDiskUsage,
+		emptyFile:     filepath.Join(config.Dir, emptyFileName),
+		highWatermark: config.HighWatermark,
+		lowWatermark:  config.LowWatermark,
+	}
+	if c.highWatermark > 1 || c.lowWatermark < 0 || c.lowWatermark > c.highWatermark {
+		return c, fmt.Errorf("缓存水位无效: high=%v low=%v，须满足 0 <= low <= high <= 1", c.highWatermark, c.lowWatermark)
+	}
+	if err := os.MkdirAll(c.cacheDir, 0755); err != nil {
+		return c, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	// 创建空文件
+	if err := os.WriteFile(c.emptyFile, []byte{}, 0644); err != nil {
+		return c, fmt.Errorf("创建空文件失败: %w", err)
+	}
+	if err := c.restore(); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// restore 扫描缓存目录重建缓存索引
+// 文件名须为 bucket_md5 且内容与MD5一致，大小与最后访问时间取自文件本身，过期时间按最后访问时间计算；
+// 损坏、过期的缓存文件与下载中断残留的临时文件会被删除，恢复后超出容量时按最久未使用淘汰
+func (c *EnhancedTestFileCache) restore() error {
+	entries, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		return fmt.Errorf("读取缓存目录失败: %w", err)
+	}
+
+	now := time.Now()
+	restored, removed := 0, 0
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		filePath := filepath.Join(c.cacheDir, name)
+		if strings.HasPrefix(name, ".download-") {
+			os.Remove(filePath)
+			removed++
+			continue
+		}
+		bucket, md5Hash, ok := parseCacheFileName(name)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		lastAccess := accessTime(info)
+		expireTime := lastAccess.Add(c.ttl)
+		if now.After(expireTime) || !c.verifyFileIntegrity(filePath, md5Hash) {
+			os.Remove(filePath)
+			removed++
+			continue
+		}
+		// 校验读取文件可能更新访问时间，恢复为扫描前的值
+		os.Chtimes(filePath, lastAccess, time.Time{})
+		c.cache[c.generateKey(bucket, md5Hash)] = &cachedFile{
+			filePath:   filePath,
+			expireTime: expireTime,
+			size:       info.Size(),
+			accessTime: lastAccess,
+			MD5Hash:    md5Hash,
+			bucket:     bucket,
+		}
+		c.currentUsage += info.Size()
+		restored++
+	}
+	c.evict(0, c.maxDiskUsage, c.maxDiskUsage)
+
+	zap.L().Info("恢复测试用例缓存",
+		zap.String("cache_dir", c.cacheDir),
+		zap.Int("restored", restored),
+		zap.Int("removed", removed),
+		zap.Int("cached", len(c.cache)),
+		zap.Int64("usage", c.currentUsage),
+	)
+	return nil
+}
+
+// parseCacheFileName 解析 bucket_md5 格式的缓存文件名
+func parseCacheFileName(name string) (bucket, md5Hash string, ok bool) {
+	i := strings.LastIndex(name, "_")
+	if i <= 0 || !md5Pattern.MatchString(name[i+1:]) {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// SetDownloader 设置测试数据下载器
+func (c *EnhancedTestFileCache) SetDownloader(downloader *testdata.Downloader) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.downloader = downloader
+}
+
+// download 从测试数据存储下载文件到缓存目录（流式写入并校验MD5），返回缓存文件路径
+func (c *EnhancedTestFileCache) download(bucket, md5 string) (string, error) {
+	c.mutex.RLock()
+	downloader := c.downloader
+	c.mutex.RUnlock()
+	if downloader == nil {
+		return "", errors.New("测试数据存储未初始化")
+	}
+	if !md5Pattern.MatchString(md5) {
+		return "", fmt.Errorf("测试数据MD5无效: %q", md5)
+	}
+	if strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("存储桶名称无效: %q", bucket)
+	}
+
+	cacheFilePath := filepath.Join(c.cacheDir, fmt.Sprintf("%s_%s", bucket, md5))
+	start := time.Now()
+	size, err := downloader.Download(context.Background(), bucket, md5, cacheFilePath)
+	if err != nil {
+		return "", err
+	}
+	zap.L().Info("下载测试数据",
+		zap.String("bucket", bucket),
+		zap.String("md5", md5),
+		zap.Int64("size", size),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+
+	if err := c.checkAndFreeSpace(size); err != nil {
+		os.Remove(cacheFilePath)
+		return "", err
+	}
+	c.add(bucket, md5, cacheFilePath, size)
+	return cacheFilePath, nil
+}
+
+// add 登记缓存文件，替换同一键的旧条目
+func (c *EnhancedTestFileCache) add(bucket, md5Hash, filePath string, size int64) {
+	cached := &cachedFile{
+		filePath:   filePath,
+		expireTime: time.Now().Add(c.ttl),
+		size:       size,
+		accessTime: time.Now(),
+		MD5Hash:    md5Hash,
+		bucket:     bucket,
+	}
+
+	key := c.generateKey(bucket, md5Hash)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// 如果已有缓存，先删除旧文件（路径相同时文件已被新文件替换）
+	// 原条目可能正被评测固定，原地更新以保留其引用计数
+	if oldCached, exists := c.cache[key]; exists {
+		if oldCached.filePath != filePath {
+			os.Remove(oldCached.filePath)
+		}
+		c.currentUsage -= oldCached.size
+		cached.refs = oldCached.refs
+		cached.generation = oldCached.generation + 1
+		*oldCached = *cached
+		cached = oldCached
+	}
+
+	c.cache[key] = cached
+	c.currentUsage += cached.size
+}
+
 // SetMaxDiskUsage 设置最大磁盘使用量
 func (c *EnhancedTestFileCache) SetMaxDiskUsage(maxBytes int64) {
 	c.mutex.Lock()
@@ -79,13 +281,17 @@ func (c *EnhancedTestFileCache) SetMaxDiskUsage(maxBytes int64) {
 
 // calculateMD5 计算文件的MD5哈希值
 func (c *EnhancedTestFileCache) calculateMD5(filePath string) (string, error) {
-	content, err := os.ReadFile(filePath)
+	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
+	defer file.Close()
 
-	hash := md5Package.Sum(content)
-	return fmt.Sprintf("%x", hash), nil
+	hash := md5Package.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
 // verifyFileIntegrity 验证文件完整性
@@ -99,54 +305,100 @@ func (c *EnhancedTestFileCache) verifyFileIntegrity(filePath, expectedMD5 string
 
 // GetFilePath 获取缓存文件路径
 func (c *EnhancedTestFileCache) GetFilePath(bucket, md5 string) (string, bool) {
-	key := c.generateKey(bucket, md5)
+	cached, exists := c.lookup(bucket, md5, false)
+	if !exists {
+		return "", false
+	}
+	return cached.filePath, true
+}
 
-	// 快速路径：先尝试读取，避免不必要的写锁
+// lookup 查找有效的缓存文件并更新其访问时间，pin为true时同时固定该文件；过期、丢失或损坏的文件从缓存中移除
+// MD5校验在锁外进行，避免并发的评测互相等待；校验期间条目被替换或删除时视为未命中
+func (c *EnhancedTestFileCache) lookup(bucket, md5 string, pin bool) (*cachedFile, bool) {
+	key := c.generateKey(bucket, md5)
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
 	cached, exists := c.cache[key]
-
 	if !exists {
-		return "", false
+		c.mutex.Unlock()
+		return nil, false
 	}
 
 	// 检查是否过期
-	if time.Now().After(cached.expireTime) {
-		if finalCached, exists := c.cache[key]; exists && time.Now().After(finalCached.expireTime) {
-			os.Remove(finalCached.filePath)
-			c.currentUsage -= finalCached.size
-			delete(c.cache, key)
-		}
-		return "", false
+	if cached.expired(time.Now()) {
+		os.Remove(cached.filePath)
+		c.currentUsage -= cached.size
+		delete(c.cache, key)
+		c.mutex.Unlock()
+		return nil, false
 	}
 
 	// 检查文件是否仍然存在
 	if _, err := os.Stat(cached.filePath); os.IsNotExist(err) {
-		if existing, exists := c.cache[key]; exists {
-			if _, statErr := os.Stat(existing.filePath); os.IsNotExist(statErr) {
-				c.currentUsage -= existing.size
-				delete(c.cache, key)
-			}
-		}
-		return "", false
+		c.currentUsage -= cached.size
+		delete(c.cache, key)
+		c.mutex.Unlock()
+		return nil, false
 	}
+	filePath, md5Hash, generation := cached.filePath, cached.MD5Hash, cached.generation
+	c.mutex.Unlock()
 
 	// 验证文件完整性
-	if !c.verifyFileIntegrity(cached.filePath, cached.MD5Hash) {
+	intact := c.verifyFileIntegrity(filePath, md5Hash)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cache[key] != cached || cached.generation != generation {
+		return nil, false
+	}
+	if !intact {
 		// 文件损坏，清理缓存
-		if existing, exists := c.cache[key]; exists && existing.filePath == cached.filePath {
-			os.Remove(existing.filePath)
-			c.currentUsage -= existing.size
-			delete(c.cache, key)
-		}
-		return "", false
+		os.Remove(cached.filePath)
+		c.currentUsage -= cached.size
+		delete(c.cache, key)
+		return nil, false
 	}
 
-	if existing, exists := c.cache[key]; exists {
-		existing.accessTime = time.Now()
+	c.touch(cached, time.Now())
+	if pin {
+		cached.refs++
 	}
+	return cached, true
+}
 
-	return cached.filePath, true
+// touch 更新缓存文件的访问时间并顺延过期时间，调用方须持有写锁
+func (c *EnhancedTestFileCache) touch(cached *cachedFile, now time.Time) {
+	cached.accessTime = now
+	cached.expireTime = now.Add(c.ttl)
+	// 访问时间同时记录在文件上，重启后据此恢复淘汰顺序（修改时间保持为写入时间）
+	os.Chtimes(cached.filePath, now, time.Time{})
+}
+
+// Acquire 获取测试数据文件（缓存未命中时下载）并固定在缓存中
+// 调用release之前该文件不会被淘汰或过期删除；bucket或md5为空时返回空文件
+func (c *EnhancedTestFileCache) Acquire(bucket, md5 string) (filePath string, release func(), err error) {
+	if bucket == "" || md5 == "" {
+		return c.emptyFile, func() {}, nil
+	}
+	// 下载完成到固定之间文件可能已被淘汰，此时重新下载一次
+	for attempt := 0; attempt < 2; attempt++ {
+		if cached, found := c.lookup(bucket, md5, true); found {
+			var once sync.Once
+			return cached.filePath, func() { once.Do(func() { c.release(cached) }) }, nil
+		}
+		if _, err := c.fetch(bucket, md5); err != nil {
+			return "", nil, err
+		}
+	}
+	return "", nil, fmt.Errorf("测试数据%s下载后即被淘汰，缓存空间不足", md5)
+}
+
+// release 释放对缓存文件的固定
+func (c *EnhancedTestFileCache) release(cached *cachedFile) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cached.refs--
+	cached.accessTime = time.Now()
+	cached.expireTime = cached.accessTime.Add(c.ttl)
 }
 
 // GetFileContent 获取缓存文件内容
@@ -177,29 +429,8 @@ func (c *EnhancedTestFileCache) checkAndFreeSpace(newFileSize int64) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// 检查是否会超过最大使用量
-	if c.currentUsage+newFileSize > c.maxDiskUsage {
-		// 按访问时间排序，删除最久未使用的文件
-		files := make([]*cachedFile, 0, len(c.cache))
-		for _, file := range c.cache {
-			files = append(files, file)
-		}
-
-		sort.Slice(files, func(i, j int) bool {
-			return files[i].accessTime.Before(files[j].accessTime)
-		})
-
-		// 删除最久未使用的文件直到有足够空间
-		for _, file := range files {
-			if c.currentUsage+newFileSize <= c.maxDiskUsage {
-				break
-			}
-
-			os.Remove(file.filePath)
-			c.currentUsage -= file.size
-			delete(c.cache, c.generateKeyFromFilePath(file.filePath))
-		}
-	}
+	high, low := c.watermarks()
+	c.evict(newFileSize, high, low)
 
 	// 检查是否仍有足够空间
 	if c.currentUsage+newFileSize > c.maxDiskUsage {
@@ -209,22 +440,57 @@ func (c *EnhancedTestFileCache) checkAndFreeSpace(newFileSize int64) error {
 	return nil
 }
 
-// generateKeyFromFilePath 从文件路径生成缓存键
-func (c *EnhancedTestFileCache) generateKeyFromFilePath(filePath string) string {
-	// 解析文件路径获取bucket和md5信息
-	// 这里假设文件名为 bucket_md5 的格式
-	baseName := filepath.Base(filePath)
-	parts := filepath.SplitList(baseName)
-	if len(parts) >= 2 {
-		return fmt.Sprintf("%s:%s", parts[0], parts[1])
+// watermarks 返回开始淘汰与淘汰目标的使用量（字节）
+func (c *EnhancedTestFileCache) watermarks() (high, low int64) {
+	high, low = c.maxDiskUsage, 0
+	if c.highWatermark > 0 {
+		high = int64(float64(c.maxDiskUsage) * c.highWatermark)
+	}
+	if c.lowWatermark > 0 {
+		low = int64(float64(c.maxDiskUsage) * c.lowWatermark)
+	}
+	return high, low
+}
+
+// evict 加入newFileSize字节后使用量超过high时，按访问时间删除最久未使用的文件，
+// 直到使用量不超过low且能放下新文件（low为0时只淘汰到能放下新文件）；被固定的文件不会被删除。调用方须持有写锁
+func (c *EnhancedTestFileCache) evict(newFileSize, high, low int64) {
+	// 检查是否会超过最大使用量
+	if c.currentUsage+newFileSize <= high {
+		return
+	}
+	target := c.maxDiskUsage - newFileSize
+	if low > 0 {
+		target = min(low, target)
+	}
+
+	// 按访问时间排序，删除最久未使用的文件
+	files := make([]*cachedFile, 0, len(c.cache))
+	for _, file := range c.cache {
+		if file.refs == 0 {
+			files = append(files, file)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].accessTime.Before(files[j].accessTime)
+	})
+
+	// 删除最久未使用的文件直到降到目标使用量
+	for _, file := range files {
+		if c.currentUsage <= target {
+			break
+		}
+
+		os.Remove(file.filePath)
+		c.currentUsage -= file.size
+		delete(c.cache, c.generateKey(file.bucket, file.MD5Hash))
+		c.evictions++
 	}
-	return ""
 }
 
 // Set 添加文件到缓存
 func (c *EnhancedTestFileCache) Set(bucket, md5Hash, content string) error {
-	key := c.generateKey(bucket, md5Hash)
-
 	// 计算新文件的MD5
 	newMD5 := fmt.Sprintf("%x", md5Package.Sum([]byte(content)))
 	if newMD5 != md5Hash {
@@ -252,26 +518,7 @@ func (c *EnhancedTestFileCache) Set(bucket, md5Hash, content string) error {
 		return err
 	}
 
-	cached := &cachedFile{
-		filePath:   cacheFilePath,
-		expireTime: time.Now().Add(c.ttl),
-		size:       fileInfo.Size(),
-		accessTime: time.Now(),
-		MD5Hash:    md5Hash,
-	}
-
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	// 如果已有缓存，先删除旧文件
-	if oldCached, exists := c.cache[key]; exists {
-		os.Remove(oldCached.filePath)
-		c.currentUsage -= oldCached.size
-	}
-
-	c.cache[key] = cached
-	c.currentUsage += cached.size
-
+	c.add(bucket, md5Hash, cacheFilePath, fileInfo.Size())
 	return nil
 }
 
@@ -298,7 +545,7 @@ func (c *EnhancedTestFileCache) cleanExpired() {
 	defer c.mutex.Unlock()
 
 	for key, cached := range c.cache {
-		if now.After(cached.expireTime) {
+		if cached.expired(now) {
 			// 删除过期的缓存文件
 			os.Remove(cached.filePath)
 			c.currentUsage -= cached.size
@@ -314,7 +561,7 @@ func (c *EnhancedTestFileCache) removeCacheEntry(bucket, md5 string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if cached, exists := c.cache[key]; exists {
+	if cached, exists := c.cache[key]; exists && cached.refs == 0 {
 		os.Remove(cached.filePath)
 		c.currentUsage -= cached.size
 		delete(c.cache, key)
@@ -335,23 +582,27 @@ func (c *EnhancedTestFileCache) DownloadFileByMD5WithCache(bucket, md5 string) (
 	if found {
 		return cachedFilePath, nil
 	}
-	// 缓存未命中，从MinIO下载
-	data, err := minio.DownloadFileByMD5AsString(bucket, md5)
-	if err != nil {
-		return "", err
+	// 缓存未命中，从测试数据存储下载
+	return c.fetch(bucket, md5)
+}
+
+// fetch 下载缓存未命中的文件，同一文件的并发请求只下载一次，其余调用等待其结果
+func (c *EnhancedTestFileCache) fetch(bucket, md5 string) (string, error) {
+	v, err, shared := c.inflight.Do(c.generateKey(bucket, md5), func() (interface{}, error) {
+		// 上一轮合并的下载可能在本次检查缓存之后刚刚完成
+		if filePath, found := c.GetFilePath(bucket, md5); found {
+			return filePath, nil
+		}
+		atomic.AddInt64(&c.downloads, 1)
+		return c.download(bucket, md5)
+	})
+	if shared {
+		atomic.AddInt64(&c.coalesced, 1)
 	}
-	// md5 = fmt.Sprintf("%x", md5Package.Sum([]byte(data)))
-	// 将下载的数据存入缓存（以文件形式）
-	if err := c.Set(bucket, md5, data); err != nil {
+	if err != nil {
 		return "", err
 	}
-
-	// 返回新缓存的文件路径
-	filePath, ok := c.GetFilePath(bucket, md5)
-	if !ok {
-		return "", fmt.Errorf("file path not found in cache")
-	}
-	return filePath, nil
+	return v.(string), nil
 }
 
 // DownloadFileByMD5WithCacheContent 使用缓存下载文件（返回内容）
@@ -364,33 +615,32 @@ func (c *EnhancedTestFileCache) DownloadFileByMD5WithCacheContent(bucket, md5 st
 		return cachedContent, nil
 	}
 
-	// 缓存未命中，从MinIO下载
-	data, err := minio.DownloadFileByMD5AsString(bucket, md5)
+	// 缓存未命中，从测试数据存储下载
+	filePath, err := c.fetch(bucket, md5)
 	if err != nil {
 		return "", err
 	}
-	// md5 = fmt.Sprintf("%x", md5Package.Sum([]byte(data)))
-
-	// 将下载的数据存入缓存
-	if err := c.Set(bucket, md5, data); err != nil {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
 		return "", err
 	}
-
-	return data, nil
+	return string(data), nil
 }
 
-// Clear 清空所有缓存（可用于调试或特殊场景）
+// Clear 清空所有缓存（可用于调试或特殊场景），正在使用的文件保留
 func (c *EnhancedTestFileCache) Clear() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// 删除所有缓存文件
-	for _, cached := range c.cache {
+	// 删除所有未被固定的缓存文件
+	for key, cached := range c.cache {
+		if cached.refs > 0 {
+			continue
+		}
 		os.Remove(cached.filePath)
+		c.currentUsage -= cached.size
+		delete(c.cache, key)
 	}
-
-	c.cache = make(map[string]*cachedFile)
-	c.currentUsage = 0
 }
 
 // GetCacheStats 获取缓存统计信息
@@ -398,13 +648,30 @@ func (c *EnhancedTestFileCache) GetCacheStats() map[string]interface{} {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
+	bucketUsage := make(map[string]int64)
+	pinned := 0
+	for _, cached := range c.cache {
+		bucketUsage[cached.bucket] += cached.size
+		if cached.refs > 0 {
+			pinned++
+		}
+	}
+	high, low := c.watermarks()
+
 	return map[string]interface{}{
-		"cache_size":    len(c.cache),
-		"current_usage": c.currentUsage,
-		"max_usage":     c.maxDiskUsage,
-		"cache_dir":     c.cacheDir,
-		"ttl":           c.ttl,
-		"clean_freq":    c.cleanFreq,
-		"usage_percent": float64(c.currentUsage) / float64(c.maxDiskUsage) * 100,
+		"cache_size":     len(c.cache),
+		"current_usage":  c.currentUsage,
+		"max_usage":      c.maxDiskUsage,
+		"high_watermark": high,
+		"low_watermark":  low,
+		"bucket_usage":   bucketUsage,
+		"pinned_files":   pinned,
+		"evictions":      c.evictions,
+		"cache_dir":      c.cacheDir,
+		"ttl":            c.ttl,
+		"clean_freq":     c.cleanFreq,
+		"usage_percent":  float64(c.currentUsage) / float64(c.maxDiskUsage) * 100,
+		"downloads":      atomic.LoadInt64(&c.downloads),
+		"coalesced":      atomic.LoadInt64(&c.coalesced),
 	}
 }
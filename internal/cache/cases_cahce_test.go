@@ -1,10 +1,18 @@
 package cache
 
 import (
+	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
+	"hitwh-judge/internal/conf"
+	"hitwh-judge/internal/testdata"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -275,3 +283,393 @@ func TestEnhancedTestFileCache_CalculateMD5(t *testing.T) {
 		t.Errorf("Expected MD5 %s, got %s", expectedMD5, calculatedMD5)
 	}
 }
+
+func TestEnhancedTestFileCache_DownloadFromStore(t *testing.T) {
+	storeDir := t.TempDir()
+	content := "1 2\n"
+	md5Hash := fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	if err := os.MkdirAll(filepath.Join(storeDir, "bucket"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "bucket", md5Hash), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &EnhancedTestFileCache{
+		cache:        make(map[string]*cachedFile),
+		ttl:          time.Minute,
+		cleanFreq:    time.Minute,
+		cacheDir:     t.TempDir(),
+		maxDiskUsage: 1024 * 1024,
+	}
+	if _, err := cache.DownloadFileByMD5WithCache("bucket", md5Hash); err == nil {
+		t.Fatal("DownloadFileByMD5WithCache() without store error = nil, want error")
+	}
+
+	downloader := testdata.NewDownloader(testdata.NewLocalStore(storeDir), testdata.KeyLayout{})
+	downloader.InitialBackoff = time.Millisecond
+	cache.SetDownloader(downloader)
+	filePath, err := cache.DownloadFileByMD5WithCache("bucket", md5Hash)
+	if err != nil {
+		t.Fatalf("DownloadFileByMD5WithCache() error = %v", err)
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != content {
+		t.Errorf("cached file = %q, %v, want %q", data, err, content)
+	}
+	if got, err := cache.DownloadFileByMD5WithCacheContent("bucket", md5Hash); err != nil || got != content {
+		t.Errorf("DownloadFileByMD5WithCacheContent() = %q, %v", got, err)
+	}
+
+	missing := fmt.Sprintf("%x", md5.Sum([]byte("missing")))
+	if _, err := cache.DownloadFileByMD5WithCache("bucket", missing); !errors.Is(err, testdata.ErrObjectNotFound) {
+		t.Errorf("DownloadFileByMD5WithCache(missing) error = %v, want ErrObjectNotFound", err)
+	}
+
+	// 存储中的内容与MD5不符（如上传被截断）时不写入缓存
+	corrupted := fmt.Sprintf("%x", md5.Sum([]byte("1 2 3\n")))
+	if err := os.WriteFile(filepath.Join(storeDir, "bucket", corrupted), []byte("1 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.DownloadFileByMD5WithCache("bucket", corrupted); !errors.Is(err, testdata.ErrChecksumMismatch) {
+		t.Errorf("DownloadFileByMD5WithCache(corrupted) error = %v, want ErrChecksumMismatch", err)
+	}
+	if _, ok := cache.GetFilePath("bucket", corrupted); ok {
+		t.Error("corrupted file should not be cached")
+	}
+	if _, err := cache.DownloadFileByMD5WithCache("bucket", "../../etc/passwd"); err == nil {
+		t.Error("DownloadFileByMD5WithCache(invalid md5) error = nil, want error")
+	}
+}
+
+// slowStore 统计读取次数，每次读取前等待一段时间以模拟慢速下载
+type slowStore struct {
+	testdata.TestDataStore
+	content string
+	calls   int64
+}
+
+func (s *slowStore) Get(_ context.Context, _, _ string) (io.ReadCloser, error) {
+	atomic.AddInt64(&s.calls, 1)
+	time.Sleep(50 * time.Millisecond)
+	return io.NopCloser(strings.NewReader(s.content)), nil
+}
+
+func TestEnhancedTestFileCache_CoalesceConcurrentMisses(t *testing.T) {
+	content := "hot problem data"
+	md5Hash := fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	store := &slowStore{content: content}
+	cache := &EnhancedTestFileCache{
+		cache:        make(map[string]*cachedFile),
+		ttl:          time.Minute,
+		cleanFreq:    time.Minute,
+		cacheDir:     t.TempDir(),
+		maxDiskUsage: 1024 * 1024,
+	}
+	cache.SetDownloader(testdata.NewDownloader(store, testdata.KeyLayout{}))
+
+	const callers = 10
+	var wg sync.WaitGroup
+	paths := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = cache.DownloadFileByMD5WithCache("bucket", md5Hash)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil || paths[i] != paths[0] {
+			t.Errorf("caller %d = %q, %v, want %q", i, paths[i], errs[i], paths[0])
+		}
+	}
+	if calls := atomic.LoadInt64(&store.calls); calls != 1 {
+		t.Errorf("store calls = %d, want 1", calls)
+	}
+	stats := cache.GetCacheStats()
+	if stats["downloads"] != int64(1) || stats["coalesced"] == int64(0) {
+		t.Errorf("stats downloads = %v, coalesced = %v", stats["downloads"], stats["coalesced"])
+	}
+}
+
+func TestNewEnhancedTestFileCache_Restore(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheFile := func(name, content string, modTime, accessTime time.Time) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, accessTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	md5Of := func(content string) string { return fmt.Sprintf("%x", md5.Sum([]byte(content))) }
+
+	now := time.Now()
+	oldAccess := now.Add(-20 * time.Minute).Truncate(time.Second)
+	hot := writeCacheFile("my_bucket_"+md5Of("hot"), "hot", now.Add(-30*time.Minute), now.Add(-time.Minute))
+	cold := writeCacheFile("bucket_"+md5Of("cold!"), "cold!", now.Add(-30*time.Minute), oldAccess)
+	corrupted := writeCacheFile("bucket_"+md5Of("original"), "tampered", now, now)
+	expired := writeCacheFile("bucket_"+md5Of("stale"), "stale", now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+	partial := writeCacheFile(".download-123", "par", now, now)
+	unrelated := writeCacheFile("notes.txt", "keep me", now, now)
+
+	c, err := newEnhancedTestFileCache(&conf.CacheConfig{
+		Dir:            dir,
+		TestCaseTTL:    time.Hour,
+		MaxDiskUsage:   1024,
+		CleanFrequency: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newEnhancedTestFileCache() error = %v", err)
+	}
+
+	if len(c.cache) != 2 || c.currentUsage != int64(len("hot")+len("cold!")) {
+		t.Errorf("restored %d files, usage %d, want 2 files, usage %d", len(c.cache), c.currentUsage, len("hot")+len("cold!"))
+	}
+	if got, ok := c.GetFilePath("my_bucket", md5Of("hot")); !ok || got != hot {
+		t.Errorf("GetFilePath(hot) = %q, %v, want %q", got, ok, hot)
+	}
+	if cached := c.cache[c.generateKey("bucket", md5Of("cold!"))]; cached == nil || !cached.accessTime.Equal(oldAccess) {
+		t.Errorf("restored cold entry = %+v, want access time %v", cached, oldAccess)
+	}
+	for _, path := range []string{corrupted, expired, partial} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed, stat error = %v", filepath.Base(path), err)
+		}
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("unrelated file should be kept, stat error = %v", err)
+	}
+	if _, err := os.Stat(cold); err != nil {
+		t.Errorf("cold file should be kept, stat error = %v", err)
+	}
+}
+
+func TestNewEnhancedTestFileCache_RestoreOverCapacity(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	var paths []string
+	for i, content := range []string{"aaaa", "bbbb", "cccc"} {
+		path := filepath.Join(dir, fmt.Sprintf("bucket_%x", md5.Sum([]byte(content))))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// 第0个文件最久未被访问
+		accessed := now.Add(time.Duration(i-3) * time.Minute)
+		if err := os.Chtimes(path, accessed, now); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	// 容量缩小后重启，恢复时淘汰最久未使用的文件
+	c, err := newEnhancedTestFileCache(&conf.CacheConfig{Dir: dir, TestCaseTTL: time.Hour, MaxDiskUsage: 8, CleanFrequency: time.Minute})
+	if err != nil {
+		t.Fatalf("newEnhancedTestFileCache() error = %v", err)
+	}
+	if len(c.cache) != 2 || c.currentUsage != 8 {
+		t.Errorf("restored %d files, usage %d, want 2 files, usage 8", len(c.cache), c.currentUsage)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("least recently used file should be evicted, stat error = %v", err)
+	}
+}
+
+func TestEnhancedTestFileCache_EvictWithoutWatermarks(t *testing.T) {
+	cache := &EnhancedTestFileCache{
+		cache:        make(map[string]*cachedFile),
+		ttl:          time.Minute,
+		cleanFreq:    time.Minute,
+		cacheDir:     t.TempDir(),
+		maxDiskUsage: 100,
+	}
+	// 4个19字节的文件共76字节，再放入25字节的文件只需淘汰最久未使用的一个
+	for _, c := range "abcd" {
+		content := strings.Repeat(string(c), 19)
+		if err := cache.Set("bucket", fmt.Sprintf("%x", md5.Sum([]byte(content))), content); err != nil {
+			t.Fatalf("Set(%c) error = %v", c, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	content := strings.Repeat("e", 25)
+	if err := cache.Set("bucket", fmt.Sprintf("%x", md5.Sum([]byte(content))), content); err != nil {
+		t.Fatalf("Set(e) error = %v", err)
+	}
+	if len(cache.cache) != 4 || cache.currentUsage != 82 || cache.evictions != 1 {
+		t.Errorf("cached = %d, usage = %d, evictions = %d, want 4, 82, 1", len(cache.cache), cache.currentUsage, cache.evictions)
+	}
+	oldest := strings.Repeat("a", 19)
+	if _, ok := cache.cache[cache.generateKey("bucket", fmt.Sprintf("%x", md5.Sum([]byte(oldest))))]; ok {
+		t.Error("least recently used file should be evicted")
+	}
+}
+
+func TestEnhancedTestFileCache_LRUWithWatermarks(t *testing.T) {
+	cache := &EnhancedTestFileCache{
+		cache:         make(map[string]*cachedFile),
+		ttl:           time.Minute,
+		cleanFreq:     time.Minute,
+		cacheDir:      t.TempDir(),
+		maxDiskUsage:  100,
+		highWatermark: 0.8,
+		lowWatermark:  0.5,
+	}
+	// 每个文件20字节
+	contents := []string{
+		"aaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbb", "cccccccccccccccccccc",
+		"dddddddddddddddddddd", "eeeeeeeeeeeeeeeeeeee",
+	}
+	hashes := make([]string, len(contents))
+	for i, content := range contents {
+		hashes[i] = fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	}
+	for _, i := range []int{0, 1, 2, 3} {
+		if err := cache.Set("bucket", hashes[i], contents[i]); err != nil {
+			t.Fatalf("Set(%d) error = %v", i, err)
+		}
+	}
+	// 文件0最早写入但最近被访问，文件1被固定
+	if _, ok := cache.GetFilePath("bucket", hashes[0]); !ok {
+		t.Fatal("GetFilePath(0) should hit")
+	}
+	_, release, err := cache.Acquire("bucket", hashes[1])
+	if err != nil {
+		t.Fatalf("Acquire(1) error = %v", err)
+	}
+
+	// 80+20超过高水位，淘汰最久未使用且未固定的文件（2、3）直到不超过低水位
+	if err := cache.Set("bucket", hashes[4], contents[4]); err != nil {
+		t.Fatalf("Set(4) error = %v", err)
+	}
+	for i, want := range []bool{true, true, false, false, true} {
+		if _, ok := cache.cache[cache.generateKey("bucket", hashes[i])]; ok != want {
+			t.Errorf("file %d cached = %v, want %v", i, ok, want)
+		}
+	}
+	if cache.currentUsage != 60 || cache.evictions != 2 {
+		t.Errorf("usage = %d, evictions = %d, want 60, 2", cache.currentUsage, cache.evictions)
+	}
+
+	// 固定的文件不会过期，释放后按TTL重新计时
+	cache.cache[cache.generateKey("bucket", hashes[1])].expireTime = time.Now().Add(-time.Second)
+	cache.cleanExpired()
+	if _, ok := cache.cache[cache.generateKey("bucket", hashes[1])]; !ok {
+		t.Error("pinned file should not expire")
+	}
+	release()
+	release() // 重复调用无副作用
+	if refs := cache.cache[cache.generateKey("bucket", hashes[1])].refs; refs != 0 {
+		t.Errorf("refs after release = %d, want 0", refs)
+	}
+}
+
+func TestEnhancedTestFileCache_PinnedFilesNotEvicted(t *testing.T) {
+	cache := &EnhancedTestFileCache{
+		cache:        make(map[string]*cachedFile),
+		ttl:          time.Minute,
+		cleanFreq:    time.Minute,
+		cacheDir:     t.TempDir(),
+		maxDiskUsage: 30,
+	}
+	first, second := "first file 1234567890", "second file 123456789"
+	firstMD5 := fmt.Sprintf("%x", md5.Sum([]byte(first)))
+	if err := cache.Set("bucket", firstMD5, first); err != nil {
+		t.Fatal(err)
+	}
+	filePath, release, err := cache.Acquire("bucket", firstMD5)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	// 唯一可淘汰的文件正在使用，空间不足时报错而不是删除它
+	if err := cache.Set("bucket", fmt.Sprintf("%x", md5.Sum([]byte(second))), second); err == nil {
+		t.Error("Set() error = nil, want not enough space")
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != first {
+		t.Errorf("pinned file = %q, %v, want %q", data, err, first)
+	}
+
+	release()
+	if err := cache.Set("bucket", fmt.Sprintf("%x", md5.Sum([]byte(second))), second); err != nil {
+		t.Errorf("Set() after release error = %v", err)
+	}
+}
+
+func TestEnhancedTestFileCache_ConcurrentHits(t *testing.T) {
+	cache := &EnhancedTestFileCache{
+		cache:        make(map[string]*cachedFile),
+		ttl:          time.Minute,
+		cleanFreq:    time.Minute,
+		cacheDir:     t.TempDir(),
+		maxDiskUsage: 1024 * 1024,
+	}
+	content := strings.Repeat("1 2 3\n", 1000)
+	md5Hash := fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	if err := cache.Set("bucket", md5Hash, content); err != nil {
+		t.Fatal(err)
+	}
+
+	// 命中时在锁外校验MD5，并发获取须各自固定一次
+	const workers = 8
+	releases := make([]func(), workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, release, err := cache.Acquire("bucket", md5Hash)
+			if err != nil {
+				t.Errorf("Acquire() error = %v", err)
+				return
+			}
+			releases[i] = release
+		}(i)
+	}
+	wg.Wait()
+	if stats := cache.GetCacheStats(); stats["pinned_files"] != 1 {
+		t.Errorf("pinned_files = %v, want 1", stats["pinned_files"])
+	}
+	if refs := cache.cache[cache.generateKey("bucket", md5Hash)].refs; refs != workers {
+		t.Errorf("refs = %d, want %d", refs, workers)
+	}
+
+	for _, release := range releases {
+		if release != nil {
+			release()
+		}
+	}
+	if refs := cache.cache[cache.generateKey("bucket", md5Hash)].refs; refs != 0 {
+		t.Errorf("refs after release = %d, want 0", refs)
+	}
+}
+
+func TestEnhancedTestFileCache_BucketUsage(t *testing.T) {
+	cache := &EnhancedTestFileCache{
+		cache:        make(map[string]*cachedFile),
+		ttl:          time.Minute,
+		cleanFreq:    time.Minute,
+		cacheDir:     t.TempDir(),
+		maxDiskUsage: 1024,
+	}
+	for bucket, contents := range map[string][]string{"p1": {"1 2", "3"}, "p2": {"hello"}} {
+		for _, content := range contents {
+			if err := cache.Set(bucket, fmt.Sprintf("%x", md5.Sum([]byte(content))), content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	stats := cache.GetCacheStats()
+	usage := stats["bucket_usage"].(map[string]int64)
+	if usage["p1"] != 4 || usage["p2"] != 5 || len(usage) != 2 {
+		t.Errorf("bucket_usage = %v, want p1:4 p2:5", usage)
+	}
+	if stats["pinned_files"].(int) != 0 {
+		t.Errorf("pinned_files = %v, want 0", stats["pinned_files"])
+	}
+}
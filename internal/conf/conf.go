@@ -59,8 +59,8 @@ func Load(confPath string) *viper.Viper {
 
 // replaceEnvPlaceholders 替换配置内容中的${VAR:-默认值}占位符
 func replaceEnvPlaceholders(content []byte) []byte {
-	// 正则匹配 ${变量名:-默认值} 或 ${变量名}
-	re := regexp.MustCompile(`\$\{([^}:-]+)(:-([^}]+))?}`)
+	// 正则匹配 ${变量名:-默认值}、${变量名:-}（默认值为空）或 ${变量名}
+	re := regexp.MustCompile(`\$\{([^}:-]+)(:-([^}]*))?}`)
 	return re.ReplaceAllFunc(content, func(match []byte) []byte {
 		groups := re.FindSubmatch(match)
 		varName := string(groups[1]) // 环境变量名
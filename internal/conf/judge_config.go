@@ -1,47 +1,117 @@
 package conf
 
 import (
+	"hitwh-judge/internal/constants"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+// DefaultSandbox 默认沙箱类型
+const DefaultSandbox = "isolate"
+
 // JudgeConfig 评测配置
 type JudgeConfig struct {
-	MaxConcurrent      int           // 最大并发评测数
-	MaxTimeout         time.Duration // 单个评测最大超时时间
-	TempDir            string        // 临时目录
-	EnableEarlyStop    bool          // 遇到错误是否提前终止
-	MaxOutputSize      int64         // 最大输出大小
-	EnableCompileCache bool          // 是否启用编译缓存
+	MaxConcurrent      int               // 最大并发评测数
+	MaxTimeout         time.Duration     // 单个评测最大超时时间
+	TempDir            string            // 临时目录
+	EnableEarlyStop    bool              // 遇到错误是否提前终止
+	MaxOutputSize      int64             // 最大输出大小
+	EnableCompileCache bool              // 是否启用编译缓存
+	Sandbox            string            // 沙箱类型（isolate/nsjail/sdu_sandbox）
+	SandboxPath        string            // 沙箱可执行文件路径（空则使用该沙箱的默认路径）
+	SandboxOverrides   map[string]string // 按语言指定沙箱类型，键为语言，值为沙箱类型
+
+	SandboxPoolSize            int           // 预先初始化的isolate box数量，0表示不预热
+	SandboxWaitTimeout         time.Duration // box ID耗尽时等待空闲box的最长时间
+	SandboxHealthCheckInterval time.Duration // 后台检查预热box健康状况的间隔
+	BoxIDStart                 int           // 本进程使用的第一个isolate box ID
+	BoxIDCount                 int           // 本进程使用的isolate box ID数量，同一主机上各评测进程的范围不能重叠
 }
 
 // CacheConfig 缓存配置
 type CacheConfig struct {
-	TestCaseTTL    time.Duration // 测试用例缓存时间
+	Dir            string        // 缓存目录，重启后从该目录恢复缓存索引
+	TestCaseTTL    time.Duration // 测试用例缓存时间（自最后一次访问起计算）
 	MaxDiskUsage   int64         // 最大磁盘使用
 	CleanFrequency time.Duration // 清理频率
+	HighWatermark  float64       // 磁盘使用超过 MaxDiskUsage 的该比例时开始按最久未使用淘汰
+	LowWatermark   float64       // 淘汰到磁盘使用不超过 MaxDiskUsage 的该比例为止
 }
 
 // LoadJudgeConfig 从配置文件加载评测配置
 func LoadJudgeConfig(cfg *viper.Viper) *JudgeConfig {
-	return &JudgeConfig{
+	judgeConfig := &JudgeConfig{
 		MaxConcurrent:      cfg.GetInt("judge.max_concurrent"),
 		MaxTimeout:         time.Duration(cfg.GetInt("judge.max_timeout")) * time.Second,
 		TempDir:            cfg.GetString("judge.temp_dir"),
 		EnableEarlyStop:    cfg.GetBool("judge.enable_early_stop"),
 		MaxOutputSize:      cfg.GetInt64("judge.max_output_size"),
 		EnableCompileCache: cfg.GetBool("judge.enable_compile_cache"),
+		Sandbox:            cfg.GetString("judge.sandbox"),
+		SandboxPath:        cfg.GetString("judge.sandbox_path"),
+		SandboxOverrides:   cfg.GetStringMapString("judge.sandbox_overrides"),
+
+		SandboxPoolSize:            cfg.GetInt("judge.sandbox_pool_size"),
+		SandboxWaitTimeout:         time.Duration(cfg.GetInt("judge.sandbox_wait_timeout")) * time.Second,
+		SandboxHealthCheckInterval: time.Duration(cfg.GetInt("judge.sandbox_health_check_interval")) * time.Second,
+		BoxIDStart:                 cfg.GetInt("judge.box_id_start"),
+		BoxIDCount:                 cfg.GetInt("judge.box_id_count"),
+	}
+	defaults := GetDefaultJudgeConfig()
+	if judgeConfig.Sandbox == "" {
+		judgeConfig.Sandbox = defaults.Sandbox
+	}
+	if judgeConfig.SandboxPoolSize < 0 {
+		judgeConfig.SandboxPoolSize = 0
+	}
+	if judgeConfig.SandboxWaitTimeout <= 0 {
+		judgeConfig.SandboxWaitTimeout = defaults.SandboxWaitTimeout
+	}
+	if judgeConfig.SandboxHealthCheckInterval <= 0 {
+		judgeConfig.SandboxHealthCheckInterval = defaults.SandboxHealthCheckInterval
+	}
+	if judgeConfig.BoxIDStart < 0 {
+		judgeConfig.BoxIDStart = defaults.BoxIDStart
 	}
+	if judgeConfig.BoxIDCount <= 0 {
+		judgeConfig.BoxIDCount = defaults.BoxIDCount
+	}
+	return judgeConfig
 }
 
-// LoadCacheConfig 从配置文件加载缓存配置
+// LoadCacheConfig 从配置文件加载缓存配置，未配置的项使用默认值
 func LoadCacheConfig(cfg *viper.Viper) *CacheConfig {
-	return &CacheConfig{
+	cacheConfig := &CacheConfig{
+		Dir:            cfg.GetString("cache.dir"),
 		TestCaseTTL:    time.Duration(cfg.GetInt("cache.test_case_ttl")) * time.Second,
 		MaxDiskUsage:   cfg.GetInt64("cache.max_disk_usage"),
 		CleanFrequency: time.Duration(cfg.GetInt("cache.clean_frequency")) * time.Second,
+		HighWatermark:  cfg.GetFloat64("cache.high_watermark"),
+		LowWatermark:   cfg.GetFloat64("cache.low_watermark"),
+	}
+	defaults := GetDefaultCacheConfig()
+	if cacheConfig.Dir == "" {
+		cacheConfig.Dir = defaults.Dir
+	}
+	if cacheConfig.TestCaseTTL <= 0 {
+		cacheConfig.TestCaseTTL = defaults.TestCaseTTL
+	}
+	if cacheConfig.MaxDiskUsage <= 0 {
+		cacheConfig.MaxDiskUsage = defaults.MaxDiskUsage
 	}
+	if cacheConfig.CleanFrequency <= 0 {
+		cacheConfig.CleanFrequency = defaults.CleanFrequency
+	}
+	if cacheConfig.HighWatermark <= 0 {
+		cacheConfig.HighWatermark = defaults.HighWatermark
+	}
+	if cacheConfig.LowWatermark <= 0 {
+		cacheConfig.LowWatermark = defaults.LowWatermark
+	}
+	return cacheConfig
 }
 
 // GetDefaultJudgeConfig 获取默认评测配置
@@ -53,14 +123,23 @@ func GetDefaultJudgeConfig() *JudgeConfig {
 		EnableEarlyStop:    false,
 		MaxOutputSize:      10 * 1024 * 1024, // 10MB
 		EnableCompileCache: false,
+		Sandbox:            DefaultSandbox,
+
+		SandboxWaitTimeout:         30 * time.Second,
+		SandboxHealthCheckInterval: time.Minute,
+		BoxIDStart:                 0,
+		BoxIDCount:                 constants.BoxIDPoolSize,
 	}
 }
 
 // GetDefaultCacheConfig 获取默认缓存配置
 func GetDefaultCacheConfig() *CacheConfig {
 	return &CacheConfig{
+		Dir:            filepath.Join(os.TempDir(), "judge-cache-enhanced"),
 		TestCaseTTL:    30 * time.Minute,
 		MaxDiskUsage:   2 * 1024 * 1024 * 1024, // 2GB
 		CleanFrequency: 10 * time.Minute,
+		HighWatermark:  0.9,
+		LowWatermark:   0.7,
 	}
 }
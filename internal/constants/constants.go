@@ -25,10 +25,17 @@ const (
 	DefaultMaxConcurrent = 2  // 默认最大并发评测数
 	MinConcurrent        = 1  // 最小并发数
 	MaxConcurrent        = 16 // 最大并发数
+	CaseDownloadWorkers  = 8  // 单个评测任务并行下载测试数据的最大测试点数
+
+	// 特殊评测（checker）资源限制
+	CheckerTimeLimit   = 10  // checker时间限制（秒）
+	CheckerMemoryLimit = 512 // checker内存限制（MB）
 
 	// 输出限制
 	MaxOutputSize = 10 * 1024 * 1024 // 最大输出大小（10MB）
 	MaxErrorSize  = 1024             // 最大错误信息大小（1KB）
+	// 评测结果中保留的输出预览大小（64KB），完整输出保存在文件中
+	OutputPreviewSize = 64 * 1024
 
 	// 临时文件
 	TempDirPrefix = "oj-judge-" // 临时目录前缀
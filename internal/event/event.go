@@ -0,0 +1,82 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// Type 评测进度事件类型
+type Type = string
+
+const (
+	TypeState           Type = "state"            // 订阅时的任务当前状态
+	TypeCompileStarted  Type = "compile_started"  // 开始编译
+	TypeCompileFinished Type = "compile_finished" // 编译完成（含编译信息）
+	TypeCaseStarted     Type = "case_started"     // 测试点开始运行
+	TypeCaseFinished    Type = "case_finished"    // 测试点运行完成（含测试点结果）
+	TypeFinished        Type = "finished"         // 评测完成（含最终结果）
+	TypeFailed          Type = "failed"           // 评测失败（含失败原因）
+)
+
+// Event 评测进度事件
+type Event struct {
+	TaskID     int64                 `json:"task_id"`               // 任务ID
+	Type       Type                  `json:"type"`                  // 事件类型
+	State      model.TaskState       `json:"state,omitempty"`       // 任务阶段（state事件）
+	CaseIndex  int                   `json:"case_index"`            // 测试点下标（测试点事件）
+	CaseTotal  int                   `json:"case_total"`            // 测试点总数（测试点事件）
+	Compile    *model.CompileResult  `json:"compile,omitempty"`     // 编译结果（compile_finished事件）
+	CaseResult *model.TestCaseResult `json:"case_result,omitempty"` // 测试点结果（case_finished事件）
+	Result     *model.JudgeResult    `json:"result,omitempty"`      // 最终结果（finished事件）
+	Error      string                `json:"error,omitempty"`       // 失败原因（failed事件）
+	Time       time.Time             `json:"time"`                  // 事件时间
+}
+
+// IsFinal 是否为任务的最后一个事件
+func (e *Event) IsFinal() bool {
+	return e.Type == TypeFinished || e.Type == TypeFailed
+}
+
+// Broker 评测进度事件分发
+type Broker interface {
+	// Publish 发布事件，没有订阅者时事件被丢弃
+	Publish(ctx context.Context, e *Event) error
+	// Subscribe 订阅任务的事件，返回事件通道及取消订阅函数
+	// 订阅在函数返回时已生效，调用方取消订阅后通道被关闭；
+	// 订阅者消费过慢时中间事件可能被丢弃，最终事件无法投递时通道被关闭，调用方应查询最终结果
+	Subscribe(ctx context.Context, taskID int64) (<-chan *Event, func(), error)
+}
+
+// 事件分发类型
+const (
+	TypeMemoryBroker = "memory" // 进程内分发（默认）
+	TypeRedisBroker  = "redis"  // Redis发布订阅，评测进程与接收订阅的进程可以不同
+)
+
+// DefaultKeyPrefix Redis频道前缀
+const DefaultKeyPrefix = "judge"
+
+// New 根据配置创建事件分发
+// 配置项：events.type（默认memory）、events.key_prefix
+func New(cfg *viper.Viper, rdb *redis.Client) (Broker, error) {
+	switch brokerType := cfg.GetString("events.type"); brokerType {
+	case "", TypeMemoryBroker:
+		return NewMemoryBroker(), nil
+	case TypeRedisBroker:
+		if rdb == nil {
+			return nil, fmt.Errorf("Redis事件分发需要先初始化Redis连接")
+		}
+		keyPrefix := cfg.GetString("events.key_prefix")
+		if keyPrefix == "" {
+			keyPrefix = DefaultKeyPrefix
+		}
+		return NewRedisBroker(rdb, keyPrefix), nil
+	default:
+		return nil, fmt.Errorf("不支持的事件分发类型: %s", brokerType)
+	}
+}
@@ -0,0 +1,84 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// subscriberBuffer 每个订阅者的事件缓冲大小，订阅者消费过慢时多余事件被丢弃
+// 最终事件不会被丢弃：缓冲已满时改为关闭订阅通道，订阅方在通道关闭后查询最终结果
+const subscriberBuffer = 256
+
+// MemoryBroker 进程内事件分发
+type MemoryBroker struct {
+	mu   sync.RWMutex
+	subs map[int64]map[*memorySubscriber]struct{}
+}
+
+// memorySubscriber 一个订阅者的事件通道，通道只关闭一次
+type memorySubscriber struct {
+	ch   chan *Event
+	once sync.Once
+}
+
+// NewMemoryBroker 创建进程内事件分发
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[int64]map[*memorySubscriber]struct{})}
+}
+
+// Publish 发布事件
+func (b *MemoryBroker) Publish(_ context.Context, e *Event) error {
+	var overflowed []*memorySubscriber
+	b.mu.RLock()
+	for sub := range b.subs[e.TaskID] {
+		select {
+		case sub.ch <- e:
+		default:
+			if e.IsFinal() {
+				overflowed = append(overflowed, sub)
+				continue
+			}
+			zap.L().Warn("评测进度订阅者消费过慢，丢弃事件",
+				zap.Int64("task_id", e.TaskID),
+				zap.String("type", e.Type),
+			)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range overflowed {
+		zap.L().Warn("评测进度订阅者消费过慢，无法投递最终事件，关闭订阅",
+			zap.Int64("task_id", e.TaskID),
+			zap.String("type", e.Type),
+		)
+		b.unsubscribe(e.TaskID, sub)
+	}
+	return nil
+}
+
+// Subscribe 订阅任务的事件
+func (b *MemoryBroker) Subscribe(_ context.Context, taskID int64) (<-chan *Event, func(), error) {
+	sub := &memorySubscriber{ch: make(chan *Event, subscriberBuffer)}
+
+	b.mu.Lock()
+	if b.subs[taskID] == nil {
+		b.subs[taskID] = make(map[*memorySubscriber]struct{})
+	}
+	b.subs[taskID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.ch, func() { b.unsubscribe(taskID, sub) }, nil
+}
+
+// unsubscribe 移除订阅者并关闭其通道，重复调用没有影响
+func (b *MemoryBroker) unsubscribe(taskID int64, sub *memorySubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs[taskID], sub)
+	if len(b.subs[taskID]) == 0 {
+		delete(b.subs, taskID)
+	}
+	sub.once.Do(func() { close(sub.ch) })
+}
@@ -0,0 +1,71 @@
+package event
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+
+	ch, cancel, err := b.Subscribe(ctx, 1)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	_ = b.Publish(ctx, &Event{TaskID: 2, Type: TypeCaseStarted})
+	_ = b.Publish(ctx, &Event{TaskID: 1, Type: TypeCaseStarted, CaseIndex: 3})
+
+	select {
+	case e := <-ch:
+		if e.TaskID != 1 || e.CaseIndex != 3 {
+			t.Errorf("received %+v, want event of task 1 case 3", e)
+		}
+	default:
+		t.Fatal("no event received")
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("received unexpected event %+v", e)
+	default:
+	}
+
+	cancel()
+	cancel() // 重复取消不应panic
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after cancel")
+	}
+	if err := b.Publish(ctx, &Event{TaskID: 1, Type: TypeFinished}); err != nil {
+		t.Errorf("Publish() after cancel error = %v", err)
+	}
+}
+
+func TestMemoryBroker_SlowSubscriberFinalEvent(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+
+	ch, cancel, err := b.Subscribe(ctx, 1)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer cancel()
+
+	// 填满缓冲后中间事件被丢弃，最终事件无法投递时关闭通道而不是静默丢弃
+	for i := 0; i <= subscriberBuffer; i++ {
+		_ = b.Publish(ctx, &Event{TaskID: 1, Type: TypeCaseFinished, CaseIndex: i})
+	}
+	_ = b.Publish(ctx, &Event{TaskID: 1, Type: TypeFinished})
+
+	received := 0
+	for e := range ch {
+		if e.IsFinal() {
+			t.Fatalf("received final event %+v, want channel closed", e)
+		}
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before close, want %d", received, subscriberBuffer)
+	}
+	cancel() // 通道已被关闭，取消订阅不应panic
+}
@@ -0,0 +1,86 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RedisBroker 基于Redis发布订阅的事件分发
+type RedisBroker struct {
+	rdb       *redis.Client
+	keyPrefix string
+}
+
+// NewRedisBroker 创建Redis事件分发
+func NewRedisBroker(rdb *redis.Client, keyPrefix string) *RedisBroker {
+	return &RedisBroker{rdb: rdb, keyPrefix: keyPrefix}
+}
+
+// channel 任务对应的Redis频道
+func (b *RedisBroker) channel(taskID int64) string {
+	return b.keyPrefix + ":events:" + strconv.FormatInt(taskID, 10)
+}
+
+// Publish 发布事件
+func (b *RedisBroker) Publish(ctx context.Context, e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("序列化评测进度事件失败: %w", err)
+	}
+	if err := b.rdb.Publish(ctx, b.channel(e.TaskID), data).Err(); err != nil {
+		return fmt.Errorf("发布评测进度事件失败: %w", err)
+	}
+	return nil
+}
+
+// Subscribe 订阅任务的事件
+func (b *RedisBroker) Subscribe(ctx context.Context, taskID int64) (<-chan *Event, func(), error) {
+	pubsub := b.rdb.Subscribe(ctx, b.channel(taskID))
+	// 等待订阅确认，保证返回后发布的事件不会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, nil, fmt.Errorf("订阅评测进度事件失败: %w", err)
+	}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { pubsub.Close() })
+	}
+
+	ch := make(chan *Event, subscriberBuffer)
+	go func() {
+		defer close(ch)
+		for msg := range pubsub.Channel() {
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				zap.L().Warn("解析评测进度事件失败", zap.Int64("task_id", taskID), zap.Error(err))
+				continue
+			}
+			select {
+			case ch <- &e:
+			default:
+				if e.IsFinal() {
+					// 最终事件不丢弃，关闭通道由订阅方查询最终结果
+					zap.L().Warn("评测进度订阅者消费过慢，无法投递最终事件，关闭订阅",
+						zap.Int64("task_id", taskID),
+						zap.String("type", e.Type),
+					)
+					cancel()
+					return
+				}
+				zap.L().Warn("评测进度订阅者消费过慢，丢弃事件",
+					zap.Int64("task_id", taskID),
+					zap.String("type", e.Type),
+				)
+			}
+		}
+	}()
+
+	return ch, cancel, nil
+}
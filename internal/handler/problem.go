@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"hitwh-judge/api"
+	v1 "hitwh-judge/api/calc/v1"
+	"hitwh-judge/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ImportProblemHandler 上传zip格式的题目包（表单字段 package），导入为题目的新版本
+func ImportProblemHandler(c *gin.Context) {
+	problemID := c.Param("id")
+	fileHeader, err := c.FormFile("package")
+	if err != nil {
+		api.ResponseErrorWithMsg(c, api.CodeInvalidParam, "缺少题目包文件(package)")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		zap.L().Error("import-problem open file failed", zap.Error(err))
+		api.ResponseError(c, api.CodeInternalError)
+		return
+	}
+	defer file.Close()
+
+	pkg, err := service.ImportProblem(problemID, file, fileHeader.Size)
+	if err != nil {
+		zap.L().Error("import-problem failed", zap.String("problem_id", problemID), zap.Error(err))
+		api.ResponseErrorWithMsg(c, api.CodeInvalidParam, err.Error())
+		return
+	}
+	api.ResponseSuccess(c, &v1.ImportProblemResp{
+		ProblemID:   pkg.ID,
+		Version:     pkg.Version,
+		Name:        pkg.Manifest.Name,
+		CheckPoints: len(pkg.Manifest.CheckPoints),
+		Subtasks:    len(pkg.Manifest.Subtasks),
+	})
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"hitwh-judge/api"
 	v1 "hitwh-judge/api/calc/v1"
 	"hitwh-judge/internal/service"
+	"hitwh-judge/internal/store"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -27,6 +29,10 @@ func AddTaskHandler(c *gin.Context) {
 	// ✅ 完善错误处理
 	// ✅ 详细的日志记录
 	judgeResult, err := service.AddTask(c, req)
+	if errors.Is(err, service.ErrInvalidTask) {
+		api.ResponseErrorWithMsg(c, api.CodeInvalidParam, err.Error())
+		return
+	}
 	if err != nil {
 		zap.L().Error("add-task failed", zap.Error(err))
 		api.ResponseError(c, api.CodeInternalError)
@@ -34,3 +40,50 @@ func AddTaskHandler(c *gin.Context) {
 	}
 	api.ResponseSuccess(c, judgeResult)
 }
+
+// SubmitTaskHandler 异步提交评测任务，立即返回任务ID
+func SubmitTaskHandler(c *gin.Context) {
+	var req *v1.TaskReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zap.L().Error("submit-task bind json failed", zap.Error(err))
+		api.ResponseError(c, api.CodeInvalidParam)
+		return
+	}
+	zap.L().Info("submit-task", zap.Any("req", req))
+
+	record, err := service.SubmitTask(c, req)
+	if errors.Is(err, service.ErrInvalidTask) {
+		api.ResponseErrorWithMsg(c, api.CodeInvalidParam, err.Error())
+		return
+	}
+	if err != nil {
+		// 结果存储、任务队列等内部错误，请求本身无需修改
+		zap.L().Error("submit-task failed", zap.Error(err))
+		api.ResponseError(c, api.CodeInternalError)
+		return
+	}
+	api.ResponseSuccess(c, &v1.SubmitTaskResp{
+		TaskID: record.TaskID,
+		Status: record.State,
+	})
+}
+
+// GetTaskHandler 查询评测任务状态及结果
+func GetTaskHandler(c *gin.Context) {
+	taskID, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+
+	record, err := service.GetTask(c, taskID)
+	if errors.Is(err, store.ErrTaskNotFound) {
+		api.ResponseError(c, api.CodeTaskNotExist)
+		return
+	}
+	if err != nil {
+		zap.L().Error("get-task failed", zap.Int64("task_id", taskID), zap.Error(err))
+		api.ResponseError(c, api.CodeInternalError)
+		return
+	}
+	api.ResponseSuccess(c, record)
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"hitwh-judge/api"
+	"hitwh-judge/internal/event"
+	"hitwh-judge/internal/service"
+	"hitwh-judge/internal/store"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// wsWriteTimeout WebSocket单条消息写超时
+const wsWriteTimeout = 10 * time.Second
+
+// upgrader 默认只允许同源页面建立连接，避免任意网页借用户浏览器读取评测结果（跨站WebSocket劫持）
+var upgrader = websocket.Upgrader{
+	CheckOrigin: wsOriginChecker(nil),
+}
+
+// SetWSAllowedOrigins 设置允许跨域建立WebSocket连接的来源，逗号分隔（如 https://oj.example.com）
+// 为空时只允许同源，须在启动服务前调用
+func SetWSAllowedOrigins(origins string) {
+	var allowed []string
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed = append(allowed, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	upgrader.CheckOrigin = wsOriginChecker(allowed)
+}
+
+// wsOriginChecker 返回校验Origin请求头的函数：没有Origin头（非浏览器客户端）或同源时允许，否则须在allowed中
+func wsOriginChecker(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return slices.ContainsFunc(allowed, func(o string) bool { return strings.EqualFold(o, origin) })
+	}
+}
+
+// TaskEventsHandler 以SSE推送评测任务进度事件
+func TaskEventsHandler(c *gin.Context) {
+	taskID, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+	events, ok := subscribeTaskEvents(c, c.Request.Context(), taskID)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // 禁用nginx缓冲
+	c.Stream(func(w io.Writer) bool {
+		e, ok := <-events
+		if !ok {
+			return false
+		}
+		c.SSEvent(e.Type, e)
+		return true
+	})
+}
+
+// TaskEventsWSHandler 以WebSocket推送评测任务进度事件，每条消息为一个JSON事件
+func TaskEventsWSHandler(c *gin.Context) {
+	taskID, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	events, ok := subscribeTaskEvents(c, ctx, taskID)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		zap.L().Warn("task-events websocket upgrade failed", zap.Int64("task_id", taskID), zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	// 读取客户端消息以处理关闭帧，客户端断开后结束推送
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for e := range events {
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(e); err != nil {
+			return
+		}
+	}
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(wsWriteTimeout))
+}
+
+// parseTaskID 解析路径中的任务ID，失败时写入错误响应
+func parseTaskID(c *gin.Context) (int64, bool) {
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		api.ResponseError(c, api.CodeInvalidParam)
+		return 0, false
+	}
+	return taskID, true
+}
+
+// subscribeTaskEvents 订阅任务进度事件，失败时写入错误响应
+func subscribeTaskEvents(c *gin.Context, ctx context.Context, taskID int64) (<-chan *event.Event, bool) {
+	events, err := service.SubscribeTaskEvents(ctx, taskID)
+	if errors.Is(err, store.ErrTaskNotFound) {
+		api.ResponseError(c, api.CodeTaskNotExist)
+		return nil, false
+	}
+	if err != nil {
+		zap.L().Error("subscribe task events failed", zap.Int64("task_id", taskID), zap.Error(err))
+		api.ResponseError(c, api.CodeInternalError)
+		return nil, false
+	}
+	return events, true
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWSOriginChecker(t *testing.T) {
+	check := wsOriginChecker([]string{"https://oj.example.com"})
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "非浏览器客户端", origin: "", want: true},
+		{name: "同源", origin: "http://judge.local:8080", want: true},
+		{name: "允许的来源", origin: "https://OJ.example.com", want: true},
+		{name: "其他网站", origin: "https://evil.example.com", want: false},
+		{name: "协议不同", origin: "http://oj.example.com", want: false},
+		{name: "无效来源", origin: "://", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://judge.local:8080/api/v1/task/1/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := check(r); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+
+	// 未配置时只允许同源
+	defer SetWSAllowedOrigins("")
+	SetWSAllowedOrigins("")
+	r := httptest.NewRequest("GET", "http://judge.local:8080/api/v1/task/1/ws", nil)
+	r.Header.Set("Origin", "https://oj.example.com")
+	if upgrader.CheckOrigin(r) {
+		t.Error("默认配置允许了跨域来源")
+	}
+	SetWSAllowedOrigins(" https://oj.example.com/ , https://admin.example.com")
+	if !upgrader.CheckOrigin(r) {
+		t.Error("配置的来源被拒绝")
+	}
+}
@@ -20,6 +20,36 @@ const (
 	JudgeInteractive JudgeType = "interactive" // 交互题评测
 )
 
+// JudgeMode 测试点运行模式
+type JudgeMode = string
+
+const (
+	JudgeModeAll          JudgeMode = "all"           // 运行全部测试点（OI赛制）
+	JudgeModeFirstFailure JudgeMode = "first_failure" // 遇到第一个未通过的测试点即停止（ACM赛制）
+)
+
+// CompareMode 输出比较模式
+type CompareMode = string
+
+const (
+	CompareModeStrict CompareMode = "strict" // 逐字节严格比较（仅忽略末尾的一个换行符），空白不同判为PE
+	CompareModeLine   CompareMode = "line"   // 逐行比较（忽略行末空白与末尾空行），空白不同判为PE
+	CompareModeToken  CompareMode = "token"  // 按空白分隔的词比较，不区分格式
+	CompareModeFloat  CompareMode = "float"  // 按词比较，数值在误差范围内视为相等
+)
+
+// DiffReveal 答案错误时差异信息的公开范围
+type DiffReveal = string
+
+const (
+	DiffRevealNone   DiffReveal = "none"   // 不公开差异（默认）
+	DiffRevealSample DiffReveal = "sample" // 仅公开样例测试点的差异
+	DiffRevealAll    DiffReveal = "all"    // 公开全部测试点的差异
+)
+
+// DefaultFloatEps 浮点比较默认的绝对误差与相对误差
+const DefaultFloatEps = 1e-6
+
 // TaskConfig 评测任务配置
 type TaskConfig struct {
 	TimeLimit   int          `json:"time_limit"`    // 时间限制（秒）
@@ -28,6 +58,11 @@ type TaskConfig struct {
 	Language    LanguageType `json:"language"`      // 编程语言
 	JudgeType   JudgeType    `json:"judge_type"`    // 评测类型
 	IsO2Enabled bool         `json:"is_o2_enabled"` // 是否启用O2优化
+	JudgeMode   JudgeMode    `json:"judge_mode"`    // 测试点运行模式
+	CompareMode CompareMode  `json:"compare_mode"`  // 输出比较模式
+	FloatEps    float64      `json:"float_eps"`     // 浮点比较的绝对误差（float模式，为0时不按绝对误差比较）
+	FloatRelEps float64      `json:"float_rel_eps"` // 浮点比较的相对误差（float模式，为0时不按相对误差比较）
+	RevealDiff  DiffReveal   `json:"reveal_diff"`   // 答案错误时差异信息的公开范围
 }
 
 // DefaultTaskConfig 默认评测配置
@@ -38,6 +73,11 @@ var DefaultTaskConfig = TaskConfig{
 	Language:    LanguageC,
 	JudgeType:   JudgeIO,
 	IsO2Enabled: false,
+	JudgeMode:   JudgeModeAll,
+	CompareMode: CompareModeToken,
+	FloatEps:    DefaultFloatEps,
+	FloatRelEps: DefaultFloatEps,
+	RevealDiff:  DiffRevealNone,
 }
 
 // SandboxConfig 沙箱配置
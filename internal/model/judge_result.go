@@ -13,9 +13,12 @@ const (
 	StatusWA      JudgeStatus = "WA"      // 答案错误
 	StatusTLE     JudgeStatus = "TLE"     // 时间超限
 	StatusMLE     JudgeStatus = "MLE"     // 内存超限
+	StatusOLE     JudgeStatus = "OLE"     // 输出超限
 	StatusRE      JudgeStatus = "RE"      // 运行时错误
 	StatusSE      JudgeStatus = "SE"      // 系统错误
 	StatusPE      JudgeStatus = "PE"      // 格式错误
+	StatusPC      JudgeStatus = "PC"      // 部分正确（checker给出部分分）
+	StatusSkipped JudgeStatus = "SKIPPED" // 未评测（已跳过）
 )
 
 // CompileResult 编译结果
@@ -26,28 +29,58 @@ type CompileResult struct {
 
 // TestCaseResult 单个测试点结果
 type TestCaseResult struct {
-	TestCaseIndex int           `json:"test_case_index"` // 测试点索引
-	Status        JudgeStatus   `json:"status"`          // 测试点状态
-	TimeUsed      time.Duration `json:"time_used"`       // 实际运行时间
-	MemUsed       uint64        `json:"mem_used"`        // 实际内存使用
-	Output        string        `json:"output"`          // 程序输出
-	Expected      string        `json:"expected"`        // 期望输出
-	Error         string        `json:"error"`           // 错误信息
+	TestCaseIndex  int           `json:"test_case_index"` // 测试点索引
+	Status         JudgeStatus   `json:"status"`          // 测试点状态
+	TimeUsed       time.Duration `json:"time_used"`       // 实际运行时间
+	MemUsed        uint64        `json:"mem_used"`        // 实际内存使用
+	Output         string        `json:"output"`          // 程序输出
+	Expected       string        `json:"expected"`        // 期望输出
+	Error          string        `json:"error"`           // 错误信息
+	CheckerMessage string        `json:"checker_message"` // 特殊评测程序输出信息
+	ScoreRatio     float64       `json:"score_ratio"`     // 得分比例（0-1），由状态或checker/交互程序给出
+	Score          float64       `json:"score"`           // 测试点得分
+	Diff           *OutputDiff   `json:"diff,omitempty"`  // 答案错误时的第一处差异（按题目配置公开）
+}
+
+// OutputDiff 程序输出与期望输出的第一处差异
+type OutputDiff struct {
+	Line       int    `json:"line"`        // 程序输出中不同之处所在行（从1开始）
+	Column     int    `json:"column"`      // 程序输出中不同之处所在列（从1开始）
+	TokenIndex int    `json:"token_index"` // 不同的词的序号（从1开始）
+	Expected   string `json:"expected"`    // 期望输出中不同之处附近的片段
+	Actual     string `json:"actual"`      // 程序输出中不同之处附近的片段
+}
+
+// SubtaskResult 子任务结果
+type SubtaskResult struct {
+	Name      string      `json:"name"`       // 子任务名称
+	Status    JudgeStatus `json:"status"`     // 子任务状态（所含测试点中优先级最高的状态）
+	Score     float64     `json:"score"`      // 子任务得分
+	FullScore int         `json:"full_score"` // 子任务满分
+	TestCases []int       `json:"test_cases"` // 包含的测试点索引
 }
 
 // JudgeResult 完整评测结果
 type JudgeResult struct {
-	TaskID        int64            `json:"task_id"`         // 对应任务ID
-	Status        JudgeStatus      `json:"status"`          // 最终评测状态
-	TotalScore    int              `json:"total_score"`     // 总得分
-	TotalTimeUsed time.Duration    `json:"total_time_used"` // 总耗时
-	TotalMemUsed  uint64           `json:"total_mem_used"`  // 最大内存使用
-	CompileResult CompileResult    `json:"compile_result"`  // 编译结果
-	TestResults   []TestCaseResult `json:"test_results"`    // 所有测试点结果
-	CodeFileID    int              `json:"code_file_id"`    // 代码文件ID
-	SubmitTime    time.Time        `json:"submit_time"`     // 提交时间
-	JudgeTime     time.Time        `json:"judge_time"`      // 评测完成时间
-	Error         string           `json:"error"`           // 评测错误信息
+	TaskID         int64            `json:"task_id"`         // 对应任务ID
+	Status         JudgeStatus      `json:"status"`          // 最终评测状态
+	TotalScore     float64          `json:"total_score"`     // 总得分（各测试点得分之和）
+	TotalTimeUsed  time.Duration    `json:"total_time_used"` // 总耗时
+	TotalMemUsed   uint64           `json:"total_mem_used"`  // 最大内存使用
+	CompileResult  CompileResult    `json:"compile_result"`  // 编译结果
+	TestResults    []TestCaseResult `json:"test_results"`    // 所有测试点结果
+	SubtaskResults []SubtaskResult  `json:"subtask_results"` // 子任务结果（未配置子任务时为空）
+	CodeFileID     int              `json:"code_file_id"`    // 代码文件ID
+	SubmitTime     time.Time        `json:"submit_time"`     // 提交时间
+	JudgeTime      time.Time        `json:"judge_time"`      // 评测完成时间
+	Error          string           `json:"error"`           // 评测错误信息
+}
+
+// CheckerResult 特殊评测程序（checker）运行结果
+type CheckerResult struct {
+	ExitCode int    `json:"exit_code"` // checker退出码
+	Message  string `json:"message"`   // checker输出信息（testlib写入stderr）
+	Error    string `json:"error"`     // 沙箱错误信息（非空表示checker未能正常运行）
 }
 
 type RunResult struct {
@@ -1,12 +1,33 @@
 package model
 
+import "time"
+
 // TestCase 单个测试用例
 type TestCase struct {
 	InputFile  string `json:"input_file"`  // 输入数据文件路径
 	OutputFile string `json:"output_file"` // 期望输出文件路径
 	Input      string `json:"input"`       // 输入数据
 	Output     string `json:"output"`      // 期望输出
-	Score      int    `json:"score"`       // 测试点分值
+	Score      int    `json:"score"`       // 测试点分值（满分）
+	Sample     bool   `json:"sample"`      // 是否为样例测试点
+}
+
+// SubtaskPolicy 子任务计分策略
+type SubtaskPolicy = string
+
+const (
+	SubtaskPolicyMin      SubtaskPolicy = "min"       // 全部测试点通过才得分（默认）
+	SubtaskPolicySum      SubtaskPolicy = "sum"       // 按测试点分值加权平均测试点得分比例计分
+	SubtaskPolicyMinRatio SubtaskPolicy = "min_ratio" // 按测试点最低得分比例计分
+)
+
+// Subtask 子任务（测试点分组）
+type Subtask struct {
+	Name         string        `json:"name"`         // 子任务名称（唯一）
+	Score        int           `json:"score"`        // 子任务分值
+	Policy       SubtaskPolicy `json:"policy"`       // 计分策略
+	TestCases    []int         `json:"test_cases"`   // 包含的测试点索引
+	Dependencies []string      `json:"dependencies"` // 依赖的子任务名称，依赖未全部通过时跳过本子任务
 }
 
 // JudgeTask 完整评测任务
@@ -19,10 +40,14 @@ type JudgeTask struct {
 	Code                string     `json:"code"`                   // 用户代码
 	Config              TaskConfig `json:"config"`                 // 评测配置
 	TestCases           []TestCase `json:"test_cases"`             // 测试用例列表
+	Subtasks            []Subtask  `json:"subtasks"`               // 子任务列表（可选）
 	FileBucket          string     `json:"file_bucket"`            // 文件存储桶名称
+	ProblemID           string     `json:"problem_id"`             // 题目包ID（可选，引用题目包时测试数据为包内文件路径）
+	ProblemVersion      string     `json:"problem_version"`        // 题目包版本
 	SpecialCode         *string    `json:"special_code"`           // 特殊评测代码（可选）
 	SpecialCodeFileName *string    `json:"special_code_file_name"` // 特殊评测代码文件名（可选）
 	CreateTime          int64      `json:"create_time"`            // 任务创建时间戳
+	CallbackURL         string     `json:"callback_url"`           // 评测完成后的回调地址（可选，仅异步评测）
 }
 
 type RunParams struct {
@@ -30,13 +55,42 @@ type RunParams struct {
 	TestCaseIndex  int        `json:"test_case_index"`  // 测试用例索引
 	ExePath        string     `json:"exe_path"`         // 可执行文件路径
 	Input          string     `json:"input"`            // 输入数据
-	InputFile      string     `json:"input_file"`       // 输入数据文件路径
+	InputFile      string     `json:"input_file"`       // 输入数据文件路径（只读引用，沙箱挂载到/data，不得修改）
 	TimeLimit      int64      `json:"time_limit"`       // 时间限制（秒）
 	MemLimit       int64      `json:"mem_limit"`        // 内存限制（字节）
 	StackLimit     int64      `json:"stack_limit"`      // 栈限制（字节）
+	OutputLimit    int64      `json:"output_limit"`     // 输出大小限制（字节，0表示使用默认限制）
+	OutputFile     string     `json:"output_file"`      // 保存程序完整输出的文件路径（可选，设置后结果中只保留输出预览）
 	Config         TaskConfig `json:"config"`           // 评测配置
 	SpecialExePath string     `json:"special_exe_path"` // 特殊评测代码可执行文件路径（可选）
 	Code           string     `json:"code"`             // 用户代码
 	UserOut        string     `json:"user_out"`         // 用户输出
 	Answer         string     `json:"answer"`           // 期望输出
+	UserOutFile    string     `json:"user_out_file"`    // 用户输出文件路径（特殊评测使用）
+	AnswerFile     string     `json:"answer_file"`      // 期望输出文件路径（特殊评测使用，只读引用）
+}
+
+// TaskState 评测任务所处阶段
+type TaskState = string
+
+const (
+	TaskStatePending  TaskState = "PENDING"  // 排队等待评测
+	TaskStateRunning  TaskState = "RUNNING"  // 评测中
+	TaskStateFinished TaskState = "FINISHED" // 评测完成（结果见Result）
+	TaskStateFailed   TaskState = "FAILED"   // 评测失败（如超时、系统异常，原因见Error）
+)
+
+// TaskRecord 异步评测任务记录
+type TaskRecord struct {
+	TaskID     int64        `json:"task_id"`     // 任务唯一标识
+	State      TaskState    `json:"state"`       // 任务阶段
+	Result     *JudgeResult `json:"result"`      // 评测结果（评测完成后非空）
+	Error      string       `json:"error"`       // 评测失败原因
+	CreateTime time.Time    `json:"create_time"` // 提交时间
+	UpdateTime time.Time    `json:"update_time"` // 最后更新时间
+}
+
+// IsDone 任务是否已结束（完成或失败）
+func (r *TaskRecord) IsDone() bool {
+	return r.State == TaskStateFinished || r.State == TaskStateFailed
 }
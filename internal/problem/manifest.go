@@ -0,0 +1,240 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestNames 题目包清单文件名，按顺序查找
+var ManifestNames = []string{"problem.yaml", "problem.yml", "problem.json"}
+
+// Manifest 题目包清单，字段含义与评测请求一致，文件路径均相对于题目包根目录
+type Manifest struct {
+	Name        string       `yaml:"name" json:"name"`                   // 题目名称
+	Version     string       `yaml:"version" json:"version"`             // 题目包版本（导入时必填，同一题目的版本不可覆盖）
+	CPULimit    int64        `yaml:"cpu_limit" json:"cpu_limit"`         // 时间限制（毫秒）
+	MemLimit    int64        `yaml:"mem_limit" json:"mem_limit"`         // 内存限制（字节）
+	JudgeType   string       `yaml:"judge_type" json:"judge_type"`       // normal（默认）/special/interactive
+	JudgeMode   string       `yaml:"judge_mode" json:"judge_mode"`       // first_failure/all，为空时使用服务端默认配置
+	CompareMode string       `yaml:"compare_mode" json:"compare_mode"`   // strict/line/token（默认）/float
+	FloatEps    *float64     `yaml:"float_eps" json:"float_eps"`         // float模式的绝对误差，未指定时默认1e-6，为0时不按绝对误差比较
+	FloatRelEps *float64     `yaml:"float_rel_eps" json:"float_rel_eps"` // float模式的相对误差，未指定时默认1e-6，为0时不按相对误差比较
+	RevealDiff  string       `yaml:"reveal_diff" json:"reveal_diff"`     // none（默认）/sample/all
+	Checker     string       `yaml:"checker" json:"checker"`             // 特殊评测程序源码（judge_type为special时必填）
+	Interactor  string       `yaml:"interactor" json:"interactor"`       // 交互程序源码（judge_type为interactive时必填）
+	CheckPoints []CheckPoint `yaml:"check_points" json:"check_points"`   // 测试点
+	Subtasks    []Subtask    `yaml:"subtasks" json:"subtasks"`           // 子任务（可选）
+}
+
+// CheckPoint 题目包中的测试点
+type CheckPoint struct {
+	Input  string `yaml:"input" json:"input"`   // 输入文件
+	Output string `yaml:"output" json:"output"` // 期望输出文件
+	Score  int    `yaml:"score" json:"score"`   // 测试点分值，全部为0时平均分配100分
+	Sample bool   `yaml:"sample" json:"sample"` // 是否为样例测试点
+}
+
+// Subtask 题目包中的子任务
+type Subtask struct {
+	Name         string   `yaml:"name" json:"name"`
+	Score        int      `yaml:"score" json:"score"`
+	Policy       string   `yaml:"policy" json:"policy"`             // min（默认）/sum/min_ratio
+	CheckPoints  []int    `yaml:"check_points" json:"check_points"` // 测试点在check_points中的下标
+	Dependencies []string `yaml:"dependencies" json:"dependencies"` // 依赖的子任务名称（须在本子任务之前声明）
+}
+
+// Package 已加载的题目包
+type Package struct {
+	ID       string    // 题目包ID
+	Version  string    // 题目包版本
+	Dir      string    // 题目包根目录
+	Manifest *Manifest // 题目包清单
+}
+
+// LoadDir 从目录加载题目包并校验清单引用的文件
+func LoadDir(dir string) (*Package, error) {
+	manifestPath, err := findManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := parseManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.validate(dir); err != nil {
+		return nil, err
+	}
+	return &Package{
+		Version:  manifest.Version,
+		Dir:      dir,
+		Manifest: manifest,
+	}, nil
+}
+
+// findManifest 查找目录下的清单文件
+func findManifest(dir string) (string, error) {
+	for _, name := range ManifestNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("题目包缺少清单文件(%s)", ManifestNames[0])
+}
+
+// parseManifest 解析YAML或JSON格式的清单，未知字段视为错误
+func parseManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取题目包清单失败: %w", err)
+	}
+	manifest := &Manifest{}
+	if filepath.Ext(path) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(manifest)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析题目包清单失败: %w", err)
+	}
+	return manifest, nil
+}
+
+// validate 校验清单，引用的文件必须是题目包内的普通文件
+func (m *Manifest) validate(dir string) error {
+	switch m.JudgeType {
+	case "", model.JudgeNormal:
+	case model.JudgeSpecial:
+		if m.Checker == "" {
+			return fmt.Errorf("特殊评测题目必须提供checker")
+		}
+	case model.JudgeInteractive:
+		if m.Interactor == "" {
+			return fmt.Errorf("交互题必须提供interactor")
+		}
+	default:
+		return fmt.Errorf("评测类型无效: %s", m.JudgeType)
+	}
+	for _, file := range []string{m.Checker, m.Interactor} {
+		if file == "" {
+			continue
+		}
+		if err := checkFile(dir, file); err != nil {
+			return err
+		}
+	}
+
+	if len(m.CheckPoints) == 0 {
+		return fmt.Errorf("测试用例不能为空")
+	}
+	for i, cp := range m.CheckPoints {
+		if cp.Score < 0 {
+			return fmt.Errorf("测试点%d分值无效: %d", i, cp.Score)
+		}
+		if err := checkFile(dir, cp.Input); err != nil {
+			return fmt.Errorf("测试点%d: %w", i, err)
+		}
+		if err := checkFile(dir, cp.Output); err != nil {
+			return fmt.Errorf("测试点%d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkFile 检查相对路径指向题目包内的普通文件
+func checkFile(dir, name string) error {
+	if name == "" {
+		return fmt.Errorf("文件路径不能为空")
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("文件路径无效: %s", name)
+	}
+	info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return fmt.Errorf("文件不存在: %s", name)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("不是普通文件: %s", name)
+	}
+	return nil
+}
+
+// path 返回题目包内文件的绝对路径
+func (p *Package) path(name string) string {
+	return filepath.Join(p.Dir, filepath.FromSlash(name))
+}
+
+// JudgeTask 将题目包转换为评测任务
+// 测试数据为包内文件的路径；代码、语言、任务ID等由提交方填写，评测模式等配置的默认值与校验由调用方处理
+func (p *Package) JudgeTask() (*model.JudgeTask, error) {
+	m := p.Manifest
+	config := model.DefaultTaskConfig
+	config.TimeLimit = int(m.CPULimit)
+	config.MemoryLimit = int(m.MemLimit)
+	config.JudgeType = m.JudgeType
+	if config.JudgeType == "" {
+		config.JudgeType = model.JudgeNormal
+	}
+	config.JudgeMode = m.JudgeMode
+	config.CompareMode = m.CompareMode
+	if m.FloatEps != nil {
+		config.FloatEps = *m.FloatEps
+	}
+	if m.FloatRelEps != nil {
+		config.FloatRelEps = *m.FloatRelEps
+	}
+	config.RevealDiff = m.RevealDiff
+
+	task := &model.JudgeTask{
+		Config:         config,
+		ProblemID:      p.ID,
+		ProblemVersion: p.Version,
+	}
+
+	special := m.Checker
+	if config.JudgeType == model.JudgeInteractive {
+		special = m.Interactor
+	}
+	if config.JudgeType != model.JudgeNormal && special != "" {
+		code, err := os.ReadFile(p.path(special))
+		if err != nil {
+			return nil, fmt.Errorf("读取评测程序代码失败: %w", err)
+		}
+		specialCode, specialName := string(code), filepath.Base(special)
+		task.SpecialCode = &specialCode
+		task.SpecialCodeFileName = &specialName
+	}
+
+	for _, cp := range m.CheckPoints {
+		task.TestCases = append(task.TestCases, model.TestCase{
+			InputFile:  p.path(cp.Input),
+			OutputFile: p.path(cp.Output),
+			Score:      cp.Score,
+			Sample:     cp.Sample,
+		})
+	}
+	for _, st := range m.Subtasks {
+		policy := st.Policy
+		if policy == "" {
+			policy = model.SubtaskPolicyMin
+		}
+		task.Subtasks = append(task.Subtasks, model.Subtask{
+			Name:         st.Name,
+			Score:        st.Score,
+			Policy:       policy,
+			TestCases:    st.CheckPoints,
+			Dependencies: st.Dependencies,
+		})
+	}
+	return task, nil
+}
@@ -0,0 +1,259 @@
+package problem
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hitwh-judge/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testManifest = `name: A+B
+version: v1
+cpu_limit: 1000
+mem_limit: 67108864
+judge_type: special
+compare_mode: line
+float_rel_eps: 0
+reveal_diff: sample
+checker: checker.cpp
+check_points:
+  - {input: data/1.in, output: data/1.out, sample: true}
+  - {input: data/2.in, output: data/2.out, score: 60}
+subtasks:
+  - {name: all, score: 100, check_points: [0, 1]}
+`
+
+// writeFiles 在目录下写入文件，键为相对路径
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testFiles 返回一个完整题目包的文件
+func testFiles(manifestName, manifest string) map[string]string {
+	return map[string]string{
+		manifestName:  manifest,
+		"checker.cpp": "int main() {}",
+		"data/1.in":   "1 2",
+		"data/1.out":  "3",
+		"data/2.in":   "2 3",
+		"data/2.out":  "5",
+	}
+}
+
+// zipFiles 将文件打包为zip
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadDir(t *testing.T) {
+	jsonManifest := `{"version": "v2", "cpu_limit": 2000, "mem_limit": 1048576,
+		"check_points": [{"input": "data/1.in", "output": "data/1.out"}]}`
+
+	tests := []struct {
+		name         string
+		manifestName string
+		manifest     string
+		wantErr      string
+		wantCases    int
+	}{
+		{name: "YAML清单", manifestName: "problem.yaml", manifest: testManifest, wantCases: 2},
+		{name: "JSON清单", manifestName: "problem.json", manifest: jsonManifest, wantCases: 1},
+		{name: "缺少清单", manifestName: "readme.txt", manifest: testManifest, wantErr: "缺少清单文件"},
+		{name: "未知字段", manifestName: "problem.yaml", manifest: "time_limit: 1\n", wantErr: "解析题目包清单失败"},
+		{name: "测试点为空", manifestName: "problem.yaml", manifest: "cpu_limit: 1000\n", wantErr: "测试用例不能为空"},
+		{name: "测试文件不存在", manifestName: "problem.yaml",
+			manifest: "check_points: [{input: data/3.in, output: data/3.out}]\n", wantErr: "文件不存在"},
+		{name: "路径越出题目包", manifestName: "problem.yaml",
+			manifest: "check_points: [{input: ../1.in, output: data/1.out}]\n", wantErr: "文件路径无效"},
+		{name: "特殊评测缺少checker", manifestName: "problem.yaml",
+			manifest: "judge_type: special\ncheck_points: [{input: data/1.in, output: data/1.out}]\n", wantErr: "必须提供checker"},
+		{name: "评测类型无效", manifestName: "problem.yaml",
+			manifest: "judge_type: io2\ncheck_points: [{input: data/1.in, output: data/1.out}]\n", wantErr: "评测类型无效"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, testFiles(tt.manifestName, tt.manifest))
+			pkg, err := LoadDir(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadDir() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDir() error = %v", err)
+			}
+			if len(pkg.Manifest.CheckPoints) != tt.wantCases {
+				t.Errorf("LoadDir() check points = %d, want %d", len(pkg.Manifest.CheckPoints), tt.wantCases)
+			}
+		})
+	}
+}
+
+func TestPackage_JudgeTask(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, testFiles("problem.yaml", testManifest))
+	pkg, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.ID = "aplusb"
+
+	task, err := pkg.JudgeTask()
+	if err != nil {
+		t.Fatalf("JudgeTask() error = %v", err)
+	}
+	if task.ProblemID != "aplusb" || task.ProblemVersion != "v1" {
+		t.Errorf("JudgeTask() problem = %s@%s, want aplusb@v1", task.ProblemID, task.ProblemVersion)
+	}
+	config := task.Config
+	if config.TimeLimit != 1000 || config.MemoryLimit != 67108864 || config.JudgeType != model.JudgeSpecial ||
+		config.CompareMode != model.CompareModeLine || config.RevealDiff != model.DiffRevealSample {
+		t.Errorf("JudgeTask() config = %+v", config)
+	}
+	// 未指定的误差取默认值，指定为0的误差保持为0（不按该种误差比较）
+	if config.FloatEps != model.DefaultFloatEps || config.FloatRelEps != 0 {
+		t.Errorf("JudgeTask() float eps = %v, %v, want %v, 0", config.FloatEps, config.FloatRelEps, model.DefaultFloatEps)
+	}
+	if task.SpecialCode == nil || *task.SpecialCode != "int main() {}" || *task.SpecialCodeFileName != "checker.cpp" {
+		t.Errorf("JudgeTask() special code = %v", task.SpecialCode)
+	}
+	if len(task.TestCases) != 2 {
+		t.Fatalf("JudgeTask() test cases = %d, want 2", len(task.TestCases))
+	}
+	if got := task.TestCases[0]; got.InputFile != filepath.Join(dir, "data", "1.in") || !got.Sample {
+		t.Errorf("JudgeTask() test case 0 = %+v", got)
+	}
+	if got := task.TestCases[1]; got.Score != 60 || got.Sample {
+		t.Errorf("JudgeTask() test case 1 = %+v", got)
+	}
+	if len(task.Subtasks) != 1 || task.Subtasks[0].Policy != model.SubtaskPolicyMin {
+		t.Errorf("JudgeTask() subtasks = %+v", task.Subtasks)
+	}
+}
+
+func TestStore_Import(t *testing.T) {
+	root := t.TempDir()
+	s := NewStore(root)
+
+	// 清单位于唯一的顶层目录中
+	files := make(map[string]string)
+	for name, content := range testFiles("problem.yaml", testManifest) {
+		files["aplusb/"+name] = content
+	}
+	data := zipFiles(t, files)
+	pkg, err := s.Import("aplusb", bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if pkg.ID != "aplusb" || pkg.Version != "v1" || pkg.Dir != filepath.Join(root, "aplusb", "v1") {
+		t.Errorf("Import() = %+v", pkg)
+	}
+
+	// 同一版本不可覆盖
+	if _, err := s.Import("aplusb", bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Import() same version error = nil, want error")
+	}
+
+	// 新版本成为当前版本，旧版本仍可按版本号加载
+	v2 := zipFiles(t, testFiles("problem.yaml", strings.Replace(testManifest, "version: v1", "version: v2", 1)))
+	if _, err := s.Import("aplusb", bytes.NewReader(v2), int64(len(v2))); err != nil {
+		t.Fatalf("Import() v2 error = %v", err)
+	}
+	if pkg, err := s.Load("aplusb", ""); err != nil || pkg.Version != "v2" {
+		t.Errorf("Load(current) = %+v, %v, want v2", pkg, err)
+	}
+	if pkg, err := s.Load("aplusb", "v1"); err != nil || pkg.Version != "v1" {
+		t.Errorf("Load(v1) = %+v, %v, want v1", pkg, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "aplusb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".import-") {
+			t.Errorf("临时目录未清理: %s", e.Name())
+		}
+	}
+}
+
+func TestStore_ImportInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		files   map[string]string
+		wantErr string
+	}{
+		{name: "路径穿越", id: "p1", files: map[string]string{"../evil": "x"}, wantErr: "非法路径"},
+		{name: "缺少版本", id: "p2",
+			files: testFiles("problem.yaml", strings.Replace(testManifest, "version: v1\n", "", 1)), wantErr: "必须指定version"},
+		{name: "ID无效", id: "../p3", files: testFiles("problem.yaml", testManifest), wantErr: "题目包ID无效"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			data := zipFiles(t, tt.files)
+			_, err := NewStore(root).Import(tt.id, bytes.NewReader(data), int64(len(data)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Import() error = %v, want containing %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil")); err == nil {
+				t.Error("Import() wrote outside the package directory")
+			}
+		})
+	}
+}
+
+func TestStore_Load(t *testing.T) {
+	root := t.TempDir()
+	// 不分版本的本地题目包
+	writeFiles(t, filepath.Join(root, "local"), testFiles("problem.yaml", testManifest))
+	s := NewStore(root)
+
+	pkg, err := s.Load("local", "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if pkg.ID != "local" || pkg.Dir != filepath.Join(root, "local") {
+		t.Errorf("Load() = %+v", pkg)
+	}
+
+	if _, err := s.Load("missing", ""); !errors.Is(err, ErrProblemNotFound) {
+		t.Errorf("Load(missing) error = %v, want ErrProblemNotFound", err)
+	}
+	if _, err := s.Load("..", ""); err == nil {
+		t.Error("Load(..) error = nil, want error")
+	}
+}
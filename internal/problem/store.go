@@ -0,0 +1,233 @@
+package problem
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrProblemNotFound 题目包不存在
+var ErrProblemNotFound = errors.New("题目包不存在")
+
+// DefaultDir 题目包默认存放目录
+const DefaultDir = "./problems"
+
+// 导入限制
+const (
+	MaxPackageSize  = 1024 * 1024 * 1024 // 解压后的最大总大小（字节）
+	MaxPackageFiles = 10000              // 最多包含的文件数
+)
+
+// currentFile 记录题目当前版本的文件名
+const currentFile = "current"
+
+// namePattern 题目包ID与版本号的合法格式
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Store 本地文件系统上的题目包存储
+//
+// 目录结构为 <root>/<id>/<version>/，<root>/<id>/current 记录当前版本；
+// 也可以直接将清单放在 <root>/<id>/ 下作为不分版本的本地题目包。
+type Store struct {
+	root string
+	mu   sync.Mutex // 串行化导入
+}
+
+// NewStore 创建题目包存储
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+// validateName 校验题目包ID或版本号，防止路径穿越
+func validateName(kind, name string) error {
+	if !namePattern.MatchString(name) || len(name) > 128 {
+		return fmt.Errorf("%s无效: %q", kind, name)
+	}
+	return nil
+}
+
+// Load 加载题目包，version为空时加载当前版本
+func (s *Store) Load(id, version string) (*Package, error) {
+	if err := validateName("题目包ID", id); err != nil {
+		return nil, err
+	}
+	problemDir := filepath.Join(s.root, id)
+	if _, err := os.Stat(problemDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrProblemNotFound, id)
+	}
+
+	dir := problemDir
+	if _, err := findManifest(problemDir); err != nil {
+		// 分版本的题目包
+		if version == "" {
+			data, err := os.ReadFile(filepath.Join(problemDir, currentFile))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s没有可用版本", ErrProblemNotFound, id)
+			}
+			version = strings.TrimSpace(string(data))
+		}
+		if err := validateName("题目包版本", version); err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(problemDir, version)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s@%s", ErrProblemNotFound, id, version)
+		}
+	}
+
+	pkg, err := LoadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("加载题目包%s失败: %w", id, err)
+	}
+	if dir == problemDir && version != "" && version != pkg.Version {
+		return nil, fmt.Errorf("%w: %s@%s", ErrProblemNotFound, id, version)
+	}
+	pkg.ID = id
+	if pkg.Version == "" {
+		pkg.Version = version
+	}
+	return pkg, nil
+}
+
+// Import 导入zip格式的题目包，作为该题目的新版本并设为当前版本
+// 清单可以位于压缩包根目录，也可以位于唯一的顶层目录中；已存在的版本不可覆盖
+func (s *Store) Import(id string, r io.ReaderAt, size int64) (*Package, error) {
+	if err := validateName("题目包ID", id); err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("读取题目包压缩文件失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	problemDir := filepath.Join(s.root, id)
+	if _, err := findManifest(problemDir); err == nil {
+		return nil, fmt.Errorf("题目包%s为本地目录，不能导入", id)
+	}
+	if err := os.MkdirAll(problemDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建题目包目录失败: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(problemDir, ".import-")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := extractZip(zr, tmpDir); err != nil {
+		return nil, err
+	}
+	pkgDir := packageRoot(tmpDir)
+	pkg, err := LoadDir(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Version == "" {
+		return nil, fmt.Errorf("导入的题目包清单必须指定version")
+	}
+	if err := validateName("题目包版本", pkg.Version); err != nil {
+		return nil, err
+	}
+
+	versionDir := filepath.Join(problemDir, pkg.Version)
+	if _, err := os.Stat(versionDir); err == nil {
+		return nil, fmt.Errorf("题目包%s的版本%s已存在", id, pkg.Version)
+	}
+	if err := os.Rename(pkgDir, versionDir); err != nil {
+		return nil, fmt.Errorf("保存题目包失败: %w", err)
+	}
+	if err := writeCurrent(problemDir, pkg.Version); err != nil {
+		return nil, err
+	}
+	return s.Load(id, pkg.Version)
+}
+
+// packageRoot 返回解压目录中清单所在的目录（根目录或唯一的顶层目录）
+func packageRoot(dir string) string {
+	if _, err := findManifest(dir); err == nil {
+		return dir
+	}
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
+
+// writeCurrent 原子地更新当前版本
+func writeCurrent(problemDir, version string) error {
+	tmp := filepath.Join(problemDir, currentFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(version+"\n"), 0644); err != nil {
+		return fmt.Errorf("更新题目包当前版本失败: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(problemDir, currentFile)); err != nil {
+		return fmt.Errorf("更新题目包当前版本失败: %w", err)
+	}
+	return nil
+}
+
+// extractZip 解压到目录，拒绝路径穿越、符号链接以及超过大小或数量限制的压缩包
+func extractZip(zr *zip.Reader, dir string) error {
+	if len(zr.File) > MaxPackageFiles {
+		return fmt.Errorf("题目包文件过多: %d (最多%d个)", len(zr.File), MaxPackageFiles)
+	}
+	remaining := int64(MaxPackageSize)
+	for _, f := range zr.File {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("题目包包含非法路径: %s", f.Name)
+		}
+		target := filepath.Join(dir, name)
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("解压题目包失败: %w", err)
+			}
+			continue
+		case !mode.IsRegular():
+			return fmt.Errorf("题目包只能包含普通文件: %s", f.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("解压题目包失败: %w", err)
+		}
+		n, err := extractFile(f, target, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// extractFile 解压单个文件，最多写入limit字节
+func extractFile(f *zip.File, target string, limit int64) (int64, error) {
+	src, err := f.Open()
+	if err != nil {
+		return 0, fmt.Errorf("解压题目包失败: %w", err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("解压题目包失败: %w", err)
+	}
+	defer dst.Close()
+
+	n, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	if err != nil {
+		return n, fmt.Errorf("解压题目包失败: %w", err)
+	}
+	if n > limit {
+		return n, fmt.Errorf("题目包解压后超过大小限制(%dMB)", MaxPackageSize/1024/1024)
+	}
+	return n, nil
+}
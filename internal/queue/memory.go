@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"sync"
+	"time"
+)
+
+// MemoryQueue 进程内评测队列，语义与 RedisQueue 一致，主要用于单机部署与测试
+// 与 RedisQueue 一样保存任务的序列化内容，每次投递都得到独立的副本，消费者修改任务不会影响重新投递
+type MemoryQueue struct {
+	opts Options
+
+	mu         sync.Mutex
+	pending    []int64             // 等待处理的任务ID（先进先出）
+	tasks      map[int64][]byte    // 任务内容（JSON）
+	attempts   map[int64]int       // 投递次数
+	processing map[int64]time.Time // 处理中的任务及其可见性截止时间
+	notify     chan struct{}       // 新任务通知
+}
+
+// NewMemoryQueue 创建进程内评测队列
+func NewMemoryQueue(opts Options) *MemoryQueue {
+	return &MemoryQueue{
+		opts:       opts.normalize(),
+		tasks:      make(map[int64][]byte),
+		attempts:   make(map[int64]int),
+		processing: make(map[int64]time.Time),
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+// Enqueue 任务入队
+func (q *MemoryQueue) Enqueue(_ context.Context, task *model.JudgeTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("序列化评测任务失败: %w", err)
+	}
+
+	q.mu.Lock()
+	q.tasks[task.TaskID] = data
+	q.pending = append(q.pending, task.TaskID)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dequeue 阻塞获取一个任务
+func (q *MemoryQueue) Dequeue(ctx context.Context) (*Delivery, error) {
+	for {
+		d, err := q.tryDequeue(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			return d, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.notify:
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
+
+// tryDequeue 重新入队超时任务后尝试取出一个任务，队列为空时返回nil
+func (q *MemoryQueue) tryDequeue(now time.Time) (*Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id, deadline := range q.processing {
+		if now.After(deadline) {
+			delete(q.processing, id)
+			// 超时任务放回队首，优先重新处理
+			q.pending = append([]int64{id}, q.pending...)
+		}
+	}
+
+	if len(q.pending) == 0 {
+		return nil, nil
+	}
+	id := q.pending[0]
+	q.pending = q.pending[1:]
+	q.processing[id] = now.Add(q.opts.VisibilityTimeout)
+	q.attempts[id]++
+
+	var task model.JudgeTask
+	if err := json.Unmarshal(q.tasks[id], &task); err != nil {
+		return nil, fmt.Errorf("解析评测任务失败: %w", err)
+	}
+	return &Delivery{Task: &task, Attempts: q.attempts[id]}, nil
+}
+
+// Ack 确认任务处理完成
+func (q *MemoryQueue) Ack(_ context.Context, d *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	id := d.Task.TaskID
+	delete(q.processing, id)
+	delete(q.tasks, id)
+	delete(q.attempts, id)
+	return nil
+}
+
+// Extend 延长任务的可见性超时
+func (q *MemoryQueue) Extend(_ context.Context, d *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.processing[d.Task.TaskID]; ok {
+		q.processing[d.Task.TaskID] = time.Now().Add(q.opts.VisibilityTimeout)
+	}
+	return nil
+}
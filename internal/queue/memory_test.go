@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"hitwh-judge/internal/model"
+	"testing"
+	"time"
+)
+
+func TestMemoryQueue_FIFO(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(Options{})
+
+	for i := int64(1); i <= 3; i++ {
+		if err := q.Enqueue(ctx, &model.JudgeTask{TaskID: i}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	for want := int64(1); want <= 3; want++ {
+		d, err := q.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Dequeue() error = %v", err)
+		}
+		if d.Task.TaskID != want || d.Attempts != 1 {
+			t.Errorf("Dequeue() = (task %d, attempts %d), want (task %d, attempts 1)", d.Task.TaskID, d.Attempts, want)
+		}
+		if err := q.Ack(ctx, d); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+}
+
+func TestMemoryQueue_DequeueCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	q := NewMemoryQueue(Options{PollInterval: 5 * time.Millisecond})
+	if _, err := q.Dequeue(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dequeue() on empty queue error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestMemoryQueue_VisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(Options{VisibilityTimeout: time.Minute})
+	_ = q.Enqueue(ctx, &model.JudgeTask{TaskID: 1})
+
+	now := time.Now()
+	if d, _ := q.tryDequeue(now); d == nil || d.Attempts != 1 {
+		t.Fatalf("tryDequeue() = %+v, want first delivery", d)
+	}
+	// 可见性超时前任务对其他消费者不可见
+	if d, _ := q.tryDequeue(now.Add(30 * time.Second)); d != nil {
+		t.Fatalf("tryDequeue() before timeout = %+v, want nil", d)
+	}
+	// 未确认的任务在超时后重新投递
+	d, _ := q.tryDequeue(now.Add(2 * time.Minute))
+	if d == nil || d.Task.TaskID != 1 || d.Attempts != 2 {
+		t.Fatalf("tryDequeue() after timeout = %+v, want redelivery with attempts 2", d)
+	}
+
+	_ = q.Ack(ctx, d)
+	if d, _ := q.tryDequeue(now.Add(time.Hour)); d != nil {
+		t.Errorf("tryDequeue() after ack = %+v, want nil", d)
+	}
+}
+
+func TestMemoryQueue_Extend(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(Options{VisibilityTimeout: time.Minute})
+	_ = q.Enqueue(ctx, &model.JudgeTask{TaskID: 1})
+
+	d, _ := q.tryDequeue(time.Now().Add(-50 * time.Second))
+	if err := q.Extend(ctx, d); err != nil {
+		t.Fatalf("Extend() error = %v", err)
+	}
+	if d, _ := q.tryDequeue(time.Now().Add(30 * time.Second)); d != nil {
+		t.Errorf("tryDequeue() after extend = %+v, want nil", d)
+	}
+}
+
+func TestMemoryQueue_RedeliveryIsolated(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(Options{VisibilityTimeout: time.Minute})
+	_ = q.Enqueue(ctx, &model.JudgeTask{TaskID: 1, TestCases: []model.TestCase{{InputFile: "md5-in", OutputFile: "md5-out"}}})
+
+	now := time.Now()
+	d, _ := q.tryDequeue(now)
+	// 消费者评测前将测试点改写为本地路径，随后未确认即失败
+	d.Task.TestCases[0].InputFile = "/cache/bucket_md5-in"
+
+	d, _ = q.tryDequeue(now.Add(2 * time.Minute))
+	if d == nil || d.Attempts != 2 {
+		t.Fatalf("tryDequeue() after timeout = %+v, want redelivery with attempts 2", d)
+	}
+	if got := d.Task.TestCases[0].InputFile; got != "md5-in" {
+		t.Errorf("redelivered InputFile = %q, want %q", got, "md5-in")
+	}
+}
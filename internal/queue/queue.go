@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// 队列类型
+const (
+	TypeMemory = "memory" // 进程内队列（默认，重启后任务丢失）
+	TypeRedis  = "redis"  // Redis持久化队列，支持多个评测进程共同消费
+)
+
+// 默认配置
+const (
+	DefaultKeyPrefix         = "judge"
+	DefaultVisibilityTimeout = 10 * time.Minute
+	DefaultPollInterval      = 500 * time.Millisecond
+)
+
+// Delivery 一次任务投递
+// 消费者处理完成后必须调用 Ack，否则可见性超时后任务会被重新投递给其他消费者（至少一次语义）
+type Delivery struct {
+	Task     *model.JudgeTask // 评测任务
+	Attempts int              // 第几次投递（从1开始）
+}
+
+// Queue 评测任务队列
+type Queue interface {
+	// Enqueue 任务入队
+	Enqueue(ctx context.Context, task *model.JudgeTask) error
+	// Dequeue 阻塞获取一个任务，直到有任务可用或ctx结束
+	// 取出的任务在可见性超时时间内对其他消费者不可见
+	Dequeue(ctx context.Context) (*Delivery, error)
+	// Ack 确认任务处理完成，将其从队列中移除
+	Ack(ctx context.Context, d *Delivery) error
+	// Extend 延长任务的可见性超时（心跳），用于耗时较长的评测
+	Extend(ctx context.Context, d *Delivery) error
+}
+
+// Options 队列配置
+type Options struct {
+	KeyPrefix         string        // Redis键前缀
+	VisibilityTimeout time.Duration // 可见性超时时间
+	PollInterval      time.Duration // 队列为空时的轮询间隔
+}
+
+// normalize 填充未设置的配置项
+func (o Options) normalize() Options {
+	if o.KeyPrefix == "" {
+		o.KeyPrefix = DefaultKeyPrefix
+	}
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultPollInterval
+	}
+	return o
+}
+
+// New 根据配置创建评测队列
+// 配置项：queue.type、queue.key_prefix、queue.visibility_timeout（秒）
+func New(cfg *viper.Viper, rdb *redis.Client) (Queue, error) {
+	opts := Options{
+		KeyPrefix:         cfg.GetString("queue.key_prefix"),
+		VisibilityTimeout: time.Duration(cfg.GetInt("queue.visibility_timeout")) * time.Second,
+	}
+
+	switch queueType := cfg.GetString("queue.type"); queueType {
+	case "", TypeMemory:
+		return NewMemoryQueue(opts), nil
+	case TypeRedis:
+		if rdb == nil {
+			return nil, fmt.Errorf("Redis队列需要先初始化Redis连接")
+		}
+		return NewRedisQueue(rdb, opts), nil
+	default:
+		return nil, fmt.Errorf("不支持的队列类型: %s", queueType)
+	}
+}
@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dequeueScript 原子地将超时任务放回队列，并取出一个任务移入处理中集合
+// KEYS: pending, processing, attempts  ARGV: 当前时间（毫秒）、可见性截止时间（毫秒）
+var dequeueScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('RPUSH', KEYS[1], id)
+end
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[2], id)
+local attempts = redis.call('HINCRBY', KEYS[3], id, 1)
+return {id, attempts}
+`)
+
+// RedisQueue 基于Redis的持久化评测队列
+// pending 列表保存待处理任务ID，processing 有序集合以可见性截止时间为分数保存处理中的任务，
+// 任务内容与投递次数分别保存在哈希表中。评测进程崩溃后，其任务在可见性超时后由其他进程重新取出。
+type RedisQueue struct {
+	rdb  *redis.Client
+	opts Options
+
+	pendingKey    string
+	processingKey string
+	tasksKey      string
+	attemptsKey   string
+}
+
+// NewRedisQueue 创建Redis评测队列
+func NewRedisQueue(rdb *redis.Client, opts Options) *RedisQueue {
+	opts = opts.normalize()
+	return &RedisQueue{
+		rdb:           rdb,
+		opts:          opts,
+		pendingKey:    opts.KeyPrefix + ":queue:pending",
+		processingKey: opts.KeyPrefix + ":queue:processing",
+		tasksKey:      opts.KeyPrefix + ":queue:tasks",
+		attemptsKey:   opts.KeyPrefix + ":queue:attempts",
+	}
+}
+
+// Enqueue 任务入队
+func (q *RedisQueue) Enqueue(ctx context.Context, task *model.JudgeTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("序列化评测任务失败: %w", err)
+	}
+
+	id := strconv.FormatInt(task.TaskID, 10)
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.tasksKey, id, data)
+		pipe.LPush(ctx, q.pendingKey, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("评测任务入队失败: %w", err)
+	}
+	return nil
+}
+
+// Dequeue 阻塞获取一个任务
+func (q *RedisQueue) Dequeue(ctx context.Context) (*Delivery, error) {
+	for {
+		d, err := q.tryDequeue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			return d, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
+
+// tryDequeue 尝试取出一个任务，队列为空时返回nil
+func (q *RedisQueue) tryDequeue(ctx context.Context) (*Delivery, error) {
+	now := time.Now()
+	res, err := dequeueScript.Run(ctx, q.rdb,
+		[]string{q.pendingKey, q.processingKey, q.attemptsKey},
+		now.UnixMilli(), now.Add(q.opts.VisibilityTimeout).UnixMilli(),
+	).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("评测任务出队失败: %w", err)
+	}
+
+	id, _ := res[0].(string)
+	attempts, _ := res[1].(int64)
+	data, err := q.rdb.HGet(ctx, q.tasksKey, id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// 任务已被其他消费者确认完成，丢弃残留的处理中记录
+		q.rdb.ZRem(ctx, q.processingKey, id)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取评测任务失败: %w", err)
+	}
+
+	var task model.JudgeTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("解析评测任务失败: %w", err)
+	}
+	return &Delivery{Task: &task, Attempts: int(attempts)}, nil
+}
+
+// Ack 确认任务处理完成
+func (q *RedisQueue) Ack(ctx context.Context, d *Delivery) error {
+	id := strconv.FormatInt(d.Task.TaskID, 10)
+	_, err := q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.processingKey, id)
+		pipe.HDel(ctx, q.tasksKey, id)
+		pipe.HDel(ctx, q.attemptsKey, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("确认评测任务失败: %w", err)
+	}
+	return nil
+}
+
+// Extend 延长任务的可见性超时
+func (q *RedisQueue) Extend(ctx context.Context, d *Delivery) error {
+	deadline := time.Now().Add(q.opts.VisibilityTimeout).UnixMilli()
+	err := q.rdb.ZAddXX(ctx, q.processingKey, redis.Z{
+		Score:  float64(deadline),
+		Member: strconv.FormatInt(d.Task.TaskID, 10),
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("延长评测任务可见性超时失败: %w", err)
+	}
+	return nil
+}
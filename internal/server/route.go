@@ -23,6 +23,8 @@ func SetupRoutes(cfg *viper.Viper) *gin.Engine {
 	corsCfg.AllowHeaders = append(corsCfg.AllowHeaders, "Authorization")
 	corsCfg.AllowAllOrigins = true
 	r.Use(cors.New(corsCfg)) // CORS 跨域中间件，简单粗暴，直接放行所有跨域请求
+	// WebSocket不受CORS限制，单独校验来源，默认只允许同源
+	handler.SetWSAllowedOrigins(cfg.GetString("events.allowed_origins"))
 
 	// 健康检查和监控端点（不需要认证）
 	r.GET("/health", handler.HealthCheckHandler)
@@ -35,6 +37,11 @@ func SetupRoutes(cfg *viper.Viper) *gin.Engine {
 	{
 		apiV1.GET("/add", calc.AddHandler())
 		apiV1.POST("/task/add", handler.AddTaskHandler)
+		apiV1.POST("/task/submit", handler.SubmitTaskHandler)
+		apiV1.GET("/task/:id", handler.GetTaskHandler)
+		apiV1.GET("/task/:id/events", handler.TaskEventsHandler)
+		apiV1.GET("/task/:id/ws", handler.TaskEventsWSHandler)
+		apiV1.POST("/problem/:id", handler.ImportProblemHandler)
 	}
 
 	r.NoRoute(func(c *gin.Context) {
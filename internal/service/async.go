@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	v1 "hitwh-judge/api/calc/v1"
+	"hitwh-judge/internal/dao"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/queue"
+	"hitwh-judge/internal/store"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var (
+	// resultStore 异步评测任务结果存储，默认使用内存存储
+	resultStore store.ResultStore = store.NewMemoryStore(store.DefaultResultTTL)
+	// taskQueue 异步评测任务队列，默认使用进程内队列
+	taskQueue queue.Queue = queue.NewMemoryQueue(queue.Options{})
+)
+
+// MustInitResultStore 根据配置初始化结果存储
+// 使用Redis存储时需先调用 dao.MustInitRedis
+func MustInitResultStore(cfg *viper.Viper) {
+	s, err := store.New(cfg, dao.RedisClient)
+	if err != nil {
+		panic(fmt.Errorf("init result store failed, err:%w", err))
+	}
+	resultStore = s
+}
+
+// MustInitQueue 根据配置初始化评测任务队列
+// 使用Redis队列时需先调用 dao.MustInitRedis
+func MustInitQueue(cfg *viper.Viper) {
+	q, err := queue.New(cfg, dao.RedisClient)
+	if err != nil {
+		panic(fmt.Errorf("init judge queue failed, err:%w", err))
+	}
+	taskQueue = q
+}
+
+// SubmitTask 异步提交评测任务
+// 参数校验通过后任务进入评测队列，立即返回PENDING状态的任务记录，结果通过 GetTask 查询
+func SubmitTask(ctx context.Context, req *v1.TaskReq) (*model.TaskRecord, error) {
+	judgeTask, err := prepareTask(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	record := &model.TaskRecord{
+		TaskID:     judgeTask.TaskID,
+		State:      model.TaskStatePending,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if err := resultStore.Save(ctx, record); err != nil {
+		return nil, fmt.Errorf("保存评测任务失败: %w", err)
+	}
+	if err := taskQueue.Enqueue(ctx, judgeTask); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetTask 查询评测任务状态及结果
+func GetTask(ctx context.Context, taskID int64) (*model.TaskRecord, error) {
+	return resultStore.Get(ctx, taskID)
+}
+
+// saveTaskRecord 更新任务记录，失败时仅记录日志
+func saveTaskRecord(ctx context.Context, record *model.TaskRecord) {
+	record.UpdateTime = time.Now()
+	if err := resultStore.Save(ctx, record); err != nil {
+		zap.L().Error("保存评测任务记录失败",
+			zap.Int64("task_id", record.TaskID),
+			zap.String("state", record.State),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	v1 "hitwh-judge/api/calc/v1"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/queue"
+	"hitwh-judge/internal/store"
+	"hitwh-judge/pkg/snowflake"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetTask(t *testing.T) {
+	ctx := context.Background()
+	defer func(s store.ResultStore) { resultStore = s }(resultStore)
+	resultStore = store.NewMemoryStore(time.Hour)
+
+	if _, err := GetTask(ctx, 1); !errors.Is(err, store.ErrTaskNotFound) {
+		t.Fatalf("GetTask() error = %v, want ErrTaskNotFound", err)
+	}
+
+	saveTaskRecord(ctx, &model.TaskRecord{TaskID: 1, State: model.TaskStateRunning})
+	record, err := GetTask(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetTask() error = %v", err)
+	}
+	if record.State != model.TaskStateRunning || record.UpdateTime.IsZero() {
+		t.Errorf("GetTask() = %+v, want RUNNING with update time", record)
+	}
+}
+
+func TestSubmitTask_InvalidRequest(t *testing.T) {
+	if _, err := SubmitTask(context.Background(), nil); !errors.Is(err, ErrInvalidTask) {
+		t.Errorf("SubmitTask(nil) error = %v, want ErrInvalidTask", err)
+	}
+	req := &v1.TaskReq{CodeFile: "int main(){}", CodeLanguage: "C++", CPULimit: 1000, MemLimit: 64 << 20, Bucket: "cases"}
+	if _, err := SubmitTask(context.Background(), req); !errors.Is(err, ErrInvalidTask) {
+		t.Errorf("SubmitTask(没有测试点) error = %v, want ErrInvalidTask", err)
+	}
+}
+
+// failingStore 保存总是失败的结果存储
+type failingStore struct{ store.ResultStore }
+
+func (failingStore) Save(context.Context, *model.TaskRecord) error {
+	return errors.New("redis: connection refused")
+}
+
+func TestSubmitTask_StoreFailureIsNotInvalid(t *testing.T) {
+	v := viper.New()
+	v.Set("snowflake.start_time", "2024-01-01")
+	snowflake.MustInit(v)
+	defer func(s store.ResultStore) { resultStore = s }(resultStore)
+	resultStore = failingStore{}
+
+	var req v1.TaskReq
+	body := `{"code_file":"int main(){}","code_language":"C++","cpu_limit":1000,"mem_limit":67108864,"bucket":"cases","check_points":[{"input":"1.in","output":"1.out"}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	// 请求本身有效，存储失败属于内部错误，不能提示调用方修改请求
+	_, err := SubmitTask(context.Background(), &req)
+	if err == nil || errors.Is(err, ErrInvalidTask) || !strings.Contains(err.Error(), "保存评测任务失败") {
+		t.Errorf("SubmitTask() error = %v, want internal error", err)
+	}
+}
+
+func TestProcessDelivery_TooManyAttempts(t *testing.T) {
+	ctx := context.Background()
+	defer func(s store.ResultStore, q queue.Queue) { resultStore, taskQueue = s, q }(resultStore, taskQueue)
+	resultStore = store.NewMemoryStore(time.Hour)
+	taskQueue = queue.NewMemoryQueue(queue.Options{})
+
+	_ = taskQueue.Enqueue(ctx, &model.JudgeTask{TaskID: 1})
+	d, err := taskQueue.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Dequeue() error = %v", err)
+	}
+	d.Attempts = MaxDeliveryAttempts + 1
+	processDelivery(ctx, d)
+
+	record, err := GetTask(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetTask() error = %v", err)
+	}
+	if record.State != model.TaskStateFailed {
+		t.Errorf("GetTask() state = %q, want %q", record.State, model.TaskStateFailed)
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hitwh-judge/internal/dao"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/webhook"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// notifier 评测结果回调，默认未配置签名密钥（不接受callback_url）、死信记录保存在内存中
+var notifier = webhook.NewNotifier(webhook.Config{}, webhook.NewMemoryDeadLetterStore())
+
+// MustInitNotifier 根据配置初始化评测结果回调
+func MustInitNotifier(cfg *viper.Viper) {
+	n, err := webhook.New(cfg, dao.RedisClient)
+	if err != nil {
+		panic(fmt.Errorf("init webhook notifier failed, err:%w", err))
+	}
+	if cfg.GetString("webhook.secret") == "" {
+		zap.L().Warn("未配置webhook.secret，携带callback_url的评测请求将被拒绝")
+	}
+	notifier = n
+}
+
+// notifyCallback 将任务最终结果POST到回调地址，在后台重试，不阻塞评测
+func notifyCallback(task *model.JudgeTask, record *model.TaskRecord) {
+	if task.CallbackURL == "" {
+		return
+	}
+
+	payload := record.Result
+	if payload == nil {
+		// 评测失败时同样回调，以SE状态携带失败原因
+		payload = &model.JudgeResult{
+			TaskID:     record.TaskID,
+			Status:     model.StatusSE,
+			Error:      record.Error,
+			SubmitTime: record.CreateTime,
+			JudgeTime:  time.Now(),
+		}
+	}
+
+	go func() {
+		if err := notifier.Deliver(context.Background(), task.CallbackURL, task.TaskID, payload); err != nil {
+			zap.L().Error("评测结果回调最终失败，已记录死信",
+				zap.Int64("task_id", task.TaskID),
+				zap.String("url", task.CallbackURL),
+				zap.Error(err),
+			)
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	v1 "hitwh-judge/api/calc/v1"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestMustInitNotifier_SignsWithConfiguredSecret(t *testing.T) {
+	defer func(n *webhook.Notifier) { notifier = n }(notifier)
+
+	type request struct {
+		body      []byte
+		timestamp string
+		signature string
+	}
+	received := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{body: body, timestamp: r.Header.Get(webhook.TimestampHeader), signature: r.Header.Get(webhook.SignatureHeader)}
+	}))
+	defer srv.Close()
+
+	cfg := viper.New()
+	cfg.Set("webhook.secret", "s3cret")
+	cfg.Set("webhook.max_attempts", 1)
+	cfg.Set("webhook.allowed_hosts", "127.0.0.1")
+	MustInitNotifier(cfg)
+
+	if err := notifier.ValidateURL(srv.URL); err != nil {
+		t.Fatalf("ValidateURL() error = %v", err)
+	}
+	task := &model.JudgeTask{TaskID: 1, CallbackURL: srv.URL}
+	notifyCallback(task, &model.TaskRecord{TaskID: 1, Result: &model.JudgeResult{TaskID: 1, Status: model.StatusAC}})
+
+	select {
+	case req := <-received:
+		if req.signature == "" {
+			t.Fatal("callback has no signature header")
+		}
+		if !webhook.Verify("s3cret", req.timestamp, req.body, req.signature) {
+			t.Errorf("signature %q does not match configured secret", req.signature)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+}
+
+func TestAddTask_RejectsCallback(t *testing.T) {
+	_, err := AddTask(context.Background(), &v1.TaskReq{CallbackURL: "http://example.com/hook"})
+	if !errors.Is(err, ErrCallbackRequiresAsync) {
+		t.Errorf("AddTask() error = %v, want ErrCallbackRequiresAsync", err)
+	}
+}
+
+func TestSubmitTask_RejectsCallbackWithoutSecret(t *testing.T) {
+	defer func(n *webhook.Notifier) { notifier = n }(notifier)
+	cfg := viper.New()
+	cfg.Set("webhook.allowed_hosts", "oj.example.com")
+	MustInitNotifier(cfg)
+
+	_, err := prepareTask(&v1.TaskReq{CodeFile: "int main() {}", CallbackURL: "https://oj.example.com/cb"})
+	if !errors.Is(err, webhook.ErrSecretNotConfigured) {
+		t.Errorf("prepareTask() error = %v, want ErrSecretNotConfigured", err)
+	}
+}
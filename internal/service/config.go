@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"hitwh-judge/internal/cache"
+	"hitwh-judge/internal/conf"
+	"hitwh-judge/internal/dao"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/task/runner"
+	"hitwh-judge/internal/testdata"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// judgeConfig 服务端评测配置，未初始化时使用默认配置
+var judgeConfig = conf.GetDefaultJudgeConfig()
+
+// MustInitJudgeConfig 从配置文件加载评测配置
+// 配置的沙箱类型（含按语言覆盖的沙箱）未注册时panic
+func MustInitJudgeConfig(cfg *viper.Viper) {
+	config := conf.LoadJudgeConfig(cfg)
+	if err := validateSandboxConfig(config); err != nil {
+		panic(fmt.Errorf("init judge config failed, err:%w", err))
+	}
+	judgeConfig = config
+}
+
+// StartSandboxPool 设置box ID耗尽时的等待时间，并为配置中使用的isolate清理遗留的box、启动预热box池
+// 须在 MustInitJudgeConfig 之后调用
+func StartSandboxPool() {
+	runner.SetBoxIDRange(judgeConfig.BoxIDStart, judgeConfig.BoxIDCount)
+	runner.SetBoxWaitTimeout(judgeConfig.SandboxWaitTimeout)
+	for _, path := range isolatePaths(judgeConfig) {
+		runner.InitIsolatePool(path, judgeConfig.SandboxPoolSize, judgeConfig.SandboxHealthCheckInterval)
+	}
+}
+
+// isolatePaths 返回评测配置中用到的isolate可执行文件路径
+// 与 newRunner 一致：按语言覆盖为isolate时使用isolate的默认路径
+func isolatePaths(config *conf.JudgeConfig) []string {
+	isolate := runner.DefaultIsolateSandboxConfig
+	var paths []string
+	if config.Sandbox == isolate.Type {
+		path := config.SandboxPath
+		if path == "" {
+			path = isolate.Path
+		}
+		paths = append(paths, path)
+	}
+	for _, sandbox := range config.SandboxOverrides {
+		if sandbox == isolate.Type && config.Sandbox != isolate.Type && !slices.Contains(paths, isolate.Path) {
+			paths = append(paths, isolate.Path)
+		}
+	}
+	return paths
+}
+
+// MustInitTestFileCache 按缓存配置初始化测试用例缓存，并恢复缓存目录中重启前的缓存
+func MustInitTestFileCache(cfg *viper.Viper) {
+	if err := cache.InitEnhancedTestFileCache(conf.LoadCacheConfig(cfg)); err != nil {
+		panic(fmt.Errorf("init test file cache failed, err:%w", err))
+	}
+}
+
+// MustInitTestDataStore 根据配置初始化测试数据存储
+// 使用MinIO存储时需先调用 dao.MustInitMinIO 与 MustInitTestFileCache
+func MustInitTestDataStore(cfg *viper.Viper) {
+	s, err := testdata.New(cfg, dao.MinIOClient)
+	if err != nil {
+		panic(fmt.Errorf("init test data store failed, err:%w", err))
+	}
+	layout, err := testdata.ParseKeyLayout(cfg.GetString("testdata.key_layout"), cfg.GetString("testdata.key_prefix"))
+	if err != nil {
+		panic(fmt.Errorf("init test data store failed, err:%w", err))
+	}
+	downloader := testdata.NewDownloader(s, layout)
+	if attempts := cfg.GetInt("testdata.max_attempts"); attempts > 0 {
+		downloader.MaxAttempts = attempts
+	}
+	if timeout := cfg.GetInt("testdata.timeout"); timeout > 0 {
+		downloader.Timeout = time.Duration(timeout) * time.Second
+	}
+	cache.GetEnhancedTestFileCache().SetDownloader(downloader)
+}
+
+// validateSandboxConfig 校验沙箱配置
+func validateSandboxConfig(config *conf.JudgeConfig) error {
+	if !runner.IsRegistered(config.Sandbox) {
+		return fmt.Errorf("不支持的沙箱类型: %s", config.Sandbox)
+	}
+	for language, sandbox := range config.SandboxOverrides {
+		if !runner.IsRegistered(sandbox) {
+			return fmt.Errorf("语言%s配置的沙箱类型不支持: %s", language, sandbox)
+		}
+	}
+	return nil
+}
+
+// newRunner 创建运行指定语言程序的沙箱运行器
+// 语言配置了 judge.sandbox_overrides 时使用覆盖的沙箱（路径为该沙箱默认路径），否则使用 judge.sandbox
+func newRunner(language model.LanguageType) (runner.Runner, error) {
+	if sandbox, ok := judgeConfig.SandboxOverrides[language]; ok && sandbox != judgeConfig.Sandbox {
+		return runner.New(sandbox, "")
+	}
+	return runner.New(judgeConfig.Sandbox, judgeConfig.SandboxPath)
+}
+
+// resolveJudgeMode 解析请求指定的测试点运行模式
+// 未指定时根据 judge.enable_early_stop 决定默认模式
+func resolveJudgeMode(mode string) (model.JudgeMode, error) {
+	switch mode {
+	case "":
+		if judgeConfig.EnableEarlyStop {
+			return model.JudgeModeFirstFailure, nil
+		}
+		return model.JudgeModeAll, nil
+	case model.JudgeModeAll, model.JudgeModeFirstFailure:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("评测模式无效: %s (应为%s/%s)", mode, model.JudgeModeAll, model.JudgeModeFirstFailure)
+	}
+}
+
+// resolveCompareMode 解析请求指定的输出比较模式，未指定时按词比较
+func resolveCompareMode(mode string) (model.CompareMode, error) {
+	switch mode {
+	case "":
+		return model.CompareModeToken, nil
+	case model.CompareModeStrict, model.CompareModeLine, model.CompareModeToken, model.CompareModeFloat:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("比较模式无效: %s (应为%s/%s/%s/%s)", mode,
+			model.CompareModeStrict, model.CompareModeLine, model.CompareModeToken, model.CompareModeFloat)
+	}
+}
+
+// resolveDiffReveal 解析请求指定的差异公开范围，未指定时不公开
+func resolveDiffReveal(reveal string) (model.DiffReveal, error) {
+	switch reveal {
+	case "":
+		return model.DiffRevealNone, nil
+	case model.DiffRevealNone, model.DiffRevealSample, model.DiffRevealAll:
+		return reveal, nil
+	default:
+		return "", fmt.Errorf("差异公开范围无效: %s (应为%s/%s/%s)", reveal,
+			model.DiffRevealNone, model.DiffRevealSample, model.DiffRevealAll)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"hitwh-judge/internal/conf"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/task/runner"
+	"slices"
+	"testing"
+)
+
+func TestResolveJudgeMode(t *testing.T) {
+	defer func(earlyStop bool) { judgeConfig.EnableEarlyStop = earlyStop }(judgeConfig.EnableEarlyStop)
+
+	tests := []struct {
+		name      string
+		mode      string
+		earlyStop bool
+		want      model.JudgeMode
+		wantErr   bool
+	}{
+		{name: "默认全部运行", mode: "", earlyStop: false, want: model.JudgeModeAll},
+		{name: "默认遇错即停", mode: "", earlyStop: true, want: model.JudgeModeFirstFailure},
+		{name: "请求覆盖默认配置", mode: model.JudgeModeAll, earlyStop: true, want: model.JudgeModeAll},
+		{name: "请求指定遇错即停", mode: model.JudgeModeFirstFailure, earlyStop: false, want: model.JudgeModeFirstFailure},
+		{name: "无效模式", mode: "acm", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			judgeConfig.EnableEarlyStop = tt.earlyStop
+			got, err := resolveJudgeMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveJudgeMode(%q) error = %v, wantErr %v", tt.mode, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveJudgeMode(%q) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCompareMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    model.CompareMode
+		wantErr bool
+	}{
+		{name: "默认按词比较", mode: "", want: model.CompareModeToken},
+		{name: "严格比较", mode: model.CompareModeStrict, want: model.CompareModeStrict},
+		{name: "逐行比较", mode: model.CompareModeLine, want: model.CompareModeLine},
+		{name: "浮点比较", mode: model.CompareModeFloat, want: model.CompareModeFloat},
+		{name: "无效模式", mode: "exact", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveCompareMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCompareMode(%q) error = %v, wantErr %v", tt.mode, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveCompareMode(%q) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSandboxConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  conf.JudgeConfig
+		wantErr bool
+	}{
+		{name: "默认沙箱", config: conf.JudgeConfig{Sandbox: "isolate"}},
+		{name: "按语言覆盖", config: conf.JudgeConfig{Sandbox: "isolate", SandboxOverrides: map[string]string{"java": "nsjail"}}},
+		{name: "未知沙箱", config: conf.JudgeConfig{Sandbox: "docker"}, wantErr: true},
+		{name: "未知覆盖沙箱", config: conf.JudgeConfig{Sandbox: "nsjail", SandboxOverrides: map[string]string{"python": "docker"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSandboxConfig(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSandboxConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewRunner(t *testing.T) {
+	defer func(config *conf.JudgeConfig) { judgeConfig = config }(judgeConfig)
+	judgeConfig = &conf.JudgeConfig{
+		Sandbox:          "nsjail",
+		SandboxPath:      "/opt/nsjail",
+		SandboxOverrides: map[string]string{model.LanguageJava: "sdu_sandbox"},
+	}
+
+	r, err := newRunner(model.LanguageCPP)
+	if err != nil {
+		t.Fatalf("newRunner(cpp) error = %v", err)
+	}
+	if nr, ok := r.(*runner.NsJailRunner); !ok || nr.NsJailPath != "/opt/nsjail" {
+		t.Errorf("newRunner(cpp) = %#v, want nsjail at /opt/nsjail", r)
+	}
+
+	r, err = newRunner(model.LanguageJava)
+	if err != nil {
+		t.Fatalf("newRunner(java) error = %v", err)
+	}
+	if sr, ok := r.(*runner.SDUSandboxRunner); !ok || sr.SandboxPath != runner.DefaultSDUSandboxConfig.Path {
+		t.Errorf("newRunner(java) = %#v, want sdu_sandbox at default path", r)
+	}
+}
+
+func TestIsolatePaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *conf.JudgeConfig
+		want   []string
+	}{
+		{
+			name:   "不使用isolate",
+			config: &conf.JudgeConfig{Sandbox: "nsjail"},
+			want:   nil,
+		},
+		{
+			name:   "isolate使用配置的路径",
+			config: &conf.JudgeConfig{Sandbox: "isolate", SandboxPath: "/opt/isolate"},
+			want:   []string{"/opt/isolate"},
+		},
+		{
+			name: "按语言覆盖为isolate时使用默认路径",
+			config: &conf.JudgeConfig{
+				Sandbox:          "nsjail",
+				SandboxPath:      "/opt/nsjail",
+				SandboxOverrides: map[string]string{model.LanguageJava: "isolate", model.LanguageC: "isolate"},
+			},
+			want: []string{runner.DefaultIsolateSandboxConfig.Path},
+		},
+		{
+			name: "覆盖与默认沙箱相同时不重复",
+			config: &conf.JudgeConfig{
+				Sandbox:          "isolate",
+				SandboxPath:      "/opt/isolate",
+				SandboxOverrides: map[string]string{model.LanguageJava: "isolate"},
+			},
+			want: []string{"/opt/isolate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isolatePaths(tt.config); !slices.Equal(got, tt.want) {
+				t.Errorf("isolatePaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDiffReveal(t *testing.T) {
+	tests := []struct {
+		name    string
+		reveal  string
+		want    model.DiffReveal
+		wantErr bool
+	}{
+		{name: "默认不公开", reveal: "", want: model.DiffRevealNone},
+		{name: "仅样例", reveal: model.DiffRevealSample, want: model.DiffRevealSample},
+		{name: "全部公开", reveal: model.DiffRevealAll, want: model.DiffRevealAll},
+		{name: "无效范围", reveal: "hidden", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveDiffReveal(tt.reveal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveDiffReveal(%q) error = %v, wantErr %v", tt.reveal, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveDiffReveal(%q) = %q, want %q", tt.reveal, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"hitwh-judge/internal/constants"
+	"hitwh-judge/internal/event"
 	"hitwh-judge/internal/model"
 	"hitwh-judge/internal/task/compiler"
 	"hitwh-judge/internal/task/language"
@@ -10,6 +11,7 @@ import (
 	"hitwh-judge/internal/task/runner"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -32,20 +34,13 @@ func judgeInteractive(config *model.TaskConfig, task *model.JudgeTask) (*model.J
 	if err := os.WriteFile(codePath, []byte(task.Code), 0600); err != nil {
 		return nil, fmt.Errorf("写入代码文件失败: %w", err)
 	}
-	var specialCodeFileName, specialCodePath, specialCodeLanguage, specialExePath string
-	if config.JudgeType != model.JudgeNormal {
-		specialCodeFileName = language.GetCodeFileName(specialCodeLanguage)
-		specialCodePath = filepath.Join(tempDir, specialCodeFileName)
-		specialCodeLanguage = language.DetectLanguageByExtension(*task.SpecialCodeFileName)
-		if err := os.WriteFile(specialCodePath, []byte(*task.SpecialCode), 0600); err != nil {
-			return nil, fmt.Errorf("写入特殊评测代码文件失败: %w", err)
-		}
-	}
 
 	// 3. 编译代码
 	exePath := filepath.Join(tempDir, "main")
 	compilerInstance := compiler.NewCompiler(constants.Language(config.Language))
+	publishEvent(&event.Event{TaskID: task.TaskID, Type: event.TypeCompileStarted})
 	compileErr, err := compilerInstance.Compile(codePath, exePath)
+	publishCompileFinished(task.TaskID, err == nil, compileErr)
 	if err != nil {
 		zap.L().Warn("编译失败",
 			zap.Int64("task_id", task.TaskID),
@@ -64,40 +59,38 @@ func judgeInteractive(config *model.TaskConfig, task *model.JudgeTask) (*model.J
 		}, nil
 	}
 
-	if task.Config.JudgeType != model.JudgeNormal {
-		specialExePath = filepath.Join(tempDir, "special_main")
-		compileErr, err = compileCode(specialCodePath, specialExePath, specialCodeLanguage)
-		if err != nil {
-			zap.L().Warn("编译特殊评测代码失败",
-				zap.Int64("task_id", task.TaskID),
-				zap.String("compile_err", compileErr),
-			)
-			return &model.JudgeResult{
-				TaskID: task.TaskID,
-				Status: model.StatusCE,
-				CompileResult: model.CompileResult{
-					Success: false,
-					Message: compileErr,
-				},
-				Error:      compileErr,
-				SubmitTime: time.Unix(task.CreateTime, 0),
-				JudgeTime:  time.Now(),
-			}, nil
-		}
+	specialExePath, compileErr, err := compileSpecialCode(task, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	if compileErr != "" {
+		zap.L().Warn("编译交互程序失败",
+			zap.Int64("task_id", task.TaskID),
+			zap.String("compile_err", compileErr),
+		)
+		return &model.JudgeResult{
+			TaskID: task.TaskID,
+			Status: model.StatusSE,
+			CompileResult: model.CompileResult{
+				Success: true,
+				Message: "编译成功",
+			},
+			Error:      "交互程序编译失败: " + compileErr,
+			SubmitTime: time.Unix(task.CreateTime, 0),
+			JudgeTime:  time.Now(),
+		}, nil
 	}
 
 	// 4. 下载测试用例
-	if err := downloadCase(task); err != nil {
+	releaseCases, err := downloadCase(task)
+	if err != nil {
 		return nil, fmt.Errorf("下载测试用例失败: %w", err)
 	}
+	defer releaseCases()
 
-	// 5. 运行所有测试用例
-	var caseResults []model.TestCaseResult
-	var maxMemUsed uint64
-	var totalTimeUsed time.Duration
-	finalStatus := model.StatusAC // 默认AC，遇到错误则更新
-
-	for i, checkPoint := range task.TestCases {
+	// 5. 运行所有测试用例（按子任务调度）
+	runCase := func(i int) *model.TestCaseResult {
+		checkPoint := task.TestCases[i]
 		runParams := model.RunParams{
 			TaskID:         task.TaskID,
 			TestCaseIndex:  i,
@@ -105,8 +98,10 @@ func judgeInteractive(config *model.TaskConfig, task *model.JudgeTask) (*model.J
 			Input:          checkPoint.Input,
 			InputFile:      checkPoint.InputFile,
 			Answer:         checkPoint.Output,
+			AnswerFile:     checkPoint.OutputFile,
 			TimeLimit:      int64(config.TimeLimit),
 			MemLimit:       int64(config.MemoryLimit),
+			OutputLimit:    judgeConfig.MaxOutputSize,
 			Config:         *config,
 			SpecialExePath: specialExePath,
 		}
@@ -118,74 +113,20 @@ func judgeInteractive(config *model.TaskConfig, task *model.JudgeTask) (*model.J
 				TestCaseIndex: i,
 				Status:        model.StatusSE,
 				Error:         err.Error(),
-				Expected:      checkPoint.Output,
-			}
-		} else {
-			testCaseResult.Expected = checkPoint.Output
-		}
-
-		// 6. 对比输出（仅当运行状态为AC时）
-		if testCaseResult.Status == model.StatusAC {
-			comparator := result.NewComparator(false)
-			if comparator.Compare(testCaseResult.Output, checkPoint.Output) {
-				testCaseResult.Status = model.StatusAC
-			} else {
-				testCaseResult.Status = model.StatusWA
-				testCaseResult.Error = "输出不匹配"
-				// 只在日志中记录详细差异，避免返回过大数据
-				zap.L().Debug("输出不匹配",
-					zap.Int("case", i),
-					zap.String("expected", truncateString(checkPoint.Output, 100)),
-					zap.String("actual", truncateString(testCaseResult.Output, 100)),
-				)
 			}
 		}
+		testCaseResult.Expected = caseExpected(config, checkPoint)
 
-		// 更新统计信息
-		if testCaseResult.MemUsed > maxMemUsed {
-			maxMemUsed = testCaseResult.MemUsed
-		}
-		totalTimeUsed += testCaseResult.TimeUsed
-
-		// 更新最终状态（优先级：SE > CE > RE > TLE > MLE > WA > AC）
-		finalStatus = updateFinalStatus(finalStatus, testCaseResult.Status)
-
-		caseResults = append(caseResults, *testCaseResult)
-
-		// 如果不是AC，可以选择是否继续运行后续测试点（可配置）
-		// if testCaseResult.Status != model.StatusAC {
-		// 	break // 提前终止评测
-		// }
+		// 6. 计算测试点得分（结果由交互程序判定，无需对比输出）
+		scoreTestCase(checkPoint.Score, testCaseResult)
+		return testCaseResult
 	}
+	caseResults, subtaskResults := runTestCases(task, runCase)
 
 	// 7. 构建最终结果
-	judgeResult := &model.JudgeResult{
-		TaskID:        task.TaskID,
-		Status:        finalStatus,
-		TotalScore:    calculateScore(caseResults),
-		TotalTimeUsed: totalTimeUsed,
-		TotalMemUsed:  maxMemUsed,
-		CompileResult: model.CompileResult{
-			Success: true,
-			Message: "编译成功",
-		},
-		TestResults: caseResults,
-		SubmitTime:  time.Unix(task.CreateTime, 0),
-		JudgeTime:   time.Now(),
-	}
-
-	// 记录评测耗时
-	judgeDuration := time.Since(startTime)
-	zap.L().Info("评测完成",
-		zap.Int64("task_id", task.TaskID),
-		zap.String("status", finalStatus),
-		zap.Int("total_cases", len(caseResults)),
-		zap.Int("ac_cases", countACCases(caseResults)),
-		zap.Duration("judge_duration", judgeDuration),
-	)
-
-	return judgeResult, nil
+	return buildJudgeResult(task, caseResults, subtaskResults, startTime), nil
 }
+
 func judgeNormal(config *model.TaskConfig, task *model.JudgeTask) (*model.JudgeResult, error) {
 	startTime := time.Now()
 
@@ -207,7 +148,9 @@ func judgeNormal(config *model.TaskConfig, task *model.JudgeTask) (*model.JudgeR
 	// 3. 编译代码
 	exePath := filepath.Join(tempDir, "main")
 	compilerInstance := compiler.NewCompiler(constants.Language(config.Language))
+	publishEvent(&event.Event{TaskID: task.TaskID, Type: event.TypeCompileStarted})
 	compileErr, err := compilerInstance.Compile(codePath, exePath)
+	publishCompileFinished(task.TaskID, err == nil, compileErr)
 	if err != nil {
 		zap.L().Warn("编译失败",
 			zap.Int64("task_id", task.TaskID),
@@ -226,18 +169,49 @@ func judgeNormal(config *model.TaskConfig, task *model.JudgeTask) (*model.JudgeR
 		}, nil
 	}
 
+	// 特殊评测：每个任务只编译一次checker
+	var checkerExePath string
+	if config.JudgeType == model.JudgeSpecial {
+		checkerExePath, compileErr, err = compileSpecialCode(task, tempDir)
+		if err != nil {
+			return nil, err
+		}
+		if compileErr != "" {
+			zap.L().Warn("编译checker失败",
+				zap.Int64("task_id", task.TaskID),
+				zap.String("compile_err", compileErr),
+			)
+			return &model.JudgeResult{
+				TaskID: task.TaskID,
+				Status: model.StatusSE,
+				CompileResult: model.CompileResult{
+					Success: true,
+					Message: "编译成功",
+				},
+				Error:      "checker编译失败: " + compileErr,
+				SubmitTime: time.Unix(task.CreateTime, 0),
+				JudgeTime:  time.Now(),
+			}, nil
+		}
+	}
+
 	// 4. 下载测试用例
-	if err := downloadCase(task); err != nil {
+	releaseCases, err := downloadCase(task)
+	if err != nil {
 		return nil, fmt.Errorf("下载测试用例失败: %w", err)
 	}
+	defer releaseCases()
 
-	// 5. 运行所有测试用例
-	var caseResults []model.TestCaseResult
-	var maxMemUsed uint64
-	var totalTimeUsed time.Duration
-	finalStatus := model.StatusAC // 默认AC，遇到错误则更新
+	// 5. 打开沙箱会话，全部测试点复用同一个沙箱和程序
+	session, err := openSession(config.Language, exePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSession(task.TaskID, session)
 
-	for i, checkPoint := range task.TestCases {
+	// 6. 运行所有测试用例（按子任务调度）
+	runCase := func(i int) *model.TestCaseResult {
+		checkPoint := task.TestCases[i]
 		runParams := model.RunParams{
 			TaskID:        task.TaskID,
 			TestCaseIndex: i,
@@ -246,70 +220,180 @@ func judgeNormal(config *model.TaskConfig, task *model.JudgeTask) (*model.JudgeR
 			InputFile:     checkPoint.InputFile,
 			TimeLimit:     int64(config.TimeLimit),
 			MemLimit:      int64(config.MemoryLimit),
+			OutputLimit:   judgeConfig.MaxOutputSize,
+			OutputFile:    filepath.Join(task.TempDir, fmt.Sprintf("user_output_%d.txt", i)),
 			Config:        *config,
 		}
 
-		testCaseResult, err := runSandboxSafe(runParams)
+		testCaseResult, err := runSandboxSafe(session, runParams)
 		if err != nil {
 			// 沙箱运行出错，标记为系统错误
 			testCaseResult = &model.TestCaseResult{
 				TestCaseIndex: i,
 				Status:        model.StatusSE,
 				Error:         err.Error(),
-				Expected:      checkPoint.Output,
 			}
-		} else {
-			testCaseResult.Expected = checkPoint.Output
+		}
+		testCaseResult.Expected = caseExpected(config, checkPoint)
+
+		// 7. 对比输出（仅当运行状态为AC时）
+		if testCaseResult.Status == model.StatusAC && checkerExePath != "" {
+			// 特殊评测：由checker判定结果
+			judgeByChecker(session, task, checkerExePath, checkPoint, testCaseResult, runParams.OutputFile)
+		} else if testCaseResult.Status == model.StatusAC {
+			compareOutput(config, checkPoint, testCaseResult, runParams.OutputFile)
 		}
 
-		// 6. 对比输出（仅当运行状态为AC时）
-		if testCaseResult.Status == model.StatusAC {
-			comparator := result.NewComparator(false)
-			if comparator.Compare(testCaseResult.Output, checkPoint.Output) {
-				testCaseResult.Status = model.StatusAC
-			} else {
-				testCaseResult.Status = model.StatusWA
-				testCaseResult.Error = "输出不匹配"
-				// 只在日志中记录详细差异，避免返回过大数据
-				zap.L().Debug("输出不匹配",
-					zap.Int("case", i),
-					zap.String("expected", truncateString(checkPoint.Output, 100)),
-					zap.String("actual", truncateString(testCaseResult.Output, 100)),
-				)
+		// 计算测试点得分
+		scoreTestCase(checkPoint.Score, testCaseResult)
+		return testCaseResult
+	}
+	caseResults, subtaskResults := runTestCases(task, runCase)
+
+	// 8. 构建最终结果
+	return buildJudgeResult(task, caseResults, subtaskResults, startTime), nil
+}
+
+// compareOutput 使用比较器对比程序输出与期望输出，结果直接写回testCaseResult
+// userOutFile非空时从该文件读取程序完整输出；按词比较时流式比较两个文件，不将输出读入内存
+func compareOutput(config *model.TaskConfig, checkPoint model.TestCase, testCaseResult *model.TestCaseResult, userOutFile string) {
+	var mismatch *result.Mismatch
+	var err error
+	if userOutFile != "" && (config.CompareMode == model.CompareModeToken || config.CompareMode == "") {
+		mismatch, err = result.CompareTokenFiles(userOutFile, checkPoint.OutputFile)
+		testCaseResult.Status = model.StatusAC
+		if mismatch != nil {
+			testCaseResult.Status = model.StatusWA
+		}
+	} else {
+		programOutput, expectedOutput := testCaseResult.Output, checkPoint.Output
+		if userOutFile != "" {
+			programOutput, expectedOutput, err = readOutputFiles(userOutFile, checkPoint.OutputFile)
+		}
+		if err == nil {
+			testCaseResult.Status = newComparator(config).Judge(programOutput, expectedOutput)
+			// 浮点模式下文本不同的词可能在误差范围内，不定位差异
+			if testCaseResult.Status == model.StatusWA && config.CompareMode != model.CompareModeFloat {
+				mismatch, err = result.CompareTokenStream(strings.NewReader(programOutput), strings.NewReader(expectedOutput))
 			}
 		}
+	}
+	if err != nil {
+		testCaseResult.Status = model.StatusSE
+		testCaseResult.Error = fmt.Sprintf("比较输出失败: %v", err)
+		return
+	}
 
-		// 更新统计信息
-		if testCaseResult.MemUsed > maxMemUsed {
-			maxMemUsed = testCaseResult.MemUsed
+	switch testCaseResult.Status {
+	case model.StatusAC:
+		return
+	case model.StatusPE:
+		testCaseResult.Error = "输出格式错误"
+	default:
+		testCaseResult.Error = "输出不匹配"
+		// 差异中含有期望输出的片段，仅按题目配置公开，避免泄露隐藏测试数据
+		if mismatch != nil && revealDiff(config, checkPoint) {
+			testCaseResult.Error += ": " + mismatch.String()
+			testCaseResult.Diff = &model.OutputDiff{
+				Line:       mismatch.Line,
+				Column:     mismatch.Column,
+				TokenIndex: mismatch.TokenIndex,
+				Expected:   mismatch.ExpectedExcerpt,
+				Actual:     mismatch.ActualExcerpt,
+			}
 		}
-		totalTimeUsed += testCaseResult.TimeUsed
+	}
+	if mismatch != nil {
+		zap.L().Debug("输出不匹配",
+			zap.Int("case", testCaseResult.TestCaseIndex),
+			zap.Stringer("mismatch", mismatch),
+		)
+		return
+	}
+	zap.L().Debug("输出不匹配",
+		zap.Int("case", testCaseResult.TestCaseIndex),
+		zap.String("expected", truncateString(checkPoint.Output, 100)),
+		zap.String("actual", truncateString(testCaseResult.Output, 100)),
+	)
+}
 
-		// 更新最终状态（优先级：SE > CE > RE > TLE > MLE > WA > AC）
-		finalStatus = updateFinalStatus(finalStatus, testCaseResult.Status)
+// caseExpected 返回测试点结果中公开的期望输出，与差异信息一样仅按题目配置公开
+func caseExpected(config *model.TaskConfig, checkPoint model.TestCase) string {
+	if !revealDiff(config, checkPoint) {
+		return ""
+	}
+	return checkPoint.Output
+}
 
-		caseResults = append(caseResults, *testCaseResult)
+// revealDiff 判断是否向提交者公开测试点的期望输出与差异信息
+func revealDiff(config *model.TaskConfig, checkPoint model.TestCase) bool {
+	switch config.RevealDiff {
+	case model.DiffRevealAll:
+		return true
+	case model.DiffRevealSample:
+		return checkPoint.Sample
+	default:
+		return false
+	}
+}
 
-		// 如果不是AC，可以选择是否继续运行后续测试点（可配置）
-		// if testCaseResult.Status != model.StatusAC {
-		// 	break // 提前终止评测
-		// }
+// newComparator 按评测配置创建比较器
+func newComparator(config *model.TaskConfig) *result.Comparator {
+	if config.CompareMode == model.CompareModeFloat {
+		return result.NewFloatComparator(config.FloatEps, config.FloatRelEps)
+	}
+	return result.NewModeComparator(config.CompareMode)
+}
+
+// readOutputFiles 读取程序输出与期望输出文件的完整内容
+func readOutputFiles(userOutFile string, answerFile string) (string, string, error) {
+	programOutput, err := os.ReadFile(userOutFile)
+	if err != nil {
+		return "", "", fmt.Errorf("读取程序输出失败: %w", err)
+	}
+	expectedOutput, err := os.ReadFile(answerFile)
+	if err != nil {
+		return "", "", fmt.Errorf("读取期望输出失败: %w", err)
+	}
+	return string(programOutput), string(expectedOutput), nil
+}
+
+// buildJudgeResult 汇总所有测试点结果，构建最终评测结果
+func buildJudgeResult(task *model.JudgeTask, caseResults []model.TestCaseResult, subtaskResults []model.SubtaskResult, startTime time.Time) *model.JudgeResult {
+	var maxMemUsed uint64
+	var totalTimeUsed time.Duration
+	finalStatus := model.StatusAC // 默认AC，遇到错误则更新
+
+	for _, r := range caseResults {
+		// 更新统计信息
+		if r.MemUsed > maxMemUsed {
+			maxMemUsed = r.MemUsed
+		}
+		totalTimeUsed += r.TimeUsed
+
+		// 更新最终状态（优先级：SE > CE > RE > TLE > MLE > OLE > WA > PE > PC > AC）
+		finalStatus = updateFinalStatus(finalStatus, r.Status)
+	}
+
+	totalScore := calculateScore(caseResults)
+	if len(subtaskResults) > 0 {
+		totalScore = calculateSubtaskScore(subtaskResults)
 	}
 
-	// 7. 构建最终结果
 	judgeResult := &model.JudgeResult{
 		TaskID:        task.TaskID,
 		Status:        finalStatus,
-		TotalScore:    calculateScore(caseResults),
+		TotalScore:    totalScore,
 		TotalTimeUsed: totalTimeUsed,
 		TotalMemUsed:  maxMemUsed,
 		CompileResult: model.CompileResult{
 			Success: true,
 			Message: "编译成功",
 		},
-		TestResults: caseResults,
-		SubmitTime:  time.Unix(task.CreateTime, 0),
-		JudgeTime:   time.Now(),
+		TestResults:    caseResults,
+		SubtaskResults: subtaskResults,
+		SubmitTime:     time.Unix(task.CreateTime, 0),
+		JudgeTime:      time.Now(),
 	}
 
 	// 记录评测耗时
@@ -322,7 +406,75 @@ func judgeNormal(config *model.TaskConfig, task *model.JudgeTask) (*model.JudgeR
 		zap.Duration("judge_duration", judgeDuration),
 	)
 
-	return judgeResult, nil
+	return judgeResult
+}
+
+// compileSpecialCode 编译特殊评测代码（checker或交互程序）
+// 返回可执行文件路径；编译失败时compileErr非空，其他错误通过err返回
+func compileSpecialCode(task *model.JudgeTask, tempDir string) (exePath string, compileErr string, err error) {
+	if task.SpecialCode == nil || *task.SpecialCode == "" {
+		return "", "", fmt.Errorf("特殊评测代码不能为空")
+	}
+	specialLanguage := constants.LanguageCpp
+	if task.SpecialCodeFileName != nil && *task.SpecialCodeFileName != "" {
+		specialLanguage = language.DetectLanguageByExtension(*task.SpecialCodeFileName)
+	}
+
+	// 放在独立子目录中，避免与用户代码文件重名
+	specialDir := filepath.Join(tempDir, "special")
+	if err := os.MkdirAll(specialDir, 0755); err != nil {
+		return "", "", fmt.Errorf("创建特殊评测目录失败: %w", err)
+	}
+	specialCodePath := filepath.Join(specialDir, language.GetCodeFileName(specialLanguage))
+	if err := os.WriteFile(specialCodePath, []byte(*task.SpecialCode), 0600); err != nil {
+		return "", "", fmt.Errorf("写入特殊评测代码文件失败: %w", err)
+	}
+
+	exePath = filepath.Join(tempDir, "special_main")
+	compileErr, err = compileCode(specialCodePath, exePath, specialLanguage)
+	if err != nil {
+		if compileErr == "" {
+			compileErr = err.Error()
+		}
+		return "", compileErr, nil
+	}
+	return exePath, "", nil
+}
+
+// judgeByChecker 使用checker判定单个测试点，结果直接写回testCaseResult
+// userOutFile为沙箱保存的程序完整输出，session为运行选手程序的沙箱会话
+func judgeByChecker(session runner.Session, task *model.JudgeTask, checkerExePath string, checkPoint model.TestCase, testCaseResult *model.TestCaseResult, userOutFile string) {
+	i := testCaseResult.TestCaseIndex
+
+	checkerResult, err := runCheckerSafe(session, model.RunParams{
+		TaskID:         task.TaskID,
+		TestCaseIndex:  i,
+		InputFile:      checkPoint.InputFile,
+		UserOutFile:    userOutFile,
+		AnswerFile:     checkPoint.OutputFile,
+		Config:         task.Config,
+		SpecialExePath: checkerExePath,
+	})
+	if err != nil {
+		testCaseResult.Status = model.StatusSE
+		testCaseResult.Error = err.Error()
+		return
+	}
+
+	verdict := result.ParseCheckerResult(checkerResult.ExitCode, checkerResult.Message)
+	testCaseResult.Status = verdict.Status
+	testCaseResult.CheckerMessage = verdict.Message
+	testCaseResult.ScoreRatio = verdict.ScoreRatio
+	switch verdict.Status {
+	case model.StatusWA:
+		testCaseResult.Error = "checker判定答案错误"
+	case model.StatusPE:
+		testCaseResult.Error = "checker判定格式错误"
+	case model.StatusPC:
+		testCaseResult.Error = "checker判定部分正确"
+	case model.StatusSE:
+		testCaseResult.Error = "checker运行失败"
+	}
 }
 
 func compileCode(srcFile string, dstFile string, language string) (string, error) {
@@ -334,8 +486,8 @@ func compileCode(srcFile string, dstFile string, language string) (string, error
 	return compileErr, err
 }
 
-// runSandboxSafe 安全地运行沙箱，捕获panic
-func runSandboxSafe(runParams model.RunParams) (result *model.TestCaseResult, err error) {
+// runSandboxSafe 安全地在沙箱会话中运行测试点，捕获panic
+func runSandboxSafe(session runner.Session, runParams model.RunParams) (result *model.TestCaseResult, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("沙箱运行panic: %v", r)
@@ -347,30 +499,7 @@ func runSandboxSafe(runParams model.RunParams) (result *model.TestCaseResult, er
 		}
 	}()
 
-	var testCaseResult *model.TestCaseResult
-
-	// 根据评测类型选择不同的沙箱
-	switch runParams.Config.JudgeType {
-	// case model.JudgeInteractive:
-	// 	// 交互题使用nsjail沙箱的特殊方法
-	// 	nsJail := runner.GetDefaultSandboxConfig(runner.NsJail)
-	// 	nsjailSandBox := runner.NewRunner(runner.NsJail, nsJail.Path)
-	// 	// 将普通的Runner转换为NsJailRunner以调用交互方法
-	// 	if nsjailRunner, ok := nsjailSandBox.(*runner.NsJailRunner); ok {
-	// 		testCaseResult = nsjailRunner.RunInteractiveInSandbox(runParams)
-	// 	} else {
-	// 		return nil, fmt.Errorf("无法转换为NsJailRunner类型")
-	// 	}
-	default:
-		// 其他类型使用isolate沙箱
-		isolate := runner.GetDefaultSandboxConfig(runner.Isolate)
-		isolateSandBox := runner.NewRunner(runner.Isolate, isolate.Path)
-		testCaseResult = isolateSandBox.RunInSandbox(runParams)
-
-		// isolate := runner.GetDefaultSandboxConfig(runner.NsJail)
-		// isolateSandBox := runner.NewRunner(runner.NsJail, isolate.Path)
-		// testCaseResult = isolateSandBox.RunInSandbox(runParams)
-	}
+	testCaseResult := session.Run(runParams)
 
 	if testCaseResult == nil {
 		return nil, fmt.Errorf("沙箱返回结果为空")
@@ -388,7 +517,27 @@ func runSandboxSafe(runParams model.RunParams) (result *model.TestCaseResult, er
 	return testCaseResult, nil
 }
 
-// runSandboxSafe 安全地运行沙箱，捕获panic
+// openSession 为提交打开运行指定语言程序的沙箱会话
+func openSession(language model.LanguageType, exePath string) (runner.Session, error) {
+	sandbox, err := newRunner(language)
+	if err != nil {
+		return nil, err
+	}
+	session, err := sandbox.Open(exePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开沙箱会话失败: %w", err)
+	}
+	return session, nil
+}
+
+// closeSession 关闭沙箱会话，失败时只记录日志
+func closeSession(taskID int64, session runner.Session) {
+	if err := session.Close(); err != nil {
+		zap.L().Warn("关闭沙箱会话失败", zap.Int64("task_id", taskID), zap.Error(err))
+	}
+}
+
+// runInteractive 安全地运行交互题沙箱，捕获panic
 func runInteractive(runParams model.RunParams) (result *model.TestCaseResult, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -401,30 +550,11 @@ func runInteractive(runParams model.RunParams) (result *model.TestCaseResult, er
 		}
 	}()
 
-	var testCaseResult *model.TestCaseResult
-
-	// 根据评测类型选择不同的沙箱
-	switch runParams.Config.JudgeType {
-	// case model.JudgeInteractive:
-	// 	// 交互题使用nsjail沙箱的特殊方法
-	// 	nsJail := runner.GetDefaultSandboxConfig(runner.NsJail)
-	// 	nsjailSandBox := runner.NewRunner(runner.NsJail, nsJail.Path)
-	// 	// 将普通的Runner转换为NsJailRunner以调用交互方法
-	// 	if nsjailRunner, ok := nsjailSandBox.(*runner.NsJailRunner); ok {
-	// 		testCaseResult = nsjailRunner.RunInteractiveInSandbox(runParams)
-	// 	} else {
-	// 		return nil, fmt.Errorf("无法转换为NsJailRunner类型")
-	// 	}
-	default:
-		// 其他类型使用isolate沙箱
-		isolate := runner.GetDefaultSandboxConfig(runner.Isolate)
-		isolateSandBox := runner.NewRunner(runner.Isolate, isolate.Path)
-		testCaseResult = isolateSandBox.RunInteractiveInSandbox(runParams)
-
-		// isolate := runner.GetDefaultSandboxConfig(runner.NsJail)
-		// isolateSandBox := runner.NewRunner(runner.NsJail, isolate.Path)
-		// testCaseResult = isolateSandBox.RunInSandbox(runParams)
+	sandbox, err := newRunner(runParams.Config.Language)
+	if err != nil {
+		return nil, err
 	}
+	testCaseResult := sandbox.RunInteractiveInSandbox(runParams)
 
 	if testCaseResult == nil {
 		return nil, fmt.Errorf("沙箱返回结果为空")
@@ -441,3 +571,38 @@ func runInteractive(runParams model.RunParams) (result *model.TestCaseResult, er
 
 	return testCaseResult, nil
 }
+
+// runCheckerSafe 安全地运行checker，捕获panic
+// 会话支持时在会话的沙箱中运行，不再另外申请沙箱
+func runCheckerSafe(session runner.Session, runParams model.RunParams) (checkerResult *model.CheckerResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			checkerResult = nil
+			err = fmt.Errorf("checker运行panic: %v", r)
+		}
+	}()
+
+	if cs, ok := session.(runner.CheckerSession); ok {
+		checkerResult = cs.RunChecker(runParams)
+	} else {
+		// checker不属于选手程序，使用默认沙箱运行
+		sandbox, err := newRunner("")
+		if err != nil {
+			return nil, err
+		}
+		checkerResult = sandbox.RunCheckerInSandbox(runParams)
+	}
+
+	if checkerResult == nil {
+		return nil, fmt.Errorf("checker返回结果为空")
+	}
+	if checkerResult.Error != "" {
+		zap.L().Warn("checker运行失败",
+			zap.Int64("task_id", runParams.TaskID),
+			zap.Int("case", runParams.TestCaseIndex),
+			zap.String("error", checkerResult.Error),
+		)
+		return nil, fmt.Errorf("checker运行失败: %s", checkerResult.Error)
+	}
+	return checkerResult, nil
+}
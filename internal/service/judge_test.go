@@ -0,0 +1,71 @@
+package service
+
+import (
+	"hitwh-judge/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareOutput(t *testing.T) {
+	dir := t.TempDir()
+	answerFile := filepath.Join(dir, "answer.txt")
+	if err := os.WriteFile(answerFile, []byte("1 2\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		mode       model.CompareMode
+		reveal     model.DiffReveal
+		sample     bool
+		userOutput string
+		wantStatus model.JudgeStatus
+		wantError  string
+		wantDiff   *model.OutputDiff
+	}{
+		{name: "按词比较-正确", mode: model.CompareModeToken, reveal: model.DiffRevealAll, userOutput: "1\n2 3", wantStatus: model.StatusAC},
+		{name: "按词比较-错误", mode: model.CompareModeToken, reveal: model.DiffRevealAll, userOutput: "1 2\n4", wantStatus: model.StatusWA, wantError: "第2行第1列",
+			wantDiff: &model.OutputDiff{Line: 2, Column: 1, TokenIndex: 3, Expected: "1 2\n3\n", Actual: "1 2\n4"}},
+		{name: "逐行比较-格式错误", mode: model.CompareModeLine, reveal: model.DiffRevealAll, userOutput: "1\n2 3", wantStatus: model.StatusPE},
+		{name: "逐行比较-错误", mode: model.CompareModeLine, reveal: model.DiffRevealAll, userOutput: "1 2\n5", wantStatus: model.StatusWA, wantError: "第2行第1列",
+			wantDiff: &model.OutputDiff{Line: 2, Column: 1, TokenIndex: 3, Expected: "1 2\n3\n", Actual: "1 2\n5"}},
+		{name: "样例测试点-公开差异", mode: model.CompareModeToken, reveal: model.DiffRevealSample, sample: true, userOutput: "1 2\n4", wantStatus: model.StatusWA, wantError: "第2行第1列",
+			wantDiff: &model.OutputDiff{Line: 2, Column: 1, TokenIndex: 3, Expected: "1 2\n3\n", Actual: "1 2\n4"}},
+		{name: "隐藏测试点-不公开差异", mode: model.CompareModeToken, reveal: model.DiffRevealSample, userOutput: "1 2\n4", wantStatus: model.StatusWA},
+		{name: "默认不公开差异", mode: model.CompareModeToken, reveal: model.DiffRevealNone, sample: true, userOutput: "1 2\n4", wantStatus: model.StatusWA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userOutFile := filepath.Join(dir, "user_output.txt")
+			if err := os.WriteFile(userOutFile, []byte(tt.userOutput), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config := model.DefaultTaskConfig
+			config.CompareMode = tt.mode
+			config.RevealDiff = tt.reveal
+			checkPoint := model.TestCase{Output: "1 2\n3\n", OutputFile: answerFile, Sample: tt.sample}
+			r := &model.TestCaseResult{Status: model.StatusAC, Expected: caseExpected(&config, checkPoint)}
+			compareOutput(&config, checkPoint, r, userOutFile)
+			if r.Status != tt.wantStatus {
+				t.Errorf("compareOutput() status = %v, want %v (error: %s)", r.Status, tt.wantStatus, r.Error)
+			}
+			if !strings.Contains(r.Error, tt.wantError) {
+				t.Errorf("compareOutput() error = %q, want containing %q", r.Error, tt.wantError)
+			}
+			if tt.wantDiff == nil && tt.wantStatus == model.StatusWA && r.Error != "输出不匹配" {
+				t.Errorf("compareOutput() error = %q, want no details", r.Error)
+			}
+			if (r.Diff == nil) != (tt.wantDiff == nil) || (r.Diff != nil && *r.Diff != *tt.wantDiff) {
+				t.Errorf("compareOutput() diff = %+v, want %+v", r.Diff, tt.wantDiff)
+			}
+			// 期望输出与差异按同一规则公开，隐藏测试点两者都不返回
+			revealed := tt.reveal == model.DiffRevealAll || (tt.reveal == model.DiffRevealSample && tt.sample)
+			if revealed != (r.Expected != "") {
+				t.Errorf("expected output = %q, want revealed %v", r.Expected, revealed)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"hitwh-judge/internal/task/runner"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,6 +19,7 @@ type JudgeMetrics struct {
 	WACount  int64 // WA数量
 	TLECount int64 // TLE数量
 	MLECount int64 // MLE数量
+	OLECount int64 // OLE数量
 	RECount  int64 // RE数量
 	CECount  int64 // CE数量
 	SECount  int64 // SE数量
@@ -72,6 +74,8 @@ func (m *JudgeMetrics) RecordSuccess(judgeTime time.Duration, status string) {
 		atomic.AddInt64(&m.TLECount, 1)
 	case "MLE":
 		atomic.AddInt64(&m.MLECount, 1)
+	case "OLE":
+		atomic.AddInt64(&m.OLECount, 1)
 	case "RE":
 		atomic.AddInt64(&m.RECount, 1)
 	case "CE":
@@ -186,6 +190,7 @@ func (m *JudgeMetrics) GetSnapshot() map[string]interface{} {
 		"wa_count":  atomic.LoadInt64(&m.WACount),
 		"tle_count": atomic.LoadInt64(&m.TLECount),
 		"mle_count": atomic.LoadInt64(&m.MLECount),
+		"ole_count": atomic.LoadInt64(&m.OLECount),
 		"re_count":  atomic.LoadInt64(&m.RECount),
 		"ce_count":  atomic.LoadInt64(&m.CECount),
 		"se_count":  atomic.LoadInt64(&m.SECount),
@@ -206,6 +211,9 @@ func (m *JudgeMetrics) GetSnapshot() map[string]interface{} {
 		"cache_misses":   cacheMisses,
 		"cache_hit_rate": cacheHitRate,
 
+		// 沙箱池统计
+		"sandbox_pool": runner.GetSandboxPoolStats(),
+
 		// 运行时间
 		"uptime_seconds": uptime.Seconds(),
 		"start_time":     m.StartTime.Format(time.RFC3339),
@@ -224,6 +232,7 @@ func (m *JudgeMetrics) Reset() {
 	atomic.StoreInt64(&m.WACount, 0)
 	atomic.StoreInt64(&m.TLECount, 0)
 	atomic.StoreInt64(&m.MLECount, 0)
+	atomic.StoreInt64(&m.OLECount, 0)
 	atomic.StoreInt64(&m.RECount, 0)
 	atomic.StoreInt64(&m.CECount, 0)
 	atomic.StoreInt64(&m.SECount, 0)
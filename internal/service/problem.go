@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/problem"
+	"io"
+	"os"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// problemStore 题目包存储，未初始化时使用默认目录
+var problemStore = problem.NewStore(problem.DefaultDir)
+
+// MustInitProblemStore 根据配置初始化题目包存储
+// 配置项：problem.dir（默认 ./problems）
+func MustInitProblemStore(cfg *viper.Viper) {
+	dir := cfg.GetString("problem.dir")
+	if dir == "" {
+		dir = problem.DefaultDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(fmt.Errorf("init problem store failed, err:%w", err))
+	}
+	problemStore = problem.NewStore(dir)
+}
+
+// ImportProblem 导入zip格式的题目包，成为该题目的当前版本
+func ImportProblem(id string, r io.ReaderAt, size int64) (*problem.Package, error) {
+	pkg, err := problemStore.Import(id, r, size)
+	if err != nil {
+		return nil, err
+	}
+	zap.L().Info("导入题目包",
+		zap.String("problem_id", pkg.ID),
+		zap.String("version", pkg.Version),
+		zap.Int("check_points", len(pkg.Manifest.CheckPoints)),
+	)
+	return pkg, nil
+}
+
+// loadProblemTask 从题目包构造评测任务，version为空时使用当前版本
+func loadProblemTask(id, version string) (*model.JudgeTask, error) {
+	pkg, err := problemStore.Load(id, version)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.JudgeTask()
+}
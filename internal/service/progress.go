@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hitwh-judge/internal/dao"
+	"hitwh-judge/internal/event"
+	"hitwh-judge/internal/model"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// eventBroker 评测进度事件分发，默认进程内分发
+var eventBroker event.Broker = event.NewMemoryBroker()
+
+// MustInitEventBroker 根据配置初始化评测进度事件分发
+// 使用Redis分发时需先调用 dao.MustInitRedis
+func MustInitEventBroker(cfg *viper.Viper) {
+	b, err := event.New(cfg, dao.RedisClient)
+	if err != nil {
+		panic(fmt.Errorf("init event broker failed, err:%w", err))
+	}
+	eventBroker = b
+}
+
+// publishEvent 发布评测进度事件，失败时仅记录日志，不影响评测
+func publishEvent(e *event.Event) {
+	e.Time = time.Now()
+	if err := eventBroker.Publish(context.Background(), e); err != nil {
+		zap.L().Warn("发布评测进度事件失败",
+			zap.Int64("task_id", e.TaskID),
+			zap.String("type", e.Type),
+			zap.Error(err),
+		)
+	}
+}
+
+// publishCompileFinished 发布编译完成事件
+func publishCompileFinished(taskID int64, success bool, message string) {
+	publishEvent(&event.Event{
+		TaskID:  taskID,
+		Type:    event.TypeCompileFinished,
+		Compile: &model.CompileResult{Success: success, Message: message},
+	})
+}
+
+// SubscribeTaskEvents 订阅评测任务的进度事件
+// 首个事件为任务当前状态（已结束的任务直接返回最终结果事件），最终结果事件之后或ctx结束时通道关闭
+func SubscribeTaskEvents(ctx context.Context, taskID int64) (<-chan *event.Event, error) {
+	if _, err := resultStore.Get(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	sub, unsubscribe, err := eventBroker.Subscribe(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	// 订阅生效后再读取一次状态，避免订阅前任务恰好结束而错过最终事件
+	record, err := resultStore.Get(ctx, taskID)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+
+	out := make(chan *event.Event)
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+
+		e := snapshotEvent(record)
+		for {
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+			if e.IsFinal() {
+				return
+			}
+
+			var ok bool
+			select {
+			case e, ok = <-sub:
+				if !ok {
+					// 订阅在最终事件之前关闭（如消费过慢时最终事件无法投递），以保存的结果补发最终事件
+					if e = finalSnapshot(ctx, taskID); e == nil {
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// finalSnapshot 重新读取任务记录，任务已结束时返回最终结果事件，否则返回nil
+func finalSnapshot(ctx context.Context, taskID int64) *event.Event {
+	record, err := resultStore.Get(ctx, taskID)
+	if err != nil {
+		zap.L().Warn("读取评测任务记录失败", zap.Int64("task_id", taskID), zap.Error(err))
+		return nil
+	}
+	if e := snapshotEvent(record); e.IsFinal() {
+		return e
+	}
+	return nil
+}
+
+// snapshotEvent 根据任务记录构造当前状态事件
+func snapshotEvent(record *model.TaskRecord) *event.Event {
+	e := &event.Event{TaskID: record.TaskID, State: record.State, Time: record.UpdateTime}
+	switch record.State {
+	case model.TaskStateFinished:
+		e.Type = event.TypeFinished
+		e.Result = record.Result
+	case model.TaskStateFailed:
+		e.Type = event.TypeFailed
+		e.Error = record.Error
+	default:
+		e.Type = event.TypeState
+	}
+	return e
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"hitwh-judge/internal/event"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/store"
+	"testing"
+	"time"
+)
+
+func TestSubscribeTaskEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer func(s store.ResultStore, b event.Broker) { resultStore, eventBroker = s, b }(resultStore, eventBroker)
+	resultStore = store.NewMemoryStore(time.Hour)
+	eventBroker = event.NewMemoryBroker()
+
+	saveTaskRecord(ctx, &model.TaskRecord{TaskID: 1, State: model.TaskStateRunning})
+	events, err := SubscribeTaskEvents(ctx, 1)
+	if err != nil {
+		t.Fatalf("SubscribeTaskEvents() error = %v", err)
+	}
+
+	publishEvent(&event.Event{TaskID: 1, Type: event.TypeCaseFinished, CaseIndex: 0, CaseTotal: 1})
+	publishEvent(&event.Event{TaskID: 1, Type: event.TypeFinished})
+
+	var got []event.Type
+	for e := range events {
+		got = append(got, e.Type)
+	}
+	want := []event.Type{event.TypeState, event.TypeCaseFinished, event.TypeFinished}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSubscribeTaskEvents_Finished(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer func(s store.ResultStore) { resultStore = s }(resultStore)
+	resultStore = store.NewMemoryStore(time.Hour)
+
+	result := &model.JudgeResult{TaskID: 1, Status: model.StatusAC}
+	saveTaskRecord(ctx, &model.TaskRecord{TaskID: 1, State: model.TaskStateFinished, Result: result})
+
+	events, err := SubscribeTaskEvents(ctx, 1)
+	if err != nil {
+		t.Fatalf("SubscribeTaskEvents() error = %v", err)
+	}
+	e, ok := <-events
+	if !ok || e.Type != event.TypeFinished || e.Result == nil || e.Result.Status != model.StatusAC {
+		t.Fatalf("first event = %+v, want finished with result", e)
+	}
+	if _, ok := <-events; ok {
+		t.Error("channel not closed after final event")
+	}
+}
+
+func TestSubscribeTaskEvents_SlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer func(s store.ResultStore, b event.Broker) { resultStore, eventBroker = s, b }(resultStore, eventBroker)
+	resultStore = store.NewMemoryStore(time.Hour)
+	eventBroker = event.NewMemoryBroker()
+
+	saveTaskRecord(ctx, &model.TaskRecord{TaskID: 1, State: model.TaskStateRunning})
+	events, err := SubscribeTaskEvents(ctx, 1)
+	if err != nil {
+		t.Fatalf("SubscribeTaskEvents() error = %v", err)
+	}
+
+	// 订阅方暂不读取，事件超出缓冲后最终事件无法投递
+	for i := 0; i < 1000; i++ {
+		publishEvent(&event.Event{TaskID: 1, Type: event.TypeCaseFinished, CaseIndex: i, CaseTotal: 1000})
+	}
+	record := &model.TaskRecord{TaskID: 1, State: model.TaskStateFinished, Result: &model.JudgeResult{TaskID: 1, Status: model.StatusAC}}
+	saveTaskRecord(ctx, record)
+	publishEvent(snapshotEvent(record))
+
+	var last *event.Event
+	for e := range events {
+		last = e
+	}
+	if last == nil || last.Type != event.TypeFinished || last.Result == nil || last.Result.Status != model.StatusAC {
+		t.Fatalf("last event = %+v, want finished with result", last)
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"fmt"
+	"hitwh-judge/internal/event"
+	"hitwh-judge/internal/model"
+	"math"
+)
+
+// validateSubtasks 校验子任务配置
+// 依赖的子任务必须在当前子任务之前声明，从而避免循环依赖
+func validateSubtasks(subtasks []model.Subtask, caseCount int) error {
+	declared := make(map[string]bool, len(subtasks))
+	for _, st := range subtasks {
+		if st.Name == "" {
+			return fmt.Errorf("子任务名称不能为空")
+		}
+		if declared[st.Name] {
+			return fmt.Errorf("子任务名称重复: %s", st.Name)
+		}
+		if st.Score < 0 {
+			return fmt.Errorf("子任务%s分值无效: %d", st.Name, st.Score)
+		}
+		switch st.Policy {
+		case model.SubtaskPolicyMin, model.SubtaskPolicySum, model.SubtaskPolicyMinRatio:
+		default:
+			return fmt.Errorf("子任务%s计分策略无效: %s", st.Name, st.Policy)
+		}
+		if len(st.TestCases) == 0 {
+			return fmt.Errorf("子任务%s不包含任何测试点", st.Name)
+		}
+		for _, idx := range st.TestCases {
+			if idx < 0 || idx >= caseCount {
+				return fmt.Errorf("子任务%s的测试点下标越界: %d", st.Name, idx)
+			}
+		}
+		for _, dep := range st.Dependencies {
+			if !declared[dep] {
+				return fmt.Errorf("子任务%s依赖的子任务%s不存在或未在其之前声明", st.Name, dep)
+			}
+		}
+		declared[st.Name] = true
+	}
+	return nil
+}
+
+// runTestCases 按子任务调度运行测试点
+// 未配置子任务时按顺序运行全部测试点；配置子任务时，子任务已失败后跳过其剩余测试点，
+// 依赖未通过的子任务整体跳过，不属于任何子任务的测试点（如样例）照常运行。
+// 遇错即停模式下，出现第一个未通过的测试点后不再运行任何测试点。
+// 每个测试点最多运行一次，被跳过的测试点状态为SKIPPED。
+func runTestCases(task *model.JudgeTask, runCase func(i int) *model.TestCaseResult) ([]model.TestCaseResult, []model.SubtaskResult) {
+	results := make([]*model.TestCaseResult, len(task.TestCases))
+	var subtaskResults []model.SubtaskResult
+
+	stopped := false
+	run := func(i int) *model.TestCaseResult {
+		if stopped {
+			return nil
+		}
+		publishEvent(&event.Event{
+			TaskID:    task.TaskID,
+			Type:      event.TypeCaseStarted,
+			CaseIndex: i,
+			CaseTotal: len(task.TestCases),
+		})
+		r := runCase(i)
+		publishEvent(&event.Event{
+			TaskID:     task.TaskID,
+			Type:       event.TypeCaseFinished,
+			CaseIndex:  i,
+			CaseTotal:  len(task.TestCases),
+			CaseResult: r,
+		})
+		if task.Config.JudgeMode == model.JudgeModeFirstFailure && r.Status != model.StatusAC {
+			stopped = true
+		}
+		return r
+	}
+
+	passed := make(map[string]bool, len(task.Subtasks))
+	grouped := make([]bool, len(task.TestCases))
+	for _, st := range task.Subtasks {
+		for _, idx := range st.TestCases {
+			grouped[idx] = true
+		}
+
+		if !dependenciesPassed(st, passed) {
+			subtaskResults = append(subtaskResults, model.SubtaskResult{
+				Name:      st.Name,
+				Status:    model.StatusSkipped,
+				FullScore: st.Score,
+				TestCases: st.TestCases,
+			})
+			continue
+		}
+
+		failed := false
+		for _, idx := range st.TestCases {
+			if results[idx] == nil {
+				if failed {
+					continue
+				}
+				if results[idx] = run(idx); results[idx] == nil {
+					continue
+				}
+			}
+			if subtaskFailedBy(st.Policy, results[idx]) {
+				failed = true
+			}
+		}
+
+		passed[st.Name] = !failed && subtaskPassed(st, results)
+		subtaskResults = append(subtaskResults, scoreSubtask(st, task.TestCases, results))
+	}
+
+	for i := range task.TestCases {
+		if results[i] == nil && !grouped[i] {
+			results[i] = run(i)
+		}
+	}
+
+	skipReason := "所属子任务已失败或依赖未通过，跳过评测"
+	if stopped {
+		skipReason = "已有测试点未通过，提前终止评测"
+	}
+	caseResults := make([]model.TestCaseResult, len(results))
+	for i, r := range results {
+		if r == nil {
+			r = skippedResult(i, skipReason)
+		}
+		caseResults[i] = *r
+	}
+	return caseResults, subtaskResults
+}
+
+// dependenciesPassed 判断子任务依赖是否全部通过
+func dependenciesPassed(st model.Subtask, passed map[string]bool) bool {
+	for _, dep := range st.Dependencies {
+		if !passed[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// subtaskPassed 判断子任务是否全部通过（所有测试点均得满分）
+func subtaskPassed(st model.Subtask, results []*model.TestCaseResult) bool {
+	for _, idx := range st.TestCases {
+		if results[idx] == nil || caseScoreRatio(results[idx]) < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// subtaskFailedBy 判断测试点结果是否使子任务失败（此后不再需要运行其剩余测试点）
+func subtaskFailedBy(policy model.SubtaskPolicy, r *model.TestCaseResult) bool {
+	switch policy {
+	case model.SubtaskPolicyMin:
+		return r.Status != model.StatusAC
+	case model.SubtaskPolicyMinRatio:
+		return caseScoreRatio(r) <= 0
+	default:
+		// sum策略下每个测试点独立计分，不提前终止
+		return false
+	}
+}
+
+// scoreSubtask 按计分策略计算子任务得分
+// sum策略按测试点分值加权平均各测试点的得分比例，子任务内测试点分值全部为0时等权平均
+// 被跳过（未运行）的测试点得分比例按0计算；测试点全部被跳过时子任务状态为SKIPPED，
+// 部分被跳过时以已运行测试点中未通过的状态为准，已运行的全部通过时同样为SKIPPED
+func scoreSubtask(st model.Subtask, cases []model.TestCase, results []*model.TestCaseResult) model.SubtaskResult {
+	equalWeights := true
+	for _, idx := range st.TestCases {
+		if cases[idx].Score > 0 {
+			equalWeights = false
+			break
+		}
+	}
+
+	status := model.StatusAC
+	skipped := false
+	minRatio, weightedRatio, totalWeight := 1.0, 0.0, 0.0
+	for _, idx := range st.TestCases {
+		weight := float64(cases[idx].Score)
+		if equalWeights {
+			weight = 1
+		}
+		totalWeight += weight
+
+		r := results[idx]
+		if r == nil {
+			skipped = true
+			minRatio = 0
+			continue
+		}
+		status = updateFinalStatus(status, r.Status)
+		ratio := caseScoreRatio(r)
+		minRatio = math.Min(minRatio, ratio)
+		weightedRatio += weight * ratio
+	}
+	if skipped && status == model.StatusAC {
+		status = model.StatusSkipped
+	}
+
+	var ratio float64
+	switch st.Policy {
+	case model.SubtaskPolicyMin:
+		if minRatio >= 1 {
+			ratio = 1
+		}
+	case model.SubtaskPolicySum:
+		if totalWeight > 0 {
+			ratio = weightedRatio / totalWeight
+		}
+	case model.SubtaskPolicyMinRatio:
+		ratio = minRatio
+	}
+
+	return model.SubtaskResult{
+		Name:      st.Name,
+		Status:    status,
+		Score:     math.Round(float64(st.Score)*ratio*100) / 100,
+		FullScore: st.Score,
+		TestCases: st.TestCases,
+	}
+}
+
+// calculateSubtaskScore 计算子任务总分
+func calculateSubtaskScore(subtaskResults []model.SubtaskResult) float64 {
+	var total float64
+	for _, r := range subtaskResults {
+		total += r.Score
+	}
+	return math.Round(total*100) / 100
+}
+
+// skippedResult 构造被跳过的测试点结果
+func skippedResult(i int, reason string) *model.TestCaseResult {
+	return &model.TestCaseResult{
+		TestCaseIndex: i,
+		Status:        model.StatusSkipped,
+		Error:         reason,
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"hitwh-judge/internal/model"
+	"testing"
+)
+
+func TestValidateSubtasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		subtasks []model.Subtask
+		wantErr  bool
+	}{
+		{
+			name: "合法配置",
+			subtasks: []model.Subtask{
+				{Name: "s1", Score: 40, Policy: model.SubtaskPolicyMin, TestCases: []int{0, 1}},
+				{Name: "s2", Score: 60, Policy: model.SubtaskPolicySum, TestCases: []int{2}, Dependencies: []string{"s1"}},
+			},
+		},
+		{
+			name: "名称重复",
+			subtasks: []model.Subtask{
+				{Name: "s1", Policy: model.SubtaskPolicyMin, TestCases: []int{0}},
+				{Name: "s1", Policy: model.SubtaskPolicyMin, TestCases: []int{1}},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "计分策略无效",
+			subtasks: []model.Subtask{{Name: "s1", Policy: "max", TestCases: []int{0}}},
+			wantErr:  true,
+		},
+		{
+			name:     "测试点下标越界",
+			subtasks: []model.Subtask{{Name: "s1", Policy: model.SubtaskPolicyMin, TestCases: []int{3}}},
+			wantErr:  true,
+		},
+		{
+			name:     "不包含测试点",
+			subtasks: []model.Subtask{{Name: "s1", Policy: model.SubtaskPolicyMin}},
+			wantErr:  true,
+		},
+		{
+			name: "依赖在之后声明",
+			subtasks: []model.Subtask{
+				{Name: "s1", Policy: model.SubtaskPolicyMin, TestCases: []int{0}, Dependencies: []string{"s2"}},
+				{Name: "s2", Policy: model.SubtaskPolicyMin, TestCases: []int{1}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSubtasks(tt.subtasks, 3)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSubtasks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunTestCases(t *testing.T) {
+	// 测试点0为样例（不属于任何子任务），1、2属于s1，3、4属于s2（依赖s1），5属于s3
+	statuses := []model.JudgeStatus{
+		model.StatusAC, model.StatusWA, model.StatusAC, model.StatusAC, model.StatusAC, model.StatusAC,
+	}
+	task := &model.JudgeTask{
+		TestCases: make([]model.TestCase, len(statuses)),
+		Subtasks: []model.Subtask{
+			{Name: "s1", Score: 30, Policy: model.SubtaskPolicyMin, TestCases: []int{1, 2}},
+			{Name: "s2", Score: 30, Policy: model.SubtaskPolicyMin, TestCases: []int{3, 4}, Dependencies: []string{"s1"}},
+			{Name: "s3", Score: 40, Policy: model.SubtaskPolicyMin, TestCases: []int{5}},
+		},
+	}
+
+	var ran []int
+	caseResults, subtaskResults := runTestCases(task, func(i int) *model.TestCaseResult {
+		ran = append(ran, i)
+		r := &model.TestCaseResult{TestCaseIndex: i, Status: statuses[i]}
+		if statuses[i] == model.StatusAC {
+			r.ScoreRatio = 1
+		}
+		return r
+	})
+
+	wantRan := []int{1, 5, 0}
+	if len(ran) != len(wantRan) {
+		t.Fatalf("ran = %v, want %v", ran, wantRan)
+	}
+	for i := range wantRan {
+		if ran[i] != wantRan[i] {
+			t.Fatalf("ran = %v, want %v", ran, wantRan)
+		}
+	}
+
+	for _, idx := range []int{2, 3, 4} {
+		if caseResults[idx].Status != model.StatusSkipped {
+			t.Errorf("case %d status = %q, want %q", idx, caseResults[idx].Status, model.StatusSkipped)
+		}
+		if caseResults[idx].TestCaseIndex != idx {
+			t.Errorf("case %d index = %d", idx, caseResults[idx].TestCaseIndex)
+		}
+	}
+
+	wantSubtasks := []struct {
+		status model.JudgeStatus
+		score  float64
+	}{
+		{model.StatusWA, 0},
+		{model.StatusSkipped, 0},
+		{model.StatusAC, 40},
+	}
+	if len(subtaskResults) != len(wantSubtasks) {
+		t.Fatalf("len(subtaskResults) = %d, want %d", len(subtaskResults), len(wantSubtasks))
+	}
+	for i, want := range wantSubtasks {
+		if subtaskResults[i].Status != want.status || subtaskResults[i].Score != want.score {
+			t.Errorf("subtask %s = (%q, %v), want (%q, %v)", subtaskResults[i].Name,
+				subtaskResults[i].Status, subtaskResults[i].Score, want.status, want.score)
+		}
+	}
+	if got := calculateSubtaskScore(subtaskResults); got != 40 {
+		t.Errorf("calculateSubtaskScore() = %v, want 40", got)
+	}
+}
+
+func TestScoreSubtask(t *testing.T) {
+	results := []*model.TestCaseResult{
+		{Status: model.StatusAC, ScoreRatio: 1},
+		{Status: model.StatusPC, ScoreRatio: 0.5},
+		{Status: model.StatusPC, ScoreRatio: 0.25},
+	}
+
+	tests := []struct {
+		name       string
+		policy     model.SubtaskPolicy
+		wantScore  float64
+		wantStatus model.JudgeStatus
+	}{
+		{name: "min策略", policy: model.SubtaskPolicyMin, wantScore: 0, wantStatus: model.StatusPC},
+		{name: "sum策略", policy: model.SubtaskPolicySum, wantScore: 35, wantStatus: model.StatusPC},
+		{name: "min_ratio策略", policy: model.SubtaskPolicyMinRatio, wantScore: 15, wantStatus: model.StatusPC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := model.Subtask{Name: "s", Score: 60, Policy: tt.policy, TestCases: []int{0, 1, 2}}
+			got := scoreSubtask(st, make([]model.TestCase, len(results)), results)
+			if got.Score != tt.wantScore {
+				t.Errorf("scoreSubtask() score = %v, want %v", got.Score, tt.wantScore)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("scoreSubtask() status = %q, want %q", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestScoreSubtask_SumWeightedByCaseScore(t *testing.T) {
+	cases := []model.TestCase{{Score: 10}, {Score: 90}}
+	st := model.Subtask{Name: "s", Score: 50, Policy: model.SubtaskPolicySum, TestCases: []int{0, 1}}
+
+	tests := []struct {
+		name      string
+		results   []*model.TestCaseResult
+		wantScore float64
+	}{
+		{name: "只通过10分的测试点", results: []*model.TestCaseResult{{Status: model.StatusAC}, {Status: model.StatusWA}}, wantScore: 5},
+		{name: "只通过90分的测试点", results: []*model.TestCaseResult{{Status: model.StatusWA}, {Status: model.StatusAC}}, wantScore: 45},
+		{name: "部分正确按比例加权", results: []*model.TestCaseResult{{Status: model.StatusAC}, {Status: model.StatusPC, ScoreRatio: 0.5}}, wantScore: 27.5},
+		{name: "跳过的测试点计0分", results: []*model.TestCaseResult{{Status: model.StatusAC}, nil}, wantScore: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoreSubtask(st, cases, tt.results); got.Score != tt.wantScore {
+				t.Errorf("scoreSubtask() score = %v, want %v", got.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestRunTestCases_FirstFailure(t *testing.T) {
+	statuses := []model.JudgeStatus{model.StatusAC, model.StatusWA, model.StatusAC, model.StatusTLE}
+	runCase := func(ran *[]int) func(i int) *model.TestCaseResult {
+		return func(i int) *model.TestCaseResult {
+			*ran = append(*ran, i)
+			return &model.TestCaseResult{TestCaseIndex: i, Status: statuses[i]}
+		}
+	}
+
+	tests := []struct {
+		name        string
+		mode        model.JudgeMode
+		wantRan     int
+		wantSkipped []int
+	}{
+		{name: "遇错即停", mode: model.JudgeModeFirstFailure, wantRan: 2, wantSkipped: []int{2, 3}},
+		{name: "全部运行", mode: model.JudgeModeAll, wantRan: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &model.JudgeTask{
+				Config:    model.TaskConfig{JudgeMode: tt.mode},
+				TestCases: make([]model.TestCase, len(statuses)),
+			}
+			var ran []int
+			caseResults, _ := runTestCases(task, runCase(&ran))
+			if len(ran) != tt.wantRan {
+				t.Errorf("ran %v, want %d cases", ran, tt.wantRan)
+			}
+			for _, idx := range tt.wantSkipped {
+				if caseResults[idx].Status != model.StatusSkipped {
+					t.Errorf("case %d status = %q, want %q", idx, caseResults[idx].Status, model.StatusSkipped)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTestCases_FirstFailureWithSubtasks(t *testing.T) {
+	statuses := []model.JudgeStatus{model.StatusAC, model.StatusWA, model.StatusAC, model.StatusAC}
+	task := &model.JudgeTask{
+		Config:    model.TaskConfig{JudgeMode: model.JudgeModeFirstFailure},
+		TestCases: make([]model.TestCase, len(statuses)),
+		Subtasks: []model.Subtask{
+			{Name: "s1", Score: 30, Policy: model.SubtaskPolicyMin, TestCases: []int{0, 1}},
+			{Name: "s2", Score: 30, Policy: model.SubtaskPolicyMin, TestCases: []int{2, 3}},
+			{Name: "s3", Score: 40, Policy: model.SubtaskPolicySum, TestCases: []int{1, 2}},
+		},
+	}
+	var ran []int
+	caseResults, subtaskResults := runTestCases(task, func(i int) *model.TestCaseResult {
+		ran = append(ran, i)
+		return &model.TestCaseResult{TestCaseIndex: i, Status: statuses[i], ScoreRatio: 1}
+	})
+
+	if len(ran) != 2 {
+		t.Errorf("ran %v, want cases 0 and 1 only", ran)
+	}
+	for _, idx := range []int{2, 3} {
+		if caseResults[idx].Status != model.StatusSkipped {
+			t.Errorf("case %d status = %q, want %q", idx, caseResults[idx].Status, model.StatusSkipped)
+		}
+	}
+
+	wants := []struct {
+		status model.JudgeStatus
+		score  float64
+	}{
+		{model.StatusWA, 0},
+		{model.StatusSkipped, 0}, // 测试点全部被跳过
+		{model.StatusWA, 0},      // 部分被跳过时未通过的状态优先
+	}
+	for i, want := range wants {
+		if subtaskResults[i].Status != want.status || subtaskResults[i].Score != want.score {
+			t.Errorf("subtask %s = (%q, %v), want (%q, %v)", subtaskResults[i].Name,
+				subtaskResults[i].Status, subtaskResults[i].Score, want.status, want.score)
+		}
+	}
+}
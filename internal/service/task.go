@@ -6,16 +6,19 @@ import (
 	"fmt"
 	v1 "hitwh-judge/api/calc/v1"
 	"hitwh-judge/internal/cache"
+	"hitwh-judge/internal/constants"
 	"hitwh-judge/internal/model"
-	file_util "hitwh-judge/internal/util/file"
+	"hitwh-judge/internal/problem"
 	"hitwh-judge/pkg/snowflake"
+	"io"
+	"math"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 // 单机评测并发控制
@@ -29,35 +32,116 @@ var (
 
 // 评测超时配置
 const (
-	MaxJudgeTimeout = 5 * time.Minute // 单个评测任务最大超时时间
+	MaxJudgeTimeout = 5 * time.Minute  // 单个评测任务最大超时时间
+	MaxQueueWait    = 30 * time.Second // 同步评测排队等待的最长时间
 )
 
-// AddTask 改进版的添加评测任务
+// ErrInvalidTask 评测请求参数无效，需要调用方修正请求；其他错误为评测服务内部错误
+var ErrInvalidTask = errors.New("评测请求参数无效")
+
+// ErrCallbackRequiresAsync 同步评测直接返回结果，不支持回调
+var ErrCallbackRequiresAsync = fmt.Errorf("%w: 同步评测不支持callback_url，请使用异步提交接口", ErrInvalidTask)
+
+// invalidTask 将参数校验错误标记为ErrInvalidTask
+func invalidTask(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidTask, err)
+}
+
+// AddTask 改进版的添加评测任务（同步等待评测完成）
 func AddTask(ctx context.Context, req *v1.TaskReq) (*model.JudgeResult, error) {
+	if req != nil && req.CallbackURL != "" {
+		return nil, ErrCallbackRequiresAsync
+	}
+	judgeTask, err := prepareTask(req)
+	if err != nil {
+		return nil, err
+	}
+	return executeTask(ctx, judgeTask, MaxQueueWait, nil)
+}
+
+// prepareTask 校验请求参数并构造评测任务
+// 请求指定problem_id时从题目包构造任务，否则使用请求中的测试点与评测配置
+// 参数校验失败时返回的错误满足 errors.Is(err, ErrInvalidTask)
+func prepareTask(req *v1.TaskReq) (*model.JudgeTask, error) {
 	// 1. 参数校验
 	if req == nil {
-		return nil, fmt.Errorf("req is nil")
+		return nil, fmt.Errorf("%w: req is nil", ErrInvalidTask)
 	}
 
 	// 校验必要参数
 	if req.CodeFile == "" {
-		return nil, fmt.Errorf("代码文件不能为空")
+		return nil, fmt.Errorf("%w: 代码文件不能为空", ErrInvalidTask)
+	}
+	if req.CallbackURL != "" {
+		if err := notifier.ValidateURL(req.CallbackURL); err != nil {
+			return nil, invalidTask(err)
+		}
+	}
+
+	var judgeTask *model.JudgeTask
+	var err error
+	if req.ProblemID != "" {
+		judgeTask, err = loadProblemTask(req.ProblemID, req.ProblemVersion)
+	} else {
+		judgeTask, err = newRequestTask(req)
 	}
+	if errors.Is(err, problem.ErrProblemNotFound) {
+		return nil, invalidTask(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveTaskConfig(&judgeTask.Config); err != nil {
+		return nil, invalidTask(err)
+	}
+	if (judgeTask.Config.JudgeType == model.JudgeSpecial || judgeTask.Config.JudgeType == model.JudgeInteractive) &&
+		(judgeTask.SpecialCode == nil || *judgeTask.SpecialCode == "") {
+		return nil, fmt.Errorf("%w: 特殊评测/交互评测必须提供评测程序代码", ErrInvalidTask)
+	}
+
+	taskId, err := snowflake.NextID()
+	if err != nil {
+		return nil, fmt.Errorf("生成任务ID失败: %w", err)
+	}
+	judgeTask.TaskID = taskId
+	judgeTask.Code = req.CodeFile
+	judgeTask.Config.Language = req.CodeLanguage
+	judgeTask.CreateTime = time.Now().Unix()
+	judgeTask.CallbackURL = req.CallbackURL
+
+	assignCaseScores(judgeTask.TestCases)
+	if err := validateSubtasks(judgeTask.Subtasks, len(judgeTask.TestCases)); err != nil {
+		return nil, invalidTask(err)
+	}
+	return judgeTask, nil
+}
+
+// newRequestTask 根据请求中的测试点与评测配置构造评测任务
+func newRequestTask(req *v1.TaskReq) (*model.JudgeTask, error) {
 	if len(req.CheckPoints) == 0 {
-		return nil, fmt.Errorf("测试用例不能为空")
+		return nil, fmt.Errorf("%w: 测试用例不能为空", ErrInvalidTask)
 	}
-	if req.CPULimit <= 0 || req.CPULimit > 60000 {
-		return nil, fmt.Errorf("CPU时间限制无效: %d (应在1-60000ms之间)", req.CPULimit)
+	if req.Bucket == "" {
+		return nil, fmt.Errorf("%w: 测试数据存储桶不能为空", ErrInvalidTask)
 	}
-	if req.MemLimit <= 0 || req.MemLimit > 1024*1024*1024 {
-		return nil, fmt.Errorf("内存限制无效: %d (应在1B-1GB之间)", req.MemLimit)
+	for i, checkPoint := range req.CheckPoints {
+		if checkPoint.Score < 0 {
+			return nil, fmt.Errorf("%w: 测试点%d分值无效: %d", ErrInvalidTask, i, checkPoint.Score)
+		}
 	}
 
 	config := model.DefaultTaskConfig
 	config.TimeLimit = int(req.CPULimit)
 	config.MemoryLimit = int(req.MemLimit)
-	config.Language = req.CodeLanguage
-
+	config.JudgeMode = req.JudgeMode
+	config.CompareMode = req.CompareMode
+	if req.FloatEps != nil {
+		config.FloatEps = *req.FloatEps
+	}
+	if req.FloatRelEps != nil {
+		config.FloatRelEps = *req.FloatRelEps
+	}
+	config.RevealDiff = req.RevealDiff
 	if req.JudgeType != "" && req.JudgeType == model.JudgeSpecial {
 		config.JudgeType = model.JudgeSpecial
 	} else if req.JudgeType != "" && req.JudgeType == model.JudgeInteractive {
@@ -66,39 +150,93 @@ func AddTask(ctx context.Context, req *v1.TaskReq) (*model.JudgeResult, error) {
 		config.JudgeType = model.JudgeNormal
 	}
 
-	taskId, err := snowflake.NextID()
-	if err != nil {
-		return nil, fmt.Errorf("生成任务ID失败: %w", err)
-	}
-
 	judgeTask := &model.JudgeTask{
-		TaskID:              taskId,
 		Config:              config,
-		Code:                req.CodeFile,
 		FileBucket:          req.Bucket,
 		SpecialCode:         &req.SpecialCodeFile,
 		SpecialCodeFileName: &req.SpecialCodeFileName,
-		CreateTime:          time.Now().Unix(),
 	}
 
 	for _, checkPoint := range req.CheckPoints {
 		judgeTask.TestCases = append(judgeTask.TestCases, model.TestCase{
 			InputFile:  checkPoint.InputFile,
 			OutputFile: checkPoint.OutputFile,
+			Score:      checkPoint.Score,
+			Sample:     checkPoint.Sample,
 		})
 	}
 
+	for _, st := range req.Subtasks {
+		policy := st.Policy
+		if policy == "" {
+			policy = model.SubtaskPolicyMin
+		}
+		judgeTask.Subtasks = append(judgeTask.Subtasks, model.Subtask{
+			Name:         st.Name,
+			Score:        st.Score,
+			Policy:       policy,
+			TestCases:    st.CheckPoints,
+			Dependencies: st.Dependencies,
+		})
+	}
+	return judgeTask, nil
+}
+
+// resolveTaskConfig 校验资源限制，并解析评测模式、比较模式等配置（未指定时使用默认值）
+// 浮点误差为0表示不按该种误差比较，其默认值在构造评测任务时填入
+func resolveTaskConfig(config *model.TaskConfig) error {
+	if config.TimeLimit <= 0 || config.TimeLimit > 60000 {
+		return fmt.Errorf("CPU时间限制无效: %d (应在1-60000ms之间)", config.TimeLimit)
+	}
+	if config.MemoryLimit <= 0 || config.MemoryLimit > 1024*1024*1024 {
+		return fmt.Errorf("内存限制无效: %d (应在1B-1GB之间)", config.MemoryLimit)
+	}
+	judgeMode, err := resolveJudgeMode(config.JudgeMode)
+	if err != nil {
+		return err
+	}
+	config.JudgeMode = judgeMode
+	compareMode, err := resolveCompareMode(config.CompareMode)
+	if err != nil {
+		return err
+	}
+	config.CompareMode = compareMode
+	revealDiff, err := resolveDiffReveal(config.RevealDiff)
+	if err != nil {
+		return err
+	}
+	config.RevealDiff = revealDiff
+	if config.FloatEps < 0 || config.FloatRelEps < 0 {
+		return fmt.Errorf("浮点误差不能为负数")
+	}
+	return nil
+}
+
+// executeTask 执行评测任务
+// queueWait 为获取评测槽位的最长等待时间，0表示一直等待；onStart 在获取槽位、开始评测时调用（可为nil）
+func executeTask(ctx context.Context, judgeTask *model.JudgeTask, queueWait time.Duration, onStart func()) (*model.JudgeResult, error) {
+	taskId := judgeTask.TaskID
+	config := judgeTask.Config
+
 	// 2. 并发控制：获取评测槽位
+	var queueTimeout <-chan time.Time
+	if queueWait > 0 {
+		queueTimeout = time.After(queueWait)
+	}
 	select {
 	case judgeSemaphore <- struct{}{}:
 		defer func() { <-judgeSemaphore }()
 	case <-ctx.Done():
 		return nil, fmt.Errorf("评测请求已取消")
-	case <-time.After(30 * time.Second):
+	case <-queueTimeout:
 		GetGlobalMetrics().RecordQueueTimeout()
 		return nil, fmt.Errorf("评测队列已满，请稍后重试")
 	}
 
+	if onStart != nil {
+		onStart()
+	}
+
 	// 统计活跃评测数
 	judgeMutex.Lock()
 	activeJudges++
@@ -137,6 +275,14 @@ func AddTask(ctx context.Context, req *v1.TaskReq) (*model.JudgeResult, error) {
 				return
 			}
 			resultChan <- judgeResult
+		case model.JudgeSpecial:
+			// 特殊评测与普通评测流程一致，仅结果由checker判定
+			judgeResult, err := judgeNormal(&config, judgeTask)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			resultChan <- judgeResult
 		case model.JudgeInteractive:
 			judgeResult, err := judgeInteractive(&config, judgeTask)
 			if err != nil {
@@ -145,7 +291,7 @@ func AddTask(ctx context.Context, req *v1.TaskReq) (*model.JudgeResult, error) {
 			}
 			resultChan <- judgeResult
 		default:
-			errChan <- fmt.Errorf("暂不支持特殊评测")
+			errChan <- fmt.Errorf("不支持的评测类型: %s", config.JudgeType)
 		}
 	}()
 
@@ -172,13 +318,17 @@ func AddTask(ctx context.Context, req *v1.TaskReq) (*model.JudgeResult, error) {
 // updateFinalStatus 更新最终状态（按优先级）
 func updateFinalStatus(current, newStatus model.JudgeStatus) model.JudgeStatus {
 	priority := map[model.JudgeStatus]int{
-		model.StatusSE:  6, // 系统错误优先级最高
-		model.StatusCE:  5,
-		model.StatusRE:  4,
-		model.StatusTLE: 3,
-		model.StatusMLE: 2,
-		model.StatusWA:  1,
-		model.StatusAC:  0, // AC优先级最低
+		model.StatusSE:      9, // 系统错误优先级最高
+		model.StatusCE:      8,
+		model.StatusRE:      7,
+		model.StatusTLE:     6,
+		model.StatusMLE:     5,
+		model.StatusOLE:     4,
+		model.StatusWA:      3,
+		model.StatusPE:      2,
+		model.StatusPC:      1,
+		model.StatusAC:      0, // AC优先级最低
+		model.StatusSkipped: 0, // 跳过的测试点不影响最终状态
 	}
 
 	if priority[newStatus] > priority[current] {
@@ -187,13 +337,51 @@ func updateFinalStatus(current, newStatus model.JudgeStatus) model.JudgeStatus {
 	return current
 }
 
-// calculateScore 计算总分（简单实现：AC的测试点占比）
-func calculateScore(results []model.TestCaseResult) int {
-	if len(results) == 0 {
+// assignCaseScores 为未配置分值的任务分配测试点分值
+// 所有测试点分值均为0时，平均分配100分（余数分给靠前的测试点）
+func assignCaseScores(cases []model.TestCase) {
+	if len(cases) == 0 {
+		return
+	}
+	for _, c := range cases {
+		if c.Score != 0 {
+			return
+		}
+	}
+	base, remainder := 100/len(cases), 100%len(cases)
+	for i := range cases {
+		cases[i].Score = base
+		if i < remainder {
+			cases[i].Score++
+		}
+	}
+}
+
+// caseScoreRatio 计算测试点得分比例：AC得满分，部分正确按checker给出的比例，其余为0
+func caseScoreRatio(r *model.TestCaseResult) float64 {
+	switch r.Status {
+	case model.StatusAC:
+		return 1
+	case model.StatusPC:
+		return math.Max(0, math.Min(1, r.ScoreRatio))
+	default:
 		return 0
 	}
-	acCount := countACCases(results)
-	return (acCount * 100) / len(results)
+}
+
+// scoreTestCase 根据测试点满分和评测状态计算测试点得分
+func scoreTestCase(fullScore int, r *model.TestCaseResult) {
+	r.ScoreRatio = caseScoreRatio(r)
+	r.Score = float64(fullScore) * r.ScoreRatio
+}
+
+// calculateScore 计算总分（各测试点得分之和）
+func calculateScore(results []model.TestCaseResult) float64 {
+	var total float64
+	for _, r := range results {
+		total += r.Score
+	}
+	return math.Round(total*100) / 100
 }
 
 // countACCases 统计AC的测试点数量
@@ -235,37 +423,89 @@ func normalizeString(s string) string {
 	return strings.TrimSpace(s)
 }
 
-func downloadCase(task *model.JudgeTask) (err error) {
-	testCache := cache.GetEnhancedTestFileCache()
-	for i := range task.TestCases {
-		// 下载输入文件
-		inputFilePath, err := testCache.DownloadFileByMD5WithCache(task.FileBucket, task.TestCases[i].InputFile)
-		if err != nil {
-			return err
+// downloadCase 并行下载任务的全部测试数据，最多同时下载 constants.CaseDownloadWorkers 个测试点
+// 测试点直接引用缓存或题目包中的文件而不再复制，由沙箱只读挂载；评测结束后须调用release解除固定
+func downloadCase(task *model.JudgeTask) (func(), error) {
+	releases := make([]func(), len(task.TestCases))
+	release := func() {
+		for _, r := range releases {
+			if r != nil {
+				r()
+			}
 		}
-		dstInputFile := filepath.Join(task.TempDir, fmt.Sprintf("input_%d.txt", i))
-		if err := file_util.CopyFile(inputFilePath, dstInputFile); err != nil {
+	}
+	var g errgroup.Group
+	g.SetLimit(constants.CaseDownloadWorkers)
+	for i := range task.TestCases {
+		g.Go(func() error {
+			r, err := prepareCaseFiles(task, i)
+			releases[i] = r
 			return err
-		}
-		task.TestCases[i].InputFile = dstInputFile
+		})
+	}
+	if err := g.Wait(); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
 
-		// 下载输出文件
-		outputFilePath, err := testCache.DownloadFileByMD5WithCache(task.FileBucket, task.TestCases[i].OutputFile)
-		if err != nil {
-			return err
-		}
-		dstOutputFile := filepath.Join(task.TempDir, fmt.Sprintf("output_%d.txt", i))
-		if err := file_util.CopyFile(outputFilePath, dstOutputFile); err != nil {
-			return err
-		}
-		task.TestCases[i].OutputFile = dstOutputFile
-		output, err := file_util.ReadFileToString(dstOutputFile)
-		if err != nil {
-			return err
-		}
-		task.TestCases[i].Output = normalizeString(output)
+// prepareCaseFiles 下载第i个测试点的输入输出文件，测试点改为引用其本地路径
+// 返回的release在评测结束前保持文件固定在缓存中
+func prepareCaseFiles(task *model.JudgeTask, i int) (func(), error) {
+	// 下载输入文件
+	inputFilePath, releaseInput, err := fetchCaseFile(task, task.TestCases[i].InputFile)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	if _, err := os.Stat(inputFilePath); err != nil {
+		releaseInput()
+		return nil, err
+	}
+
+	// 下载输出文件
+	outputFilePath, releaseOutput, err := fetchCaseFile(task, task.TestCases[i].OutputFile)
+	if err != nil {
+		releaseInput()
+		return nil, err
+	}
+	release := func() {
+		releaseInput()
+		releaseOutput()
+	}
+	// 完整的期望输出保留在文件中，内存中只保留预览
+	output, err := readPreview(outputFilePath, constants.OutputPreviewSize)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	task.TestCases[i].InputFile = inputFilePath
+	task.TestCases[i].OutputFile = outputFilePath
+	task.TestCases[i].Output = normalizeString(output)
+	return release, nil
+}
+
+// fetchCaseFile 获取测试数据文件的本地路径，使用完毕后须调用release
+// 引用题目包的任务直接使用包内文件，否则按MD5从存储桶下载（带缓存），release之前文件不会被缓存淘汰
+func fetchCaseFile(task *model.JudgeTask, name string) (string, func(), error) {
+	if task.ProblemID != "" {
+		return name, func() {}, nil
+	}
+	return cache.GetEnhancedTestFileCache().Acquire(task.FileBucket, name)
+}
+
+// readPreview 读取文件开头最多limit字节
+func readPreview(path string, limit int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
 func createTmpDir() (string, func(), error) {
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/problem"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -42,6 +47,18 @@ func TestUpdateFinalStatus(t *testing.T) {
 			newStatus:  model.StatusMLE,
 			wantStatus: model.StatusTLE,
 		},
+		{
+			name:       "WA -> OLE",
+			current:    model.StatusWA,
+			newStatus:  model.StatusOLE,
+			wantStatus: model.StatusOLE,
+		},
+		{
+			name:       "OLE -> MLE (MLE优先级更高)",
+			current:    model.StatusOLE,
+			newStatus:  model.StatusMLE,
+			wantStatus: model.StatusMLE,
+		},
 		{
 			name:       "MLE -> RE",
 			current:    model.StatusMLE,
@@ -66,6 +83,24 @@ func TestUpdateFinalStatus(t *testing.T) {
 			newStatus:  model.StatusAC,
 			wantStatus: model.StatusSE,
 		},
+		{
+			name:       "PE -> WA (WA优先级更高)",
+			current:    model.StatusPE,
+			newStatus:  model.StatusWA,
+			wantStatus: model.StatusWA,
+		},
+		{
+			name:       "AC -> PC",
+			current:    model.StatusAC,
+			newStatus:  model.StatusPC,
+			wantStatus: model.StatusPC,
+		},
+		{
+			name:       "PC -> PE",
+			current:    model.StatusPC,
+			newStatus:  model.StatusPE,
+			wantStatus: model.StatusPE,
+		},
 		{
 			name:       "AC -> AC",
 			current:    model.StatusAC,
@@ -89,23 +124,23 @@ func TestCalculateScore(t *testing.T) {
 	tests := []struct {
 		name    string
 		results []model.TestCaseResult
-		want    int
+		want    float64
 	}{
 		{
 			name: "全部AC",
 			results: []model.TestCaseResult{
-				{Status: model.StatusAC},
-				{Status: model.StatusAC},
-				{Status: model.StatusAC},
+				{Status: model.StatusAC, Score: 34},
+				{Status: model.StatusAC, Score: 33},
+				{Status: model.StatusAC, Score: 33},
 			},
 			want: 100,
 		},
 		{
 			name: "部分AC",
 			results: []model.TestCaseResult{
-				{Status: model.StatusAC},
+				{Status: model.StatusAC, Score: 25},
 				{Status: model.StatusWA},
-				{Status: model.StatusAC},
+				{Status: model.StatusAC, Score: 25},
 				{Status: model.StatusTLE},
 			},
 			want: 50,
@@ -124,14 +159,13 @@ func TestCalculateScore(t *testing.T) {
 			want:    0,
 		},
 		{
-			name: "1个AC，3个WA",
+			name: "部分得分",
 			results: []model.TestCaseResult{
-				{Status: model.StatusAC},
-				{Status: model.StatusWA},
-				{Status: model.StatusWA},
-				{Status: model.StatusWA},
+				{Status: model.StatusAC, Score: 40},
+				{Status: model.StatusPC, Score: 12.5},
+				{Status: model.StatusPC, Score: 1.0 / 3},
 			},
-			want: 25,
+			want: 52.83,
 		},
 	}
 
@@ -145,6 +179,212 @@ func TestCalculateScore(t *testing.T) {
 	}
 }
 
+func TestAssignCaseScores(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores []int
+		want   []int
+	}{
+		{
+			name:   "未配置分值时平均分配",
+			scores: []int{0, 0, 0, 0},
+			want:   []int{25, 25, 25, 25},
+		},
+		{
+			name:   "余数分给靠前的测试点",
+			scores: []int{0, 0, 0},
+			want:   []int{34, 33, 33},
+		},
+		{
+			name:   "已配置分值保持不变",
+			scores: []int{10, 0, 90},
+			want:   []int{10, 0, 90},
+		},
+		{
+			name:   "空测试点",
+			scores: []int{},
+			want:   []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cases := make([]model.TestCase, len(tt.scores))
+			for i, score := range tt.scores {
+				cases[i].Score = score
+			}
+			assignCaseScores(cases)
+			for i := range cases {
+				if cases[i].Score != tt.want[i] {
+					t.Errorf("case %d score = %d, want %d", i, cases[i].Score, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveTaskConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *model.TaskConfig)
+		wantErr bool
+	}{
+		{name: "默认配置", modify: func(c *model.TaskConfig) {}},
+		{name: "时间限制过大", modify: func(c *model.TaskConfig) { c.TimeLimit = 60001 }, wantErr: true},
+		{name: "内存限制为0", modify: func(c *model.TaskConfig) { c.MemoryLimit = 0 }, wantErr: true},
+		{name: "比较模式无效", modify: func(c *model.TaskConfig) { c.CompareMode = "exact" }, wantErr: true},
+		{name: "差异公开范围无效", modify: func(c *model.TaskConfig) { c.RevealDiff = "hidden" }, wantErr: true},
+		{name: "浮点误差为负", modify: func(c *model.TaskConfig) { c.FloatEps = -1 }, wantErr: true},
+		{name: "只按绝对误差比较", modify: func(c *model.TaskConfig) { c.FloatRelEps = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := model.TaskConfig{TimeLimit: 1000, MemoryLimit: 64 << 20, FloatEps: 1e-3, FloatRelEps: 1e-3}
+			want := config
+			tt.modify(&config)
+			err := resolveTaskConfig(&config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTaskConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			tt.modify(&want)
+			if config.CompareMode != model.CompareModeToken || config.RevealDiff != model.DiffRevealNone || config.JudgeMode == "" {
+				t.Errorf("resolveTaskConfig() config = %+v, want defaults", config)
+			}
+			// 浮点误差原样保留，0表示不按该种误差比较
+			if config.FloatEps != want.FloatEps || config.FloatRelEps != want.FloatRelEps {
+				t.Errorf("resolveTaskConfig() float eps = %v, %v, want %v, %v", config.FloatEps, config.FloatRelEps, want.FloatEps, want.FloatRelEps)
+			}
+		})
+	}
+}
+
+func TestLoadProblemTask(t *testing.T) {
+	defer func(s *problem.Store) { problemStore = s }(problemStore)
+	root := t.TempDir()
+	problemStore = problem.NewStore(root)
+
+	dir := filepath.Join(root, "aplusb")
+	files := map[string]string{
+		"problem.yaml": "cpu_limit: 1000\nmem_limit: 67108864\ncheck_points:\n  - {input: 1.in, output: 1.out}\n",
+		"1.in":         "1 2",
+		"1.out":        "3",
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task, err := loadProblemTask("aplusb", "")
+	if err != nil {
+		t.Fatalf("loadProblemTask() error = %v", err)
+	}
+	if task.ProblemID != "aplusb" || len(task.TestCases) != 1 || task.TestCases[0].OutputFile != filepath.Join(dir, "1.out") {
+		t.Errorf("loadProblemTask() = %+v", task)
+	}
+	// 引用题目包的任务直接使用包内文件
+	if path, _, err := fetchCaseFile(task, task.TestCases[0].InputFile); err != nil || path != filepath.Join(dir, "1.in") {
+		t.Errorf("fetchCaseFile() = %q, %v", path, err)
+	}
+
+	if _, err := loadProblemTask("missing", ""); !errors.Is(err, problem.ErrProblemNotFound) {
+		t.Errorf("loadProblemTask(missing) error = %v, want ErrProblemNotFound", err)
+	}
+}
+
+func TestDownloadCase(t *testing.T) {
+	dataDir, tempDir := t.TempDir(), t.TempDir()
+	task := &model.JudgeTask{TempDir: tempDir, ProblemID: "local"}
+	const caseCount = 20
+	for i := 0; i < caseCount; i++ {
+		input := filepath.Join(dataDir, fmt.Sprintf("%d.in", i))
+		output := filepath.Join(dataDir, fmt.Sprintf("%d.out", i))
+		if err := os.WriteFile(input, []byte(fmt.Sprint(i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(output, []byte(fmt.Sprintf("%d\r\n", i*2)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		task.TestCases = append(task.TestCases, model.TestCase{InputFile: input, OutputFile: output})
+	}
+
+	release, err := downloadCase(task)
+	if err != nil {
+		t.Fatalf("downloadCase() error = %v", err)
+	}
+	defer release()
+	for i, tc := range task.TestCases {
+		// 测试数据直接引用源文件，不再复制到任务临时目录
+		if tc.InputFile != filepath.Join(dataDir, fmt.Sprintf("%d.in", i)) {
+			t.Errorf("case %d input file = %s", i, tc.InputFile)
+		}
+		if data, err := os.ReadFile(tc.InputFile); err != nil || string(data) != fmt.Sprint(i) {
+			t.Errorf("case %d input = %q, %v", i, data, err)
+		}
+		if tc.Output != fmt.Sprint(i*2) {
+			t.Errorf("case %d output preview = %q, want %q", i, tc.Output, fmt.Sprint(i*2))
+		}
+	}
+
+	missing := &model.JudgeTask{TempDir: t.TempDir(), ProblemID: "local", TestCases: []model.TestCase{
+		{InputFile: filepath.Join(dataDir, "missing.in"), OutputFile: filepath.Join(dataDir, "0.out")},
+	}}
+	if _, err := downloadCase(missing); err == nil {
+		t.Error("downloadCase() with missing file error = nil, want error")
+	}
+}
+
+func TestScoreTestCase(t *testing.T) {
+	tests := []struct {
+		name      string
+		fullScore int
+		result    model.TestCaseResult
+		want      float64
+	}{
+		{
+			name:      "AC得满分",
+			fullScore: 10,
+			result:    model.TestCaseResult{Status: model.StatusAC},
+			want:      10,
+		},
+		{
+			name:      "WA不得分",
+			fullScore: 10,
+			result:    model.TestCaseResult{Status: model.StatusWA, ScoreRatio: 0.5},
+			want:      0,
+		},
+		{
+			name:      "部分正确按比例得分",
+			fullScore: 10,
+			result:    model.TestCaseResult{Status: model.StatusPC, ScoreRatio: 0.3},
+			want:      3,
+		},
+		{
+			name:      "比例超过1时截断",
+			fullScore: 10,
+			result:    model.TestCaseResult{Status: model.StatusPC, ScoreRatio: 1.5},
+			want:      10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.result
+			scoreTestCase(tt.fullScore, &r)
+			if r.Score != tt.want {
+				t.Errorf("scoreTestCase() score = %v, want %v", r.Score, tt.want)
+			}
+		})
+	}
+}
+
 func TestCountACCases(t *testing.T) {
 	tests := []struct {
 		name    string
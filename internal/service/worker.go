@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/queue"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 评测工作协程配置
+const (
+	MaxDeliveryAttempts = 3                // 任务最大投递次数，超过后直接判为失败（避免反复导致进程崩溃的任务无限重试）
+	HeartbeatInterval   = 30 * time.Second // 延长可见性超时的心跳间隔
+	dequeueRetryDelay   = time.Second      // 出队失败后的重试间隔
+)
+
+// StartWorkers 启动n个评测工作协程消费评测队列，ctx结束后退出
+// 多个评测进程可各自启动工作协程共同消费同一个Redis队列
+func StartWorkers(ctx context.Context, n int) {
+	for i := 0; i < n; i++ {
+		go runWorker(ctx, i)
+	}
+	zap.L().Info("评测工作协程已启动", zap.Int("workers", n))
+}
+
+// runWorker 循环从队列获取并处理评测任务
+func runWorker(ctx context.Context, workerID int) {
+	for {
+		d, err := taskQueue.Dequeue(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			zap.L().Error("获取评测任务失败", zap.Int("worker", workerID), zap.Error(err))
+			time.Sleep(dequeueRetryDelay)
+			continue
+		}
+		processDelivery(ctx, d)
+	}
+}
+
+// processDelivery 执行一次任务投递并写回结果
+// 结果写入存储后才确认任务，进程在此之前崩溃时任务会被重新投递
+func processDelivery(ctx context.Context, d *queue.Delivery) {
+	task := d.Task
+	record := model.TaskRecord{
+		TaskID:     task.TaskID,
+		CreateTime: time.Unix(task.CreateTime, 0),
+	}
+
+	if d.Attempts > MaxDeliveryAttempts {
+		zap.L().Error("评测任务投递次数过多，放弃评测",
+			zap.Int64("task_id", task.TaskID),
+			zap.Int("attempts", d.Attempts),
+		)
+		record.State = model.TaskStateFailed
+		record.Error = "评测任务多次执行失败，已放弃评测"
+		saveTaskRecord(ctx, &record)
+		publishEvent(snapshotEvent(&record))
+		notifyCallback(task, &record)
+		ackDelivery(ctx, d)
+		return
+	}
+
+	stopHeartbeat := startHeartbeat(ctx, d)
+	judgeResult, err := executeTask(ctx, task, 0, func() {
+		record.State = model.TaskStateRunning
+		saveTaskRecord(ctx, &record)
+		publishEvent(snapshotEvent(&record))
+	})
+	stopHeartbeat()
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// 进程退出导致评测中断，不确认任务，由其他进程重新评测
+		return
+	}
+	if err != nil {
+		record.State = model.TaskStateFailed
+		record.Error = err.Error()
+	} else {
+		record.State = model.TaskStateFinished
+		record.Result = judgeResult
+	}
+	// 先保存结果再发布最终事件，保证订阅者收到最终事件或能查询到最终结果
+	saveTaskRecord(ctx, &record)
+	publishEvent(snapshotEvent(&record))
+	notifyCallback(task, &record)
+	ackDelivery(ctx, d)
+}
+
+// startHeartbeat 定期延长任务的可见性超时，返回停止函数
+func startHeartbeat(ctx context.Context, d *queue.Delivery) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := taskQueue.Extend(ctx, d); err != nil {
+					zap.L().Warn("延长评测任务可见性超时失败", zap.Int64("task_id", d.Task.TaskID), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ackDelivery 确认任务，失败时仅记录日志（任务会被重新投递）
+func ackDelivery(ctx context.Context, d *queue.Delivery) {
+	if err := taskQueue.Ack(ctx, d); err != nil {
+		zap.L().Error("确认评测任务失败", zap.Int64("task_id", d.Task.TaskID), zap.Error(err))
+	}
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"hitwh-judge/internal/model"
+	"sync"
+	"time"
+)
+
+// MemoryStore 基于进程内存的结果存储
+// 已结束的任务在保留时间过后被清理，服务重启后结果丢失
+type MemoryStore struct {
+	mu        sync.RWMutex
+	records   map[int64]*model.TaskRecord
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+// NewMemoryStore 创建内存结果存储
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		records:   make(map[int64]*model.TaskRecord),
+		ttl:       ttl,
+		lastSweep: time.Now(),
+	}
+}
+
+// Save 保存任务记录
+func (s *MemoryStore) Save(_ context.Context, record *model.TaskRecord) error {
+	copied := *record
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.TaskID] = &copied
+
+	// 顺带清理过期记录，避免单独启动清理协程
+	if now := time.Now(); now.Sub(s.lastSweep) > s.ttl {
+		for id, r := range s.records {
+			if s.expired(r, now) {
+				delete(s.records, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+// Get 获取任务记录
+func (s *MemoryStore) Get(_ context.Context, taskID int64) (*model.TaskRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[taskID]
+	if !ok || s.expired(record, time.Now()) {
+		return nil, ErrTaskNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// expired 判断已结束的任务是否超过保留时间
+func (s *MemoryStore) expired(record *model.TaskRecord, now time.Time) bool {
+	return record.IsDone() && now.Sub(record.UpdateTime) > s.ttl
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"hitwh-judge/internal/model"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(time.Hour)
+
+	if _, err := s.Get(ctx, 1); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("Get() on empty store error = %v, want ErrTaskNotFound", err)
+	}
+
+	record := &model.TaskRecord{TaskID: 1, State: model.TaskStatePending, UpdateTime: time.Now()}
+	if err := s.Save(ctx, record); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 保存后修改原记录不应影响存储内容
+	record.State = model.TaskStateRunning
+	got, err := s.Get(ctx, 1)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.State != model.TaskStatePending {
+		t.Errorf("Get() state = %q, want %q", got.State, model.TaskStatePending)
+	}
+}
+
+func TestMemoryStore_Expire(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(time.Minute)
+
+	old := time.Now().Add(-2 * time.Minute)
+	_ = s.Save(ctx, &model.TaskRecord{TaskID: 1, State: model.TaskStateFinished, UpdateTime: old})
+	_ = s.Save(ctx, &model.TaskRecord{TaskID: 2, State: model.TaskStateRunning, UpdateTime: old})
+
+	if _, err := s.Get(ctx, 1); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Get() expired finished task error = %v, want ErrTaskNotFound", err)
+	}
+	// 未结束的任务不过期
+	if _, err := s.Get(ctx, 2); err != nil {
+		t.Errorf("Get() running task error = %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于Redis的结果存储，多个评测进程共享同一份任务状态
+type RedisStore struct {
+	rdb       *redis.Client
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewRedisStore 创建Redis结果存储
+func NewRedisStore(rdb *redis.Client, keyPrefix string, ttl time.Duration) *RedisStore {
+	return &RedisStore{rdb: rdb, keyPrefix: keyPrefix, ttl: ttl}
+}
+
+// key 任务记录对应的Redis键
+func (s *RedisStore) key(taskID int64) string {
+	return s.keyPrefix + ":result:" + strconv.FormatInt(taskID, 10)
+}
+
+// Save 保存任务记录，已结束的任务在保留时间后过期
+func (s *RedisStore) Save(ctx context.Context, record *model.TaskRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("序列化任务记录失败: %w", err)
+	}
+
+	var expiration time.Duration
+	if record.IsDone() {
+		expiration = s.ttl
+	}
+	if err := s.rdb.Set(ctx, s.key(record.TaskID), data, expiration).Err(); err != nil {
+		return fmt.Errorf("保存任务记录失败: %w", err)
+	}
+	return nil
+}
+
+// Get 获取任务记录
+func (s *RedisStore) Get(ctx context.Context, taskID int64) (*model.TaskRecord, error) {
+	data, err := s.rdb.Get(ctx, s.key(taskID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取任务记录失败: %w", err)
+	}
+
+	var record model.TaskRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析任务记录失败: %w", err)
+	}
+	return &record, nil
+}
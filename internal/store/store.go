@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hitwh-judge/internal/model"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// ErrTaskNotFound 评测任务不存在（或已过期）
+var ErrTaskNotFound = errors.New("评测任务不存在")
+
+// 结果存储类型
+const (
+	TypeMemory = "memory" // 进程内存（默认）
+	TypeRedis  = "redis"  // Redis，多个评测进程共享
+)
+
+// 默认配置
+const (
+	DefaultResultTTL = time.Hour // 已结束任务结果的默认保留时间
+	DefaultKeyPrefix = "judge"   // Redis键前缀
+)
+
+// ResultStore 评测任务结果存储
+type ResultStore interface {
+	// Save 保存（覆盖）任务记录
+	Save(ctx context.Context, record *model.TaskRecord) error
+	// Get 获取任务记录，不存在时返回 ErrTaskNotFound
+	Get(ctx context.Context, taskID int64) (*model.TaskRecord, error)
+}
+
+// New 根据配置创建结果存储
+// 配置项：result_store.type（默认memory）、result_store.ttl（秒，默认3600）、result_store.key_prefix
+func New(cfg *viper.Viper, rdb *redis.Client) (ResultStore, error) {
+	ttl := time.Duration(cfg.GetInt("result_store.ttl")) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultResultTTL
+	}
+
+	switch storeType := cfg.GetString("result_store.type"); storeType {
+	case "", TypeMemory:
+		return NewMemoryStore(ttl), nil
+	case TypeRedis:
+		if rdb == nil {
+			return nil, fmt.Errorf("Redis结果存储需要先初始化Redis连接")
+		}
+		keyPrefix := cfg.GetString("result_store.key_prefix")
+		if keyPrefix == "" {
+			keyPrefix = DefaultKeyPrefix
+		}
+		return NewRedisStore(rdb, keyPrefix, ttl), nil
+	default:
+		return nil, fmt.Errorf("不支持的结果存储类型: %s", storeType)
+	}
+}
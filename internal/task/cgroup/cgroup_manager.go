@@ -9,10 +9,17 @@ import (
 	"strings"
 )
 
+// DefaultRoot 评测cgroup的默认父目录（需要cgroup v2，且评测进程对其有写权限）
+const DefaultRoot = "/sys/fs/cgroup/judge"
+
+// CPUPeriodUS cpu.max的调度周期（微秒）
+const CPUPeriodUS = 100000
+
 // ResourceUsage 表示资源使用情况
 type ResourceUsage struct {
-	CPUUsec int64 // CPU使用时间（微秒）
-	MemPeak int64 // 内存峰值（字节）
+	CPUUsec   int64 // CPU使用时间（微秒）
+	MemPeak   int64 // 内存峰值（字节）
+	OOMKilled bool  // 是否有进程因内存超限被终止
 }
 
 // CgroupManager cgroup管理器
@@ -28,21 +35,37 @@ func detectSelfCgroup() string {
 }
 func enableControllers(cgroupPath string) error {
 	ctrl := filepath.Join(cgroupPath, "cgroup.subtree_control")
-	return os.WriteFile(ctrl, []byte("+cpu +memory"), 0644)
+	return os.WriteFile(ctrl, []byte("+cpu +memory +pids"), 0644)
 }
 
-// NewCgroupManager 创建新的cgroup管理器
-func NewCgroupManager(id string) (*CgroupManager, error) {
-	// path := filepath.Join(detectSelfCgroup(), "judge")
-	// enableControllers(path)
-	// path = filepath.Join(path, id)
+// NewCgroupManager 在root下创建名为id的cgroup
+// root不存在时自动创建，并为其子cgroup启用cpu、memory、pids控制器
+func NewCgroupManager(root string, id string) (*CgroupManager, error) {
+	if root == "" {
+		root = DefaultRoot
+	}
+	// 父目录必须位于cgroup v2层级中，否则MkdirAll只会创建普通目录
+	controllers, err := os.ReadFile(filepath.Join(filepath.Dir(root), "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not available at %s: %w", filepath.Dir(root), err)
+	}
+	if !strings.Contains(string(controllers), "memory") {
+		return nil, fmt.Errorf("memory controller is not available at %s", filepath.Dir(root))
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup root: %w", err)
+	}
+	if err := enableControllers(root); err != nil {
+		return nil, fmt.Errorf("failed to enable cgroup controllers: %w", err)
+	}
 
-	// if err := os.MkdirAll(path, 0777); err != nil {
-	// 	return nil, fmt.Errorf("failed to create cgroup: %w", err)
-	// }
+	path := filepath.Join(root, id)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
 
 	return &CgroupManager{
-		cgroupPath: "path",
+		cgroupPath: path,
 	}, nil
 }
 
@@ -67,6 +90,9 @@ func (cm *CgroupManager) SetLimits(cpuLimitUS int64, memLimitBytes int64) error
 		return fmt.Errorf("failed to set memory limit: %w", err)
 	}
 
+	// 禁用swap，避免内存限制被swap绕过（未启用swap时文件不存在）
+	_ = ioutil.WriteFile(filepath.Join(cm.cgroupPath, "memory.swap.max"), []byte("0"), 0644)
+
 	// 启用内存压力事件
 	if err := ioutil.WriteFile(
 		filepath.Join(cm.cgroupPath, "memory.pressure"),
@@ -79,6 +105,29 @@ func (cm *CgroupManager) SetLimits(cpuLimitUS int64, memLimitBytes int64) error
 	return nil
 }
 
+// SetPidsLimit 设置cgroup内的最大进程（线程）数
+func (cm *CgroupManager) SetPidsLimit(max int64) error {
+	if err := ioutil.WriteFile(
+		filepath.Join(cm.cgroupPath, "pids.max"),
+		[]byte(strconv.FormatInt(max, 10)),
+		0644,
+	); err != nil {
+		return fmt.Errorf("failed to set pids limit: %w", err)
+	}
+	return nil
+}
+
+// OpenProcs 以写方式打开cgroup.procs
+// 持有该文件的进程向其写入"0"即可将自身加入cgroup，权限以打开时的身份为准
+func (cm *CgroupManager) OpenProcs() (*os.File, error) {
+	return os.OpenFile(filepath.Join(cm.cgroupPath, "cgroup.procs"), os.O_WRONLY, 0)
+}
+
+// Kill 终止cgroup内的所有进程
+func (cm *CgroupManager) Kill() error {
+	return ioutil.WriteFile(filepath.Join(cm.cgroupPath, "cgroup.kill"), []byte("1"), 0644)
+}
+
 // AddProcessToCgroup 将进程添加到cgroup
 func (cm *CgroupManager) AddProcessToCgroup(pid int) error {
 	return ioutil.WriteFile(
@@ -116,9 +165,20 @@ func (cm *CgroupManager) ReadUsage() (*ResourceUsage, error) {
 		return nil, fmt.Errorf("failed to parse memory peak: %w", err)
 	}
 
+	// memory.events 中 oom_kill 非0表示有进程因内存超限被终止
+	var oomKills int64
+	if events, err := ioutil.ReadFile(filepath.Join(cm.cgroupPath, "memory.events")); err == nil {
+		for _, line := range strings.Split(string(events), "\n") {
+			if strings.HasPrefix(line, "oom_kill ") {
+				fmt.Sscanf(line, "oom_kill %d", &oomKills)
+			}
+		}
+	}
+
 	return &ResourceUsage{
-		CPUUsec: cpuUsec,
-		MemPeak: memPeak,
+		CPUUsec:   cpuUsec,
+		MemPeak:   memPeak,
+		OOMKilled: oomKills > 0,
 	}, nil
 }
 
@@ -0,0 +1,109 @@
+package result
+
+import (
+	"fmt"
+	"hitwh-judge/internal/model"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// testlib 约定的checker退出码
+const (
+	TestlibExitOK     = 0 // 答案正确
+	TestlibExitWA     = 1 // 答案错误
+	TestlibExitPE     = 2 // 格式错误
+	TestlibExitFail   = 3 // checker自身出错（如标准答案不合法）
+	TestlibExitPoints = 7 // 部分得分（quitp，信息以 "points <分数>" 开头）
+
+	// TestlibPCBaseExitCode _pc(n) 的基准退出码，checker以 50+n 退出表示得到 n% 的分数
+	TestlibPCBaseExitCode = 50
+	testlibPCMaxExitCode  = TestlibPCBaseExitCode + 100
+)
+
+// maxCheckerMessageSize checker信息最大长度
+const maxCheckerMessageSize = 1024
+
+// CheckerVerdict checker（或交互程序）的判定结果
+type CheckerVerdict struct {
+	Status     model.JudgeStatus // 测试点状态
+	Message    string            // 整理后的checker信息
+	ScoreRatio float64           // 得分比例（0-1），AC为1
+}
+
+// ParseCheckerResult 将testlib风格的checker退出码转换为测试点判定结果
+func ParseCheckerResult(exitCode int, message string) CheckerVerdict {
+	message = normalizeCheckerMessage(message)
+
+	switch {
+	case exitCode == TestlibExitOK:
+		return CheckerVerdict{Status: model.StatusAC, Message: message, ScoreRatio: 1}
+	case exitCode == TestlibExitWA:
+		return CheckerVerdict{Status: model.StatusWA, Message: message}
+	case exitCode == TestlibExitPE:
+		return CheckerVerdict{Status: model.StatusPE, Message: message}
+	case exitCode == TestlibExitFail:
+		return CheckerVerdict{Status: model.StatusSE, Message: message}
+	case exitCode == TestlibExitPoints:
+		ratio, ok := parsePoints(message)
+		if !ok {
+			return CheckerVerdict{Status: model.StatusSE, Message: fmt.Sprintf("无法解析checker给出的分数: %s", message)}
+		}
+		if ratio < 0 || ratio > 1 {
+			return CheckerVerdict{Status: model.StatusSE, Message: fmt.Sprintf("checker给出的分数超出[0,1]范围: %s", message)}
+		}
+		return partialVerdict(ratio, message)
+	case IsPartialExitCode(exitCode):
+		return partialVerdict(float64(exitCode-TestlibPCBaseExitCode)/100, message)
+	default:
+		// 未知退出码视为checker异常，避免误判为AC
+		return CheckerVerdict{
+			Status:  model.StatusSE,
+			Message: fmt.Sprintf("checker返回未知退出码 %d: %s", exitCode, message),
+		}
+	}
+}
+
+// IsPartialExitCode 判断退出码是否表示部分得分
+func IsPartialExitCode(exitCode int) bool {
+	return exitCode == TestlibExitPoints ||
+		(exitCode >= TestlibPCBaseExitCode && exitCode <= testlibPCMaxExitCode)
+}
+
+// partialVerdict 根据得分比例生成判定结果，满分视为AC，零分视为WA
+func partialVerdict(ratio float64, message string) CheckerVerdict {
+	switch {
+	case ratio >= 1:
+		return CheckerVerdict{Status: model.StatusAC, Message: message, ScoreRatio: 1}
+	case ratio <= 0:
+		return CheckerVerdict{Status: model.StatusWA, Message: message}
+	default:
+		return CheckerVerdict{Status: model.StatusPC, Message: message, ScoreRatio: ratio}
+	}
+}
+
+// parsePoints 解析quitp输出的分数
+// 与testlib的quitp约定一致，分数为[0,1]内的得分比例，范围由调用方校验
+func parsePoints(message string) (float64, bool) {
+	fields := strings.Fields(message)
+	if len(fields) > 0 && fields[0] == "points" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return 0, false
+	}
+	points, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || math.IsNaN(points) {
+		return 0, false
+	}
+	return points, true
+}
+
+// normalizeCheckerMessage 清理并截断checker信息
+func normalizeCheckerMessage(message string) string {
+	message = normalizeString(message)
+	if len(message) > maxCheckerMessageSize {
+		message = message[:maxCheckerMessageSize] + "..."
+	}
+	return strings.ToValidUTF8(message, "")
+}
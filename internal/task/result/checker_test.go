@@ -0,0 +1,136 @@
+package result
+
+import (
+	"hitwh-judge/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestParseCheckerResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		exitCode   int
+		message    string
+		wantStatus model.JudgeStatus
+		wantMsg    string
+		wantRatio  float64
+	}{
+		{
+			name:       "答案正确",
+			exitCode:   TestlibExitOK,
+			message:    "ok 3 numbers\n",
+			wantStatus: model.StatusAC,
+			wantMsg:    "ok 3 numbers",
+			wantRatio:  1,
+		},
+		{
+			name:       "答案错误",
+			exitCode:   TestlibExitWA,
+			message:    "wrong answer 1st numbers differ - expected: '3', found: '4'",
+			wantStatus: model.StatusWA,
+			wantMsg:    "wrong answer 1st numbers differ - expected: '3', found: '4'",
+		},
+		{
+			name:       "格式错误",
+			exitCode:   TestlibExitPE,
+			message:    "wrong output format Unexpected end of file",
+			wantStatus: model.StatusPE,
+			wantMsg:    "wrong output format Unexpected end of file",
+		},
+		{
+			name:       "checker失败",
+			exitCode:   TestlibExitFail,
+			message:    "FAIL answer file is invalid",
+			wantStatus: model.StatusSE,
+			wantMsg:    "FAIL answer file is invalid",
+		},
+		{
+			name:       "quitp部分得分",
+			exitCode:   TestlibExitPoints,
+			message:    "points 0.5 half correct",
+			wantStatus: model.StatusPC,
+			wantMsg:    "points 0.5 half correct",
+			wantRatio:  0.5,
+		},
+		{
+			name:       "quitp分数超过1视为checker错误",
+			exitCode:   TestlibExitPoints,
+			message:    "points 1.5",
+			wantStatus: model.StatusSE,
+			wantMsg:    "checker给出的分数超出[0,1]范围: points 1.5",
+		},
+		{
+			name:       "quitp百分制分数视为checker错误",
+			exitCode:   TestlibExitPoints,
+			message:    "points 100",
+			wantStatus: model.StatusSE,
+			wantMsg:    "checker给出的分数超出[0,1]范围: points 100",
+		},
+		{
+			name:       "quitp负分视为checker错误",
+			exitCode:   TestlibExitPoints,
+			message:    "points -0.5",
+			wantStatus: model.StatusSE,
+			wantMsg:    "checker给出的分数超出[0,1]范围: points -0.5",
+		},
+		{
+			name:       "quitp满分视为AC",
+			exitCode:   TestlibExitPoints,
+			message:    "points 1",
+			wantStatus: model.StatusAC,
+			wantMsg:    "points 1",
+			wantRatio:  1,
+		},
+		{
+			name:       "quitp零分视为WA",
+			exitCode:   TestlibExitPoints,
+			message:    "points 0",
+			wantStatus: model.StatusWA,
+			wantMsg:    "points 0",
+		},
+		{
+			name:       "quitp分数无法解析",
+			exitCode:   TestlibExitPoints,
+			message:    "points abc",
+			wantStatus: model.StatusSE,
+			wantMsg:    "无法解析checker给出的分数: points abc",
+		},
+		{
+			name:       "_pc(25)",
+			exitCode:   TestlibPCBaseExitCode + 25,
+			message:    "partially correct (25)",
+			wantStatus: model.StatusPC,
+			wantMsg:    "partially correct (25)",
+			wantRatio:  0.25,
+		},
+		{
+			name:       "未知退出码",
+			exitCode:   42,
+			message:    "???",
+			wantStatus: model.StatusSE,
+			wantMsg:    "checker返回未知退出码 42: ???",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseCheckerResult(tt.exitCode, tt.message)
+			if got.Status != tt.wantStatus {
+				t.Errorf("ParseCheckerResult(%d) status = %q, want %q", tt.exitCode, got.Status, tt.wantStatus)
+			}
+			if got.Message != tt.wantMsg {
+				t.Errorf("ParseCheckerResult(%d) message = %q, want %q", tt.exitCode, got.Message, tt.wantMsg)
+			}
+			if got.ScoreRatio != tt.wantRatio {
+				t.Errorf("ParseCheckerResult(%d) ratio = %v, want %v", tt.exitCode, got.ScoreRatio, tt.wantRatio)
+			}
+		})
+	}
+}
+
+func TestParseCheckerResult_TruncateMessage(t *testing.T) {
+	got := ParseCheckerResult(TestlibExitWA, strings.Repeat("a", maxCheckerMessageSize*2))
+	if len(got.Message) != maxCheckerMessageSize+len("...") {
+		t.Errorf("message length = %d, want %d", len(got.Message), maxCheckerMessageSize+len("..."))
+	}
+}
@@ -1,25 +1,90 @@
 package result
 
-import "strings"
+import (
+	"hitwh-judge/internal/model"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 type Comparator struct {
-	strict bool
+	mode   model.CompareMode
+	absEps float64 // float模式的绝对误差
+	relEps float64 // float模式的相对误差
 }
 
+// NewComparator 创建比较器，strict为true时严格比较，否则按词比较
 func NewComparator(strict bool) *Comparator {
+	if strict {
+		return NewModeComparator(model.CompareModeStrict)
+	}
+	return NewModeComparator(model.CompareModeToken)
+}
+
+// NewModeComparator 创建指定比较模式的比较器，未知模式按词比较
+func NewModeComparator(mode model.CompareMode) *Comparator {
+	return &Comparator{
+		mode: mode,
+	}
+}
+
+// NewFloatComparator 创建浮点比较器
+// 数值在绝对误差absEps或相对误差relEps范围内视为相等，非数值的词严格比较
+func NewFloatComparator(absEps float64, relEps float64) *Comparator {
 	return &Comparator{
-		strict: strict,
+		mode:   model.CompareModeFloat,
+		absEps: absEps,
+		relEps: relEps,
 	}
 }
 
-// Compare 比较程序输出和标准输出
+// Compare 比较程序输出和标准输出，仅在判定为AC时返回true
 func (c *Comparator) Compare(programOutput, expectedOutput string) bool {
-	if c.strict {
-		return normalizeString(programOutput) == normalizeString(expectedOutput)
+	return c.Judge(programOutput, expectedOutput) == model.StatusAC
+}
+
+// Judge 比较程序输出和标准输出，返回AC、PE或WA
+// strict与line模式下，按词比较相同但空白或换行布局不同时判为PE
+func (c *Comparator) Judge(programOutput, expectedOutput string) model.JudgeStatus {
+	var match bool
+	switch c.mode {
+	case model.CompareModeStrict:
+		match = equalBytes(programOutput, expectedOutput)
+	case model.CompareModeLine:
+		match = equalLines(programOutput, expectedOutput)
+	case model.CompareModeFloat:
+		if !c.equalFloatTokens(programOutput, expectedOutput) {
+			return model.StatusWA
+		}
+		return model.StatusAC
+	default:
+		match = equalTokens(programOutput, expectedOutput)
+		if !match {
+			return model.StatusWA
+		}
+	}
+
+	if match {
+		return model.StatusAC
 	}
-	// 模糊比较（忽略多余空格和换行）
-	progOut := strings.Fields(normalizeString(programOutput))
-	expOut := strings.Fields(normalizeString(expectedOutput))
+	if equalTokens(programOutput, expectedOutput) {
+		return model.StatusPE
+	}
+	return model.StatusWA
+}
+
+// fields 按空白切分词，空白的定义与流式比较相同（见isSpace）
+func fields(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r < utf8.RuneSelf && isSpace(byte(r))
+	})
+}
+
+// equalTokens 按空白分隔的词比较（忽略多余空格和换行）
+func equalTokens(programOutput, expectedOutput string) bool {
+	progOut := fields(programOutput)
+	expOut := fields(expectedOutput)
 	if len(progOut) != len(expOut) {
 		return false
 	}
@@ -31,6 +96,71 @@ func (c *Comparator) Compare(programOutput, expectedOutput string) bool {
 	return true
 }
 
+// equalFloatTokens 按词比较，两个词均为有限数值时允许误差
+func (c *Comparator) equalFloatTokens(programOutput, expectedOutput string) bool {
+	progOut := fields(programOutput)
+	expOut := fields(expectedOutput)
+	if len(progOut) != len(expOut) {
+		return false
+	}
+	for i := range progOut {
+		if progOut[i] == expOut[i] {
+			continue
+		}
+		if !c.equalFloat(progOut[i], expOut[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalFloat 判断两个数值是否在误差范围内，任一方不是有限数值时返回false
+func (c *Comparator) equalFloat(programToken, expectedToken string) bool {
+	got, err := strconv.ParseFloat(programToken, 64)
+	if err != nil || math.IsNaN(got) || math.IsInf(got, 0) {
+		return false
+	}
+	want, err := strconv.ParseFloat(expectedToken, 64)
+	if err != nil || math.IsNaN(want) || math.IsInf(want, 0) {
+		return false
+	}
+	diff := math.Abs(got - want)
+	return diff <= c.absEps || diff <= c.relEps*math.Abs(want)
+}
+
+// equalLines 逐行比较，忽略每行末尾的空白和输出末尾的空行
+func equalLines(programOutput, expectedOutput string) bool {
+	progLines := splitLines(programOutput)
+	expLines := splitLines(expectedOutput)
+	if len(progLines) != len(expLines) {
+		return false
+	}
+	for i := range progLines {
+		if progLines[i] != expLines[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLines 按行切分输出，去除行末空白和末尾空行
+func splitLines(s string) []string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// equalBytes 逐字节比较，不折叠CRLF、不去除空白
+// 唯一的例外是忽略输出末尾的一个换行符，程序或答案文件末尾有无换行不影响结果
+func equalBytes(programOutput, expectedOutput string) bool {
+	return strings.TrimSuffix(programOutput, "\n") == strings.TrimSuffix(expectedOutput, "\n")
+}
+
 // normalizeString 清理字符串
 func normalizeString(s string) string {
 	s = strings.ReplaceAll(s, "\r\n", "\n")
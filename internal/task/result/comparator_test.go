@@ -1,6 +1,7 @@
 package result
 
 import (
+	"hitwh-judge/internal/model"
 	"testing"
 )
 
@@ -20,16 +21,28 @@ func TestComparator_Compare_Strict(t *testing.T) {
 			want:           true,
 		},
 		{
-			name:           "忽略首尾空白",
-			programOutput:  "  Hello World  \n",
+			name:           "忽略末尾的一个换行符",
+			programOutput:  "Hello World\n",
 			expectedOutput: "Hello World",
 			want:           true,
 		},
+		{
+			name:           "首尾空白不同",
+			programOutput:  "  Hello World  \n",
+			expectedOutput: "Hello World",
+			want:           false,
+		},
+		{
+			name:           "末尾多余空行",
+			programOutput:  "Hello World\n\n",
+			expectedOutput: "Hello World\n",
+			want:           false,
+		},
 		{
 			name:           "Windows换行符",
 			programOutput:  "Hello\r\nWorld",
 			expectedOutput: "Hello\nWorld",
-			want:           true,
+			want:           false,
 		},
 		{
 			name:           "内容不同",
@@ -175,6 +188,73 @@ func TestNormalizeString(t *testing.T) {
 	}
 }
 
+func TestComparator_Judge(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           model.CompareMode
+		programOutput  string
+		expectedOutput string
+		want           model.JudgeStatus
+	}{
+		{name: "严格-完全相同", mode: model.CompareModeStrict, programOutput: "1 2\n3", expectedOutput: "1 2\n3\n", want: model.StatusAC},
+		{name: "严格-多余空格", mode: model.CompareModeStrict, programOutput: "1  2\n3", expectedOutput: "1 2\n3", want: model.StatusPE},
+		{name: "严格-行末空格", mode: model.CompareModeStrict, programOutput: "1 2 \n3", expectedOutput: "1 2\n3", want: model.StatusPE},
+		{name: "严格-内容不同", mode: model.CompareModeStrict, programOutput: "1 2\n4", expectedOutput: "1 2\n3", want: model.StatusWA},
+		{name: "逐行-行末空格", mode: model.CompareModeLine, programOutput: "1 2 \r\n3\t\n\n", expectedOutput: "1 2\n3", want: model.StatusAC},
+		{name: "逐行-换行布局不同", mode: model.CompareModeLine, programOutput: "1\n2\n3", expectedOutput: "1 2\n3", want: model.StatusPE},
+		{name: "逐行-行首空格", mode: model.CompareModeLine, programOutput: " 1 2\n3", expectedOutput: "1 2\n3", want: model.StatusPE},
+		{name: "逐行-内容不同", mode: model.CompareModeLine, programOutput: "1 2\n3 4", expectedOutput: "1 2\n3", want: model.StatusWA},
+		{name: "按词-换行布局不同", mode: model.CompareModeToken, programOutput: "1\n2\n3", expectedOutput: "1 2\n3", want: model.StatusAC},
+		{name: "按词-内容不同", mode: model.CompareModeToken, programOutput: "1 2", expectedOutput: "1 2 3", want: model.StatusWA},
+		{name: "未指定模式按词比较", mode: "", programOutput: "1  2", expectedOutput: "1 2", want: model.StatusAC},
+		// U+00A0不是分隔词的空白，各模式的分词与流式比较一致
+		{name: "严格-非ASCII空格", mode: model.CompareModeStrict, programOutput: "1\u00a02\n3", expectedOutput: "1 2\n3", want: model.StatusWA},
+		{name: "逐行-非ASCII空格", mode: model.CompareModeLine, programOutput: "1\u00a02\n3", expectedOutput: "1 2\n3", want: model.StatusWA},
+		{name: "按词-非ASCII空格", mode: model.CompareModeToken, programOutput: "1\u00a02\n3", expectedOutput: "1 2\n3", want: model.StatusWA},
+		{name: "浮点-非ASCII空格", mode: model.CompareModeFloat, programOutput: "1\u00852", expectedOutput: "1 2", want: model.StatusWA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewModeComparator(tt.mode).Judge(tt.programOutput, tt.expectedOutput)
+			if got != tt.want {
+				t.Errorf("Judge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloatComparator_Judge(t *testing.T) {
+	comparator := NewFloatComparator(1e-6, 1e-6)
+
+	tests := []struct {
+		name           string
+		programOutput  string
+		expectedOutput string
+		want           model.JudgeStatus
+	}{
+		{name: "完全相同", programOutput: "3.141593", expectedOutput: "3.141593", want: model.StatusAC},
+		{name: "绝对误差内", programOutput: "0.3333333", expectedOutput: "0.333333", want: model.StatusAC},
+		{name: "超出绝对误差", programOutput: "0.33334", expectedOutput: "0.333333", want: model.StatusWA},
+		{name: "相对误差内", programOutput: "1000000.5", expectedOutput: "1000000", want: model.StatusAC},
+		{name: "科学计数法", programOutput: "1e-7", expectedOutput: "0.0000001", want: model.StatusAC},
+		{name: "整数与小数", programOutput: "2", expectedOutput: "2.000000", want: model.StatusAC},
+		{name: "非数值严格比较", programOutput: "Yes 0.5", expectedOutput: "YES 0.5", want: model.StatusWA},
+		{name: "数值与非数值", programOutput: "nan", expectedOutput: "0", want: model.StatusWA},
+		{name: "词数不同", programOutput: "1.0 2.0", expectedOutput: "1.0", want: model.StatusWA},
+		{name: "忽略空白布局", programOutput: "1.0\n2.0", expectedOutput: "1.0 2.0", want: model.StatusAC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := comparator.Judge(tt.programOutput, tt.expectedOutput)
+			if got != tt.want {
+				t.Errorf("Judge(%q, %q) = %v, want %v", tt.programOutput, tt.expectedOutput, got, tt.want)
+			}
+		})
+	}
+}
+
 // 基准测试
 func BenchmarkComparator_Compare_Strict(b *testing.B) {
 	comparator := NewComparator(true)
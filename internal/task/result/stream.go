@@ -0,0 +1,242 @@
+package result
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// streamBufferSize 流式比较时每个文件的读缓冲区大小
+const streamBufferSize = 64 * 1024
+
+// maxMismatchToken 差异信息中保留的词的最大长度（字节）
+const maxMismatchToken = 64
+
+// 差异片段的大小：不同之处之后保留excerptAfter字节，整个片段最多excerptSize字节
+const (
+	excerptSize  = 160
+	excerptAfter = 32
+)
+
+// Mismatch 程序输出与期望输出的第一处不同
+type Mismatch struct {
+	Line            int    `json:"line"`             // 程序输出中不同之处所在行（从1开始）
+	Column          int    `json:"column"`           // 程序输出中不同之处所在列（从1开始，按字节计）
+	TokenIndex      int    `json:"token_index"`      // 不同的词的序号（从1开始）
+	Expected        string `json:"expected"`         // 期望的词（过长时截断，期望输出已结束时为空）
+	Actual          string `json:"actual"`           // 程序输出的词（过长时截断，程序输出已结束时为空）
+	ExpectedExcerpt string `json:"expected_excerpt"` // 期望输出中不同之处附近的片段
+	ActualExcerpt   string `json:"actual_excerpt"`   // 程序输出中不同之处附近的片段
+}
+
+func (m *Mismatch) String() string {
+	switch {
+	case m.Actual == "":
+		return fmt.Sprintf("第%d行第%d列: 期望 %q, 程序输出已结束", m.Line, m.Column, m.Expected)
+	case m.Expected == "":
+		return fmt.Sprintf("第%d行第%d列: 期望输出已结束, 程序输出 %q", m.Line, m.Column, m.Actual)
+	default:
+		return fmt.Sprintf("第%d行第%d列: 期望 %q, 程序输出 %q", m.Line, m.Column, m.Expected, m.Actual)
+	}
+}
+
+// tokenScanner 逐字节读取按空白分隔的词，并记录当前位置和最近读取的内容
+type tokenScanner struct {
+	r      *bufio.Reader
+	line   int
+	column int
+	recent [excerptSize]byte // 最近读取的字节（环形缓冲区）
+	read   int               // 已读取的字节数
+}
+
+func newTokenScanner(r io.Reader) *tokenScanner {
+	return &tokenScanner{
+		r:      bufio.NewReaderSize(r, streamBufferSize),
+		line:   1,
+		column: 1,
+	}
+}
+
+// readByte 读取一个字节并记入最近读取的内容
+func (s *tokenScanner) readByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	s.recent[s.read%excerptSize] = b
+	s.read++
+	return b, nil
+}
+
+// unreadByte 回退上一个字节
+func (s *tokenScanner) unreadByte() error {
+	s.read--
+	return s.r.UnreadByte()
+}
+
+// excerpt 再读取最多excerptAfter字节，返回最近读取的片段
+func (s *tokenScanner) excerpt() (string, error) {
+	for i := 0; i < excerptAfter; i++ {
+		if _, err := s.readByte(); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	n := s.read
+	if n > excerptSize {
+		n = excerptSize
+	}
+	buf := make([]byte, 0, n)
+	for i := s.read - n; i < s.read; i++ {
+		buf = append(buf, s.recent[i%excerptSize])
+	}
+	return strings.ToValidUTF8(string(buf), ""), nil
+}
+
+// isSpace 判断是否为分隔词的空白，只认ASCII空白（与testlib一致），U+00A0等非ASCII空白属于词的一部分
+// 流式比较与内存中的按词比较（见fields）共用这一定义，保证同一输出在各比较模式下分词一致
+func isSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// skipSpace 跳过空白，到达输出末尾时返回false
+func (s *tokenScanner) skipSpace() (bool, error) {
+	for {
+		b, err := s.readByte()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !isSpace(b) {
+			return true, s.unreadByte()
+		}
+		if b == '\n' {
+			s.line++
+			s.column = 1
+		} else {
+			s.column++
+		}
+	}
+}
+
+// nextTokenByte 读取当前词的下一个字节，词结束时返回false（不消耗其后的空白）
+func (s *tokenScanner) nextTokenByte() (byte, bool, error) {
+	b, err := s.readByte()
+	if err == io.EOF {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if isSpace(b) {
+		return 0, false, s.unreadByte()
+	}
+	s.column++
+	return b, true, nil
+}
+
+// readToken 将当前词的剩余部分追加到prefix，最多保留maxMismatchToken字节
+func (s *tokenScanner) readToken(prefix []byte) ([]byte, error) {
+	for len(prefix) < maxMismatchToken {
+		b, ok, err := s.nextTokenByte()
+		if err != nil {
+			return prefix, err
+		}
+		if !ok {
+			break
+		}
+		prefix = append(prefix, b)
+	}
+	return prefix, nil
+}
+
+// CompareTokenStream 按词流式比较程序输出与期望输出，遇到第一处不同即停止
+// 只使用固定大小的缓冲区，内存占用与输出大小无关；按词相同时返回nil
+func CompareTokenStream(program io.Reader, expected io.Reader) (*Mismatch, error) {
+	prog := newTokenScanner(program)
+	exp := newTokenScanner(expected)
+	for tokenIndex := 1; ; tokenIndex++ {
+		progMore, err := prog.skipSpace()
+		if err != nil {
+			return nil, fmt.Errorf("读取程序输出失败: %w", err)
+		}
+		expMore, err := exp.skipSpace()
+		if err != nil {
+			return nil, fmt.Errorf("读取期望输出失败: %w", err)
+		}
+		if !progMore && !expMore {
+			return nil, nil
+		}
+
+		mismatch := &Mismatch{Line: prog.line, Column: prog.column, TokenIndex: tokenIndex}
+		var progToken, expToken []byte
+		for {
+			pb, pok, err := prog.nextTokenByte()
+			if err != nil {
+				return nil, fmt.Errorf("读取程序输出失败: %w", err)
+			}
+			eb, eok, err := exp.nextTokenByte()
+			if err != nil {
+				return nil, fmt.Errorf("读取期望输出失败: %w", err)
+			}
+			if !pok && !eok {
+				break // 当前词相同
+			}
+			if pok {
+				progToken = appendBounded(progToken, pb)
+			}
+			if eok {
+				expToken = appendBounded(expToken, eb)
+			}
+			if pok != eok || pb != eb {
+				if progToken, err = prog.readToken(progToken); err != nil {
+					return nil, fmt.Errorf("读取程序输出失败: %w", err)
+				}
+				if expToken, err = exp.readToken(expToken); err != nil {
+					return nil, fmt.Errorf("读取期望输出失败: %w", err)
+				}
+				mismatch.Actual = string(progToken)
+				mismatch.Expected = string(expToken)
+				if mismatch.ActualExcerpt, err = prog.excerpt(); err != nil {
+					return nil, fmt.Errorf("读取程序输出失败: %w", err)
+				}
+				if mismatch.ExpectedExcerpt, err = exp.excerpt(); err != nil {
+					return nil, fmt.Errorf("读取期望输出失败: %w", err)
+				}
+				return mismatch, nil
+			}
+		}
+	}
+}
+
+// appendBounded 追加字节，超过maxMismatchToken后丢弃
+func appendBounded(token []byte, b byte) []byte {
+	if len(token) < maxMismatchToken {
+		token = append(token, b)
+	}
+	return token
+}
+
+// CompareTokenFiles 按词流式比较程序输出文件与期望输出文件
+func CompareTokenFiles(programPath string, expectedPath string) (*Mismatch, error) {
+	program, err := os.Open(programPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开程序输出文件失败: %w", err)
+	}
+	defer program.Close()
+	expected, err := os.Open(expectedPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开期望输出文件失败: %w", err)
+	}
+	defer expected.Close()
+	return CompareTokenStream(program, expected)
+}
@@ -0,0 +1,133 @@
+package result
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareTokenStream(t *testing.T) {
+	tests := []struct {
+		name           string
+		programOutput  string
+		expectedOutput string
+		want           *Mismatch
+	}{
+		{name: "完全相同", programOutput: "1 2\n3\n", expectedOutput: "1 2\n3\n"},
+		{name: "忽略空白布局", programOutput: "1\r\n2   3", expectedOutput: "  1 2\n3\n\n"},
+		{name: "空输出", programOutput: "\n", expectedOutput: ""},
+		{
+			name:           "词不同",
+			programOutput:  "1 2\n3 5 6",
+			expectedOutput: "1 2\n3 4 6",
+			want:           &Mismatch{Line: 2, Column: 3, TokenIndex: 4, Expected: "4", Actual: "5"},
+		},
+		{
+			name:           "词为前缀",
+			programOutput:  "hello wor",
+			expectedOutput: "hello world",
+			want:           &Mismatch{Line: 1, Column: 7, TokenIndex: 2, Expected: "world", Actual: "wor"},
+		},
+		{
+			name:           "程序输出过短",
+			programOutput:  "1 2\n",
+			expectedOutput: "1 2 3",
+			want:           &Mismatch{Line: 2, Column: 1, TokenIndex: 3, Expected: "3"},
+		},
+		{
+			name:           "程序输出过长",
+			programOutput:  "1 2 3",
+			expectedOutput: "1 2",
+			want:           &Mismatch{Line: 1, Column: 5, TokenIndex: 3, Actual: "3"},
+		},
+		{
+			// U+00A0不是分隔词的空白，与内存中的按词比较一致
+			name:           "非ASCII空格",
+			programOutput:  "1\u00a02 3",
+			expectedOutput: "1 2 3",
+			want:           &Mismatch{Line: 1, Column: 1, TokenIndex: 1, Expected: "1", Actual: "1\u00a02"},
+		},
+		{
+			name:           "长词截断",
+			programOutput:  strings.Repeat("a", 100),
+			expectedOutput: strings.Repeat("a", 99) + "b",
+			want:           &Mismatch{Line: 1, Column: 1, TokenIndex: 1, Expected: strings.Repeat("a", maxMismatchToken), Actual: strings.Repeat("a", maxMismatchToken)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareTokenStream(strings.NewReader(tt.programOutput), strings.NewReader(tt.expectedOutput))
+			if err != nil {
+				t.Fatalf("CompareTokenStream() error = %v", err)
+			}
+			if got != nil {
+				// 片段单独验证
+				got.ExpectedExcerpt, got.ActualExcerpt = "", ""
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("CompareTokenStream() = %+v, want %+v", got, tt.want)
+			}
+			// 与内存中按词比较的结果一致
+			if equal := NewComparator(false).Compare(tt.programOutput, tt.expectedOutput); equal != (got == nil) {
+				t.Errorf("Compare() = %v, CompareTokenStream() = %+v", equal, got)
+			}
+		})
+	}
+}
+
+func TestCompareTokenStream_Excerpt(t *testing.T) {
+	prefix := strings.Repeat("0 ", 200)
+	programOutput := prefix + "1 2 X 4 5\n" + strings.Repeat("9 ", 100)
+	expectedOutput := prefix + "1 2 3 4 5\n" + strings.Repeat("9 ", 100)
+
+	got, err := CompareTokenStream(strings.NewReader(programOutput), strings.NewReader(expectedOutput))
+	if err != nil || got == nil {
+		t.Fatalf("CompareTokenStream() = %+v, %v", got, err)
+	}
+	if got.TokenIndex != 203 {
+		t.Errorf("TokenIndex = %d, want 203", got.TokenIndex)
+	}
+	for _, excerpt := range []struct {
+		text string
+		want string
+	}{
+		{got.ActualExcerpt, "1 2 X 4 5\n9"},
+		{got.ExpectedExcerpt, "1 2 3 4 5\n9"},
+	} {
+		if !strings.Contains(excerpt.text, excerpt.want) {
+			t.Errorf("excerpt = %q, want containing %q", excerpt.text, excerpt.want)
+		}
+		if len(excerpt.text) > excerptSize {
+			t.Errorf("excerpt too long: %d", len(excerpt.text))
+		}
+	}
+}
+
+func TestCompareTokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	programPath := filepath.Join(dir, "user_output.txt")
+	expectedPath := filepath.Join(dir, "answer.txt")
+	// 超过缓冲区大小的输出
+	var sb strings.Builder
+	for i := 0; i < 50000; i++ {
+		sb.WriteString("12345 ")
+	}
+	expected := sb.String()
+	if err := os.WriteFile(expectedPath, []byte(expected), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(programPath, []byte(strings.ReplaceAll(expected, " ", "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := CompareTokenFiles(programPath, expectedPath)
+	if err != nil || got != nil {
+		t.Fatalf("CompareTokenFiles() = %+v, %v, want nil", got, err)
+	}
+
+	if _, err := CompareTokenFiles(filepath.Join(dir, "missing.txt"), expectedPath); err == nil {
+		t.Error("CompareTokenFiles() 文件不存在时应返回错误")
+	}
+}
@@ -0,0 +1,278 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"hitwh-judge/internal/constants"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/task/result"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// scriptDir 沙箱辅助脚本所在目录
+const scriptDir = "./scripts/runner"
+
+// copyScript 将沙箱辅助脚本复制到目标目录，返回复制后的路径
+func copyScript(name string, dstDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(scriptDir, name))
+	if err != nil {
+		return "", fmt.Errorf("无法读取%s脚本: %w", name, err)
+	}
+	dst := filepath.Join(dstDir, name)
+	if err := os.WriteFile(dst, content, 0755); err != nil {
+		return "", fmt.Errorf("无法写入%s脚本: %w", name, err)
+	}
+	return dst, nil
+}
+
+// interactorOutputFile 交互程序工作目录中的输出文件（testlib的第二个参数）
+const interactorOutputFile = "output.txt"
+
+// partyLimits 交互一方的资源限制
+type partyLimits struct {
+	TimeLimit  int64 // CPU时间限制（秒）
+	WallTime   int64 // 墙钟时间限制（秒）
+	MemLimit   int64 // 内存限制（MB）
+	StackLimit int64 // 栈限制（字节，0表示与内存限制相同）
+}
+
+// enforce 正常结束的一方若资源使用超过限制，改判为对应的超限状态
+func (l partyLimits) enforce(r partyResult) partyResult {
+	if r.Status != model.StatusAC {
+		return r
+	}
+	if r.TimeUsed > time.Duration(l.TimeLimit)*time.Second {
+		r.Status = model.StatusTLE
+		r.Error = fmt.Sprintf("CPU时间超限: %v > %vs", r.TimeUsed, l.TimeLimit)
+	} else if r.MemUsed > uint64(l.MemLimit)*1024*1024 {
+		r.Status = model.StatusMLE
+		r.Error = fmt.Sprintf("内存超限: %d bytes > %d MB", r.MemUsed, l.MemLimit)
+	}
+	return r
+}
+
+// partySpec 交互一方的运行配置
+type partySpec struct {
+	ExePath   string     // 宿主机上的可执行文件
+	Args      []string   // 程序参数
+	DataFiles []dataFile // 只读挂载到沙箱内 /data 的测试数据
+	Limits    partyLimits
+	Trusted   bool // 是否为出题人提供的交互程序：工作目录可写，且不启用seccomp
+}
+
+// solutionSpec 选手程序的运行配置：使用题目的资源限制，墙钟时间额外留出交互程序的计算时间，不可读取测试数据
+func solutionSpec(runParams model.RunParams) partySpec {
+	return partySpec{
+		ExePath: runParams.ExePath,
+		Limits: partyLimits{
+			TimeLimit:  runParams.TimeLimit,
+			WallTime:   runParams.TimeLimit*2 + constants.CheckerTimeLimit,
+			MemLimit:   runParams.MemLimit,
+			StackLimit: runParams.StackLimit,
+		},
+	}
+}
+
+// interactorSpec 交互程序的运行配置，参数与testlib约定一致：interactor <input> <output> [<answer>]
+// 资源限制与checker一致，墙钟时间长于选手程序，保证选手程序等待超时先被终止，交互程序随后读到EOF并给出结果
+func interactorSpec(runParams model.RunParams) partySpec {
+	files := []dataFile{{Source: runParams.InputFile, Name: "input.txt"}}
+	if runParams.AnswerFile != "" {
+		files = append(files, dataFile{Source: runParams.AnswerFile, Name: "answer.txt"})
+	}
+	args := []string{files[0].BoxPath(), interactorOutputFile}
+	for _, f := range files[1:] {
+		args = append(args, f.BoxPath())
+	}
+	return partySpec{
+		ExePath:   runParams.SpecialExePath,
+		Args:      args,
+		DataFiles: files,
+		Limits: partyLimits{
+			TimeLimit: constants.CheckerTimeLimit,
+			WallTime:  solutionSpec(runParams).Limits.WallTime + constants.CheckerTimeLimit,
+			MemLimit:  constants.CheckerMemoryLimit,
+		},
+		Trusted: true,
+	}
+}
+
+// partyResult 交互一方（交互程序或选手程序）的运行结果
+type partyResult struct {
+	// Status 正常退出（无论退出码）为AC，资源超限为TLE/MLE/OLE，被信号终止为RE，沙箱出错为SE
+	Status   model.JudgeStatus
+	Error    string         // 非AC时的说明
+	ExitCode int            // 退出码（正常退出时有效）
+	Signal   syscall.Signal // 终止信号（被信号终止时有效）
+	TimeUsed time.Duration  // CPU时间
+	MemUsed  uint64         // 内存峰值（字节）
+	Stderr   string         // 标准错误输出（最多 MaxErrorSize 字节）
+}
+
+// failedParty 沙箱未能正常运行时的结果
+func failedParty(format string, args ...interface{}) partyResult {
+	return partyResult{Status: model.StatusSE, Error: fmt.Sprintf(format, args...)}
+}
+
+// partyRunner 在某种沙箱中按spec运行交互的一方，stdin、stdout为连接另一方的管道，阻塞至其结束
+// 子进程启动后（或启动失败时）必须立即关闭stdin与stdout，否则另一方在此方结束后读不到EOF
+type partyRunner func(spec partySpec, stdin, stdout *os.File) partyResult
+
+// runInteraction 用run分别运行交互程序与选手程序，并根据两方的结果判定测试点
+func runInteraction(runParams model.RunParams, run partyRunner) *model.TestCaseResult {
+	interactor, solution := interactorSpec(runParams), solutionSpec(runParams)
+	interactorResult, solutionResult, err := interact(
+		func(stdin, stdout *os.File) partyResult { return run(interactor, stdin, stdout) },
+		func(stdin, stdout *os.File) partyResult { return run(solution, stdin, stdout) },
+	)
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
+	return interactiveResult(runParams.TestCaseIndex, interactorResult, solutionResult)
+}
+
+// party 在沙箱中运行交互的一方，以stdin、stdout与另一方相连，阻塞至其结束
+// 子进程启动后（或启动失败时）必须立即关闭stdin与stdout，否则另一方在此方结束后读不到EOF
+type party func(stdin, stdout *os.File) partyResult
+
+// interact 以两条管道连接交互程序与选手程序：交互程序的输出为选手程序的输入，反之亦然
+// 两方各自在独立的沙箱中运行，拥有独立的资源限制与统计，全部结束后返回各自的结果
+func interact(interactor, solution party) (interactorResult, solutionResult partyResult, err error) {
+	toSolutionR, toSolutionW, err := os.Pipe()
+	if err != nil {
+		return partyResult{}, partyResult{}, fmt.Errorf("创建管道失败: %w", err)
+	}
+	toInteractorR, toInteractorW, err := os.Pipe()
+	if err != nil {
+		toSolutionR.Close()
+		toSolutionW.Close()
+		return partyResult{}, partyResult{}, fmt.Errorf("创建管道失败: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		interactorResult = interactor(toInteractorR, toSolutionW)
+	}()
+	go func() {
+		defer wg.Done()
+		solutionResult = solution(toSolutionR, toInteractorW)
+	}()
+	wg.Wait()
+	return interactorResult, solutionResult, nil
+}
+
+// startWithPipes 以管道作为标准输入输出启动命令，启动后关闭评测进程持有的管道端
+func startWithPipes(cmd *exec.Cmd, stdin, stdout *os.File) error {
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	err := cmd.Start()
+	stdin.Close()
+	stdout.Close()
+	return err
+}
+
+// limitedBuffer 只保留前limit字节的缓冲区，用于收集stderr，超出部分直接丢弃
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func newLimitedBuffer() *limitedBuffer {
+	return &limitedBuffer{limit: constants.MaxErrorSize}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// interactiveVerdict 根据交互程序与选手程序各自的结束状态判定交互题结果
+// 交互程序异常视为系统错误；选手程序超限、被信号终止或退出码非0时以选手程序的状态为准；
+// 两方都正常结束时按testlib约定解析交互程序的退出码。
+// 一方提前退出时另一方可能因写入已关闭的管道被SIGPIPE终止，此时以先退出的一方为准。
+func interactiveVerdict(interactor, solution partyResult) (status model.JudgeStatus, errorMsg string, scoreRatio float64, judgeMsg string) {
+	interactorBroken := interactor.Status == model.StatusRE && interactor.Signal == syscall.SIGPIPE
+	if interactor.Status != model.StatusAC && !interactorBroken {
+		msg := interactor.Error
+		if msg == "" {
+			msg = normalizeString(interactor.Stderr)
+		}
+		return model.StatusSE, fmt.Sprintf("交互程序运行失败: %s", msg), 0, ""
+	}
+
+	switch solution.Status {
+	case model.StatusTLE, model.StatusMLE, model.StatusOLE, model.StatusSE:
+		return solution.Status, solution.Error, 0, ""
+	case model.StatusRE:
+		if solution.Signal != syscall.SIGPIPE {
+			return model.StatusRE, solution.Error, 0, ""
+		}
+		// 交互程序先结束并关闭了管道，以交互程序的判定为准
+	default:
+		if solution.ExitCode != 0 {
+			return model.StatusRE, fmt.Sprintf("选手程序返回非0码: %d", solution.ExitCode), 0, ""
+		}
+	}
+
+	if interactorBroken {
+		return model.StatusWA, "选手程序提前结束", 0, ""
+	}
+
+	verdict := result.ParseCheckerResult(interactor.ExitCode, interactor.Stderr)
+	switch verdict.Status {
+	case model.StatusWA:
+		errorMsg = "交互程序判定答案错误"
+	case model.StatusPE:
+		errorMsg = "交互程序判定格式错误"
+	case model.StatusPC:
+		errorMsg = "交互程序判定部分正确"
+	case model.StatusSE:
+		errorMsg = "交互程序运行失败"
+	}
+	return verdict.Status, errorMsg, verdict.ScoreRatio, verdict.Message
+}
+
+// interactiveResult 汇总两方的运行结果，时间与内存为选手程序的使用量
+func interactiveResult(testCaseIndex int, interactor, solution partyResult) *model.TestCaseResult {
+	status, errorMsg, scoreRatio, judgeMsg := interactiveVerdict(interactor, solution)
+
+	zap.L().Info("Interactive execution result",
+		zap.Int("test_case", testCaseIndex),
+		zap.String("status", status),
+		zap.String("solution_status", solution.Status),
+		zap.Int("solution_exit_code", solution.ExitCode),
+		zap.Int("solution_signal", int(solution.Signal)),
+		zap.Duration("solution_cpu_time", solution.TimeUsed),
+		zap.Uint64("solution_memory_bytes", solution.MemUsed),
+		zap.String("interactor_status", interactor.Status),
+		zap.Int("interactor_exit_code", interactor.ExitCode),
+		zap.Int("interactor_signal", int(interactor.Signal)),
+		zap.Duration("interactor_cpu_time", interactor.TimeUsed),
+		zap.String("interactor_stderr", interactor.Stderr),
+	)
+
+	return &model.TestCaseResult{
+		TestCaseIndex:  testCaseIndex,
+		Status:         status,
+		TimeUsed:       solution.TimeUsed,
+		MemUsed:        solution.MemUsed,
+		Error:          errorMsg,
+		CheckerMessage: judgeMsg,
+		ScoreRatio:     scoreRatio,
+	}
+}
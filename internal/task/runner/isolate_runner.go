@@ -3,13 +3,16 @@ package runner
 import (
 	"bytes"
 	"fmt"
+	"hitwh-judge/internal/constants"
 	"hitwh-judge/internal/model"
 	file_util "hitwh-judge/internal/util/file"
-	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
@@ -28,128 +31,135 @@ var DefaultIsolateSandboxConfig = model.SandboxConfig{
 	Path: "isolate",
 }
 
+// InitSandbox 分配并初始化一个isolate box，返回box目录，由调用方负责清理
 func (ir *IsoRunner) InitSandbox() (string, error) {
-	currentBoxId := allocateBoxID()
-	ir.SetBoxId(currentBoxId)
-	// 初始化沙箱
-	initCmd := exec.Command(ir.IsolatePath, "--init", "--cg", fmt.Sprintf("--box-id=%d", ir.boxId))
-	initOutput, err := initCmd.Output()
+	box, err := newBox(isolateDriver{path: ir.IsolatePath})
 	if err != nil {
-		return "", fmt.Errorf("初始化沙箱失败: %w", err)
+		return "", err
 	}
-	sandboxPath := strings.TrimSpace(string(initOutput))
-	sandboxPath = filepath.Join(sandboxPath, "box")
-	return sandboxPath, nil
+	ir.SetBoxId(box.id)
+	return box.path, nil
 }
 
-// RunInSandbox 在Isolate沙箱中运行程序
-func (ir *IsoRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResult {
-	exePath := runParams.ExePath
-	timeLimit := runParams.TimeLimit
-	memoryLimit := runParams.MemLimit
+// acquireBox 取出一个已初始化的box：启用预热池时优先使用预热的box，否则分配ID并现场初始化
+func (ir *IsoRunner) acquireBox() (*sandboxBox, error) {
+	if pool := getIsolatePool(ir.IsolatePath); pool != nil {
+		return pool.get()
+	}
+	return newBox(isolateDriver{path: ir.IsolatePath})
+}
 
-	// // 检查isolate是否存在
-	// if _, err := exec.LookPath(ir.IsolatePath); err != nil {
-	// 	return &model.TestCaseResult{
-	// 		TestCaseIndex: runParams.TestCaseIndex,
-	// 		Status:        model.StatusSE,
-	// 		Error:         fmt.Sprintf("isolate 不存在: %v", err),
-	// 	}
-	// }
-
-	currentBoxId := allocateBoxID()
-	ir.SetBoxId(currentBoxId)
-
-	// 初始化沙箱
-	initCmd := exec.Command(ir.IsolatePath, "--init", "--cg", fmt.Sprintf("--box-id=%d", ir.boxId))
-	initOutput, err := initCmd.Output()
+// releaseBox 归还box：启用预热池时在后台回收复用，否则立即清理并释放box ID
+func (ir *IsoRunner) releaseBox(box *sandboxBox) error {
+	if pool := getIsolatePool(ir.IsolatePath); pool != nil {
+		pool.put(box)
+		return nil
+	}
+	return destroyBox(isolateDriver{path: ir.IsolatePath}, box)
+}
+
+// RunInSandbox 在Isolate沙箱中运行程序，单独初始化并清理一个box
+// 同一提交的多个测试点应通过 Open 复用同一个box
+func (ir *IsoRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResult {
+	session, err := ir.Open(runParams.ExePath)
 	if err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("初始化沙箱失败: %v", err),
-		}
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
-	sandboxPath := strings.TrimSpace(string(initOutput))
-	sandboxPath = filepath.Join(sandboxPath, "box")
+	defer session.Close()
+	return session.Run(runParams)
+}
 
-	// 确保清理函数
-	defer func() {
-		cleanupCmd := exec.Command(ir.IsolatePath, "--cleanup", "--cg", fmt.Sprintf("--box-id=%d", ir.boxId))
-		cleanupCmd.Run()
-		releaseBoxID(ir.boxId)
-	}()
+// isolateSession 复用同一个isolate box运行多个测试点的会话
+type isolateSession struct {
+	runner      *IsoRunner
+	box         *sandboxBox
+	exeFilename string // box内的可执行文件名
+}
 
-	// 获取脚本路径
-	scriptSrcPath := "./scripts/runner/normal_judge.sh"
-	scriptDstPath := filepath.Join(sandboxPath, "normal_judge.sh")
+// isolateNormalScript 普通题运行脚本
+const isolateNormalScript = "normal_judge.sh"
 
-	scriptContent, err := ioutil.ReadFile(scriptSrcPath)
+// Open 取出一个已初始化的isolate box，放入运行脚本与可执行文件
+// 之后每个测试点只重置box内的输出与meta文件，不再重复初始化沙箱和复制程序
+func (ir *IsoRunner) Open(exePath string) (Session, error) {
+	box, err := ir.acquireBox()
 	if err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("无法读取normal_judge.sh脚本: %v", err),
-		}
+		return nil, err
 	}
-
-	if err := ioutil.WriteFile(scriptDstPath, scriptContent, 0755); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("无法写入normal_judge.sh脚本到沙箱目录: %v", err),
-		}
+	s := &isolateSession{
+		runner:      ir,
+		box:         box,
+		exeFilename: filepath.Base(exePath),
 	}
 
+	if _, err := copyScript(isolateNormalScript, box.path); err != nil {
+		s.Close()
+		return nil, err
+	}
 	// 复制可执行文件到沙箱目录
-	exeFilename := filepath.Base(exePath)
-	destExePath := filepath.Join(sandboxPath, exeFilename)
-	cpCmd := exec.Command("cp", exePath, destExePath)
+	cpCmd := exec.Command("cp", exePath, filepath.Join(box.path, s.exeFilename))
 	if err := cpCmd.Run(); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("复制可执行文件到沙箱失败: %v", err),
-		}
+		s.Close()
+		return nil, fmt.Errorf("复制可执行文件到沙箱失败: %w", err)
+	}
+	return s, nil
+}
+
+// Close 归还box
+func (s *isolateSession) Close() error {
+	return s.runner.releaseBox(s.box)
+}
+
+// Run 在会话的box中运行一个测试点
+func (s *isolateSession) Run(runParams model.RunParams) *model.TestCaseResult {
+	timeLimit := runParams.TimeLimit
+	memoryLimit := runParams.MemLimit
+	maxOutput := outputLimit(runParams)
+	sandboxPath := s.box.path
+	exeFilename := s.exeFilename
+
+	// 删除上一个测试点的输出、meta以及程序留下的文件
+	if err := resetBox(sandboxPath, isolateNormalScript, exeFilename); err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
 
-	// 复制输入文件到沙箱目录
-	inputFilename := filepath.Base(runParams.InputFile)
-	destInputPath := filepath.Join(sandboxPath, inputFilename)
-	cpInputCmd := exec.Command("cp", runParams.InputFile, destInputPath)
-	if err := cpInputCmd.Run(); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("复制输入文件到沙箱失败: %v", err),
-		}
+	// 输入文件只读挂载到沙箱内的 /data，不复制到沙箱目录
+	input := dataFile{Source: runParams.InputFile, Name: "input.txt"}
+	dataDir, cleanupData, err := stageDataFiles([]dataFile{input})
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
+	defer cleanupData()
 
 	// 准备运行命令
 	args := []string{
 		"--run",
 		"--cg",
-		fmt.Sprintf("--box-id=%d", ir.boxId),
+		fmt.Sprintf("--box-id=%d", s.box.id),
 		"--processes", // 允许多个进程
 		"-e",          // 设置环境变量
+		isolateDataDirArg(dataDir),
 		fmt.Sprintf("--time=%f", float64(timeLimit)),        // 时间限制（秒）
 		fmt.Sprintf("--wall-time=%f", float64(timeLimit*2)), // 墙钟时间限制
 		fmt.Sprintf("--mem=%d", memoryLimit*1024),           // 内存限制（KB）
+		fmt.Sprintf("--fsize=%d", maxOutput/1024+1),         // 文件大小限制（KB），略大于输出限制以便判定超限
+		"--stdout=output.txt",                               // 输出写入沙箱内文件，避免占用评测服务内存
+		"--stderr=error.txt",
 		"--meta=meta.txt", // 输出元数据
 		"--",
 		"/bin/bash",
-		"normal_judge.sh",
+		isolateNormalScript,
 		exeFilename,
-		inputFilename,
+		input.BoxPath(),
 	}
 
 	// 创建执行命令
-	cmd := exec.Command(ir.IsolatePath, args...)
+	cmd := exec.Command(s.runner.IsolatePath, args...)
 
 	// 设置沙箱目录为工作目录
 	cmd.Dir = sandboxPath
 
-	// 捕获输出
+	// 捕获isolate自身的输出，程序输出写入沙箱内的output.txt
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -163,101 +173,31 @@ func (ir *IsoRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResu
 	// 计算墙钟时间
 	realTime := time.Since(startTime)
 
-	// 读取元数据文件
-	metaPath := filepath.Join(sandboxPath, "meta.txt")
-	metaContent, _ := file_util.ReadFileToString(metaPath)
-
-	// 解析资源使用情况
-	var cpuTime time.Duration
-	var memUsed int64
-	var exitCode int
-	var isKilled bool
-	var exitSig int
-
-	lines := strings.Split(metaContent, "\n")
-	zap.L().Info("Isolate meta content", zap.String("meta", metaContent))
-	for _, line := range lines {
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) >= 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-
-			switch key {
-			case "time":
-				if t, err := strconv.ParseFloat(value, 64); err == nil {
-					cpuTime = time.Duration(t * float64(time.Second))
-				}
-			case "cg-mem":
-				if m, err := strconv.ParseInt(value, 10, 64); err == nil {
-					memUsed = m * 1024 // convert KB to bytes
-				}
-			case "exitcode":
-				if code, err := strconv.Atoi(value); err == nil {
-					exitCode = code
-				}
-			case "exitsig":
-				if sig, err := strconv.Atoi(value); err == nil {
-					exitSig = sig
-				}
-			case "killed":
-				isKilled = true
-			case "cg-oom-killed":
-				isKilled = true
-				memUsed = memoryLimit * 1024 * 1024 * 2
-			}
-		}
+	output, outputExceeded, readErr := collectOutput(filepath.Join(sandboxPath, "output.txt"), runParams)
+	if readErr != nil {
+		return systemError(runParams.TestCaseIndex, "%v", readErr)
 	}
 
-	output := normalizeString(stdout.String())
-	errOutput := stderr.String()
-
-	// 解析状态
-	status := model.StatusAC
-	var errorMsg string
-
 	if err != nil {
-		zap.L().Error("Isolate execution error", zap.Error(err), zap.String("stderr", errOutput))
-	}
-
-	// 检查元数据中的状态标志
-	if strings.Contains(metaContent, "status:TO") || isKilled {
-		status = model.StatusTLE
-		errorMsg = "时间超限或被终止"
-	} else if strings.Contains(metaContent, "status:SG") || exitSig > 0 {
-		status = model.StatusRE
-		if exitSig > 0 {
-			errorMsg = fmt.Sprintf("程序收到信号 %d 终止", exitSig)
-		} else {
-			errorMsg = "程序被信号终止"
-		}
-	} else if strings.Contains(metaContent, "status:XX") {
-		status = model.StatusSE
-		errorMsg = "沙箱内部错误"
-	} else if strings.Contains(metaContent, "status:RE") || exitCode != 0 {
-		status = model.StatusRE
-		errorMsg = fmt.Sprintf("运行时错误: 退出码 %d", exitCode)
-	}
-
-	// 二次检查资源限制
-	if status == model.StatusAC {
-		if cpuTime > time.Duration(timeLimit)*time.Second {
-			status = model.StatusTLE
-			errorMsg = fmt.Sprintf("CPU时间超限: %v > %vs", cpuTime, timeLimit)
-		}
-		if memUsed > memoryLimit*1024*1024 {
-			status = model.StatusMLE
-			errorMsg = fmt.Sprintf("内存超限: %d bytes > %d MB", memUsed, memoryLimit)
-		}
+		zap.L().Error("Isolate execution error", zap.Error(err), zap.String("stderr", stderr.String()))
+	}
+
+	// 读取并解析元数据文件
+	metaContent, _ := file_util.ReadFileToString(filepath.Join(sandboxPath, "meta.txt"))
+	meta := parseIsolateMeta(metaContent)
+	status, errorMsg := meta.verdict(partyLimits{TimeLimit: timeLimit, MemLimit: memoryLimit})
+	if outputExceeded || status == model.StatusOLE {
+		status, errorMsg = model.StatusOLE, outputLimitMessage(maxOutput)
 	}
 
 	// 记录运行结果
 	zap.L().Info("Isolate execution result",
-		zap.Int("box_id", ir.boxId),
+		zap.Int("box_id", s.box.id),
 		zap.Int("test_case", runParams.TestCaseIndex),
-		zap.Duration("cpu_time", cpuTime),
+		zap.Duration("cpu_time", meta.cpuTime),
 		zap.Duration("real_time", realTime),
-		zap.Int64("memory_bytes", memUsed),
-		zap.Float64("memory_mb", float64(memUsed)/(1024*1024)),
+		zap.Int64("memory_bytes", meta.memUsed),
+		zap.Float64("memory_mb", float64(meta.memUsed)/(1024*1024)),
 		zap.String("status", string(status)),
 		zap.Int64("time_limit_sec", timeLimit),
 		zap.Int64("mem_limit_mb", memoryLimit),
@@ -267,8 +207,8 @@ func (ir *IsoRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResu
 	result := &model.TestCaseResult{
 		TestCaseIndex: runParams.TestCaseIndex,
 		Status:        status,
-		TimeUsed:      cpuTime,
-		MemUsed:       uint64(memUsed),
+		TimeUsed:      meta.cpuTime,
+		MemUsed:       uint64(meta.memUsed),
 		Output:        output,
 		Error:         errorMsg,
 	}
@@ -277,337 +217,318 @@ func (ir *IsoRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResu
 }
 
 // RunInteractiveInSandbox 在Isolate沙箱中运行交互题
+// 交互程序与选手程序分别运行在两个box中，以管道相连，各自独立限制并统计资源
+// 两个box在启动任何一方之前一并取出，避免并发评测各持有一个box互相等待
 func (ir *IsoRunner) RunInteractiveInSandbox(runParams model.RunParams) *model.TestCaseResult {
-	exePath := runParams.ExePath
-	timeLimit := runParams.TimeLimit
-	memoryLimit := runParams.MemLimit
-
-	specialExePath := runParams.SpecialExePath // 交互题评测程序路径
-	answer := runParams.Answer                 // 标准答案
-
-	currentBoxId := allocateBoxID()
-	ir.SetBoxId(currentBoxId)
-
-	// 初始化沙箱
-	initCmd := exec.Command(ir.IsolatePath, "--init", "--cg", fmt.Sprintf("--box-id=%d", ir.boxId))
-	initOutput, err := initCmd.Output()
+	boxes, err := ir.acquireBoxes(2)
 	if err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("初始化沙箱失败: %v", err),
-		}
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
+	defer ir.releaseBoxes(boxes)
 
-	sandboxPath := strings.TrimSpace(string(initOutput))
-	sandboxPath = filepath.Join(sandboxPath, "box")
-
-	// 确保清理函数
-	// defer func() {
-	// 	cleanupCmd := exec.Command(ir.IsolatePath, "--cleanup", "--cg", fmt.Sprintf("--box-id=%d", ir.boxId))
-	// 	cleanupCmd.Run()
-	// 	releaseBoxID(ir.boxId)
-	// }()
-
-	// 获取交互题评测脚本路径
-	scriptSrcPath := "./scripts/runner/interactive_judge.sh"
-	scriptDstPath := filepath.Join(sandboxPath, "interactive_judge.sh")
+	free := make(chan *sandboxBox, len(boxes))
+	for _, box := range boxes {
+		free <- box
+	}
+	return runInteraction(runParams, func(spec partySpec, stdin, stdout *os.File) partyResult {
+		return ir.runParty(<-free, spec, stdin, stdout)
+	})
+}
 
-	scriptContent, err := ioutil.ReadFile(scriptSrcPath)
-	if err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("无法读取interactive_run.py脚本: %v", err),
+// multiBoxMu 串行化需要同时取出多个box的申请
+// 多个申请者交替各取到一部分box后会互相等待直至超时，串行化后同一时刻至多一个申请者在部分持有box时等待
+var multiBoxMu sync.Mutex
+
+// acquireBoxes 一并取出n个box，任一个取出失败时归还已取出的box
+func (ir *IsoRunner) acquireBoxes(n int) ([]*sandboxBox, error) {
+	multiBoxMu.Lock()
+	defer multiBoxMu.Unlock()
+
+	boxes := make([]*sandboxBox, 0, n)
+	for range n {
+		box, err := ir.acquireBox()
+		if err != nil {
+			ir.releaseBoxes(boxes)
+			return nil, err
 		}
+		boxes = append(boxes, box)
 	}
+	return boxes, nil
+}
 
-	if err := ioutil.WriteFile(scriptDstPath, scriptContent, 0755); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("无法写入interactive_run.py脚本到沙箱目录: %v", err),
+// releaseBoxes 归还全部box，失败时只记录日志
+func (ir *IsoRunner) releaseBoxes(boxes []*sandboxBox) {
+	for _, box := range boxes {
+		if err := ir.releaseBox(box); err != nil {
+			zap.L().Warn("归还沙箱失败", zap.Int("box_id", box.id), zap.Error(err))
 		}
 	}
+}
 
-	// 复制选手可执行文件到沙箱目录
-	exeFilename := filepath.Base(exePath)
-	destExePath := filepath.Join(sandboxPath, exeFilename)
-	cpCmd := exec.Command("cp", exePath, destExePath)
+// runParty 在指定的box中运行交互的一方，box由调用方取出并归还
+func (ir *IsoRunner) runParty(box *sandboxBox, spec partySpec, stdin, stdout *os.File) partyResult {
+	// 提前返回时同样需要关闭管道，重复关闭没有影响
+	defer stdin.Close()
+	defer stdout.Close()
+
+	exeFilename := filepath.Base(spec.ExePath)
+	cpCmd := exec.Command("cp", spec.ExePath, filepath.Join(box.path, exeFilename))
 	if err := cpCmd.Run(); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("复制选手可执行文件到沙箱失败: %v", err),
-		}
+		return failedParty("复制%s到沙箱失败: %v", exeFilename, err)
 	}
-	var destSpecialExePath, specialExeFilename string
-	// 复制交互评测程序到沙箱目录
-	if specialExePath != "" {
-		specialExeFilename = filepath.Base(specialExePath)
-		destSpecialExePath = filepath.Join(sandboxPath, specialExeFilename)
-		cpSpecialCmd := exec.Command("cp", specialExePath, destSpecialExePath)
-		if err := cpSpecialCmd.Run(); err != nil {
-			return &model.TestCaseResult{
-				TestCaseIndex: runParams.TestCaseIndex,
-				Status:        model.StatusSE,
-				Error:         fmt.Sprintf("复制交互评测程序到沙箱失败: %v", err),
-			}
+
+	limits := spec.Limits
+	args := []string{
+		"--run",
+		"--cg",
+		fmt.Sprintf("--box-id=%d", box.id),
+		"--processes", // 允许多个进程
+		"-e",          // 设置环境变量
+		fmt.Sprintf("--time=%d", limits.TimeLimit),
+		fmt.Sprintf("--wall-time=%d", limits.WallTime),
+		fmt.Sprintf("--mem=%d", limits.MemLimit*1024),
+		"--stderr=error.txt",
+		"--meta=meta.txt",
+	}
+	// 测试数据只读挂载到沙箱内的 /data
+	if len(spec.DataFiles) > 0 {
+		dataDir, cleanupData, err := stageDataFiles(spec.DataFiles)
+		if err != nil {
+			return failedParty("%v", err)
 		}
+		defer cleanupData()
+		args = append(args, isolateDataDirArg(dataDir))
 	}
+	args = append(args, "--", "./"+exeFilename)
+	args = append(args, spec.Args...)
 
-	// 创建输入、输出和答案文件
-	outputPath := filepath.Join(sandboxPath, "output.txt")
-	answerPath := filepath.Join(sandboxPath, "answer.txt")
+	cmd := exec.Command(ir.IsolatePath, args...)
+	cmd.Dir = box.path
+	isolateErr := newLimitedBuffer()
+	cmd.Stderr = isolateErr
+	if err := startWithPipes(cmd, stdin, stdout); err != nil {
+		return failedParty("启动沙箱失败: %v", err)
+	}
+	// 程序返回非0退出码时isolate也会返回非0，是否出错以meta为准
+	_ = cmd.Wait()
 
-	// 复制输入文件到沙箱目录
-	inputFilename := filepath.Base(runParams.InputFile)
-	destInputPath := filepath.Join(sandboxPath, inputFilename)
-	cpInputCmd := exec.Command("cp", runParams.InputFile, destInputPath)
-	if err := cpInputCmd.Run(); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("复制输入文件到沙箱失败: %v", err),
-		}
+	metaContent, _ := file_util.ReadFileToString(filepath.Join(box.path, "meta.txt"))
+	if metaContent == "" {
+		return failedParty("沙箱内部错误: %s", sanitizeError(isolateErr.String()))
 	}
-	// 创建空输出文件
-	if err := ioutil.WriteFile(outputPath, []byte{}, 0666); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("创建输出文件失败: %v", err),
+	r := parseIsolateMeta(metaContent).party(limits)
+	r.Stderr, _, _ = readOutput(filepath.Join(box.path, "error.txt"), constants.MaxErrorSize)
+
+	zap.L().Debug("Isolate interactive party result",
+		zap.Int("box_id", box.id),
+		zap.String("exe", exeFilename),
+		zap.String("meta_content", metaContent),
+	)
+	return r
+}
+
+// SetBoxId 设置沙箱ID
+func (ir *IsoRunner) SetBoxId(id int) {
+	ir.boxId = id
+}
+
+// GetBoxId 获取沙箱ID
+func (ir *IsoRunner) GetBoxId() int {
+	return ir.boxId
+}
+
+// RunCheckerInSandbox 在Isolate沙箱中运行特殊评测程序（checker）
+// 调用方式与testlib一致：checker input.txt user_output.txt answer.txt
+func (ir *IsoRunner) RunCheckerInSandbox(runParams model.RunParams) *model.CheckerResult {
+	// 取出沙箱，运行结束后归还
+	box, err := ir.acquireBox()
+	if err != nil {
+		return &model.CheckerResult{Error: err.Error()}
+	}
+	defer func() {
+		if err := ir.releaseBox(box); err != nil {
+			zap.L().Warn("归还沙箱失败", zap.Int("box_id", box.id), zap.Error(err))
 		}
+	}()
+	return ir.runChecker(box, runParams)
+}
+
+// RunChecker 在会话的box中运行checker，避免持有会话box的同时再等待另一个box
+// 运行前清除测试点留下的文件，checker及其输出会在下一个测试点运行前被清除
+func (s *isolateSession) RunChecker(runParams model.RunParams) *model.CheckerResult {
+	if filepath.Base(runParams.SpecialExePath) == s.exeFilename {
+		return &model.CheckerResult{Error: "checker与选手程序同名"}
 	}
+	if err := resetBox(s.box.path, isolateNormalScript, s.exeFilename); err != nil {
+		return &model.CheckerResult{Error: err.Error()}
+	}
+	return s.runner.runChecker(s.box, runParams)
+}
+
+// runChecker 在指定的box中运行checker
+func (ir *IsoRunner) runChecker(box *sandboxBox, runParams model.RunParams) *model.CheckerResult {
+	sandboxPath := box.path
 
-	// 创建答案文件
-	if err := ioutil.WriteFile(answerPath, []byte(answer), 0666); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("创建答案文件失败: %v", err),
+	// 复制checker到沙箱目录，测试数据与程序输出只读挂载到沙箱内的 /data
+	checkerFilename := filepath.Base(runParams.SpecialExePath)
+	cpCmd := exec.Command("cp", runParams.SpecialExePath, filepath.Join(sandboxPath, checkerFilename))
+	if err := cpCmd.Run(); err != nil {
+		return &model.CheckerResult{
+			Error: fmt.Sprintf("复制%s到沙箱失败: %v", checkerFilename, err),
 		}
 	}
+	files := checkerDataFiles(runParams)
+	dataDir, cleanupData, err := stageDataFiles(files)
+	if err != nil {
+		return &model.CheckerResult{Error: err.Error()}
+	}
+	defer cleanupData()
 
-	// 准备运行命令，使用Python脚本运行交互题
-	// 根据命令: python3 run.py ./b.out hitwhoj-rebirth_cd286ce977bbf0f10aed62ccc4de1cdf output.txt -- ./a.out
-	// 其中 a.out 是选手程序（用户解决方案），b.out 是 special 程序（评测程序）
 	args := []string{
 		"--run",
 		"--cg",
-		fmt.Sprintf("--box-id=%d", ir.boxId),
-		"--processes", // 允许多个进程
-		"-e",          // 设置环境变量
-		fmt.Sprintf("--time=%f", float64(timeLimit*4)),      // 时间限制（秒）- 交互题需要更多时间
-		fmt.Sprintf("--wall-time=%f", float64(timeLimit*6)), // 墙钟时间限制
-		fmt.Sprintf("--mem=%d", memoryLimit*1024*2),         // 内存限制（KB）
-		"--meta=meta.txt", // 输出元数据
+		fmt.Sprintf("--box-id=%d", box.id),
+		isolateDataDirArg(dataDir),
+		fmt.Sprintf("--time=%d", constants.CheckerTimeLimit),
+		fmt.Sprintf("--wall-time=%d", constants.CheckerTimeLimit*2),
+		fmt.Sprintf("--mem=%d", constants.CheckerMemoryLimit*1024),
+		"--stderr=checker.txt", // testlib将评测信息写入stderr
+		"--meta=meta.txt",
 		"--",
-		"/bin/bash",
-		"./interactive_judge.sh",
-		"./" + specialExeFilename, // 评测程序 (b.out)
-		inputFilename,             // 额外参数
-		"answer.txt",              // 输出文件
-		"--",                      // 分隔符
-		"./" + exeFilename,        // 选手程序 (a.out)
+		"./" + checkerFilename,
+		files[0].BoxPath(),
+		files[1].BoxPath(),
+		files[2].BoxPath(),
 	}
 
-	// 创建执行命令
 	cmd := exec.Command(ir.IsolatePath, args...)
-
-	// 设置沙箱目录为工作目录
 	cmd.Dir = sandboxPath
-
-	// 捕获输出
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	// 记录开始时间
-	startTime := time.Now()
+	// checker返回非0退出码时isolate也会返回非0，是否出错以meta为准
+	_ = cmd.Run()
 
-	// 执行命令
-	err = cmd.Run()
+	metaContent, _ := file_util.ReadFileToString(filepath.Join(sandboxPath, "meta.txt"))
+	checkerMsg, _ := file_util.ReadFileToString(filepath.Join(sandboxPath, "checker.txt"))
+	meta := parseIsolateMeta(metaContent)
 
-	// 计算墙钟时间
-	realTime := time.Since(startTime)
-
-	// 读取元数据文件
-	metaPath := filepath.Join(sandboxPath, "meta.txt")
-	metaContent, _ := file_util.ReadFileToString(metaPath)
+	zap.L().Debug("Isolate checker result",
+		zap.Int("box_id", box.id),
+		zap.Int("test_case", runParams.TestCaseIndex),
+		zap.String("meta_content", metaContent),
+		zap.String("checker_message", checkerMsg),
+	)
 
-	// 解析资源使用情况
-	var cpuTime time.Duration
-	var memUsed int64
-	var exitCode int
-	var isKilled bool
-	var exitSig int
+	switch meta.status {
+	case "TO":
+		return &model.CheckerResult{Error: "checker运行超时"}
+	case "SG":
+		return &model.CheckerResult{Error: fmt.Sprintf("checker收到信号 %d 终止", meta.exitSig)}
+	case "XX":
+		return &model.CheckerResult{Error: fmt.Sprintf("沙箱内部错误: %s", sanitizeError(stderr.String()))}
+	}
 
-	lines := strings.Split(metaContent, "\n")
-	zap.L().Info("Interactive Isolate meta content", zap.String("meta", metaContent))
-	for _, line := range lines {
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) >= 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-
-			switch key {
-			case "time":
-				if t, err := strconv.ParseFloat(value, 64); err == nil {
-					cpuTime = time.Duration(t * float64(time.Second))
-				}
-			case "cg-mem":
-				if m, err := strconv.ParseInt(value, 10, 64); err == nil {
-					memUsed = m * 1024 // convert KB to bytes
-				}
-			case "exitcode":
-				if code, err := strconv.Atoi(value); err == nil {
-					exitCode = code
-				}
-			case "exitsig":
-				if sig, err := strconv.Atoi(value); err == nil {
-					exitSig = sig
-				}
-			case "killed":
-				isKilled = true
-			case "cg-oom-killed":
-				isKilled = true
-				memUsed = memoryLimit * 1024 * 1024 * 2
-			}
-		}
+	return &model.CheckerResult{
+		ExitCode: meta.exitCode,
+		Message:  checkerMsg,
 	}
+}
 
-	output := normalizeString(stdout.String())
-	errOutput := stderr.String()
+// isolateDataDirArg 将宿主机目录只读挂载为沙箱内 /data 的isolate参数
+func isolateDataDirArg(dir string) string {
+	return fmt.Sprintf("--dir=%s=%s", boxDataDir, dir)
+}
 
-	// 解析状态
-	status := model.StatusAC
-	var errorMsg string
+// isolateMeta isolate --meta 文件中的关键字段
+type isolateMeta struct {
+	status   string        // 状态：RE/SG/TO/XX，正常结束时为空
+	exitCode int           // 退出码
+	exitSig  int           // 终止信号
+	cpuTime  time.Duration // CPU时间
+	memUsed  int64         // 内存使用（字节）
+	killed   bool          // 是否被沙箱终止
+	oom      bool          // 是否因内存超限被终止
+}
 
-	if err != nil {
-		zap.L().Error("Interactive isolate execution error", zap.Error(err), zap.String("stderr", errOutput))
-	}
-
-	// 检查元数据中的状态标志
-	if strings.Contains(metaContent, "status:TO") || isKilled {
-		status = model.StatusTLE
-		errorMsg = "时间超限或被终止"
-	} else if strings.Contains(metaContent, "status:SG") || exitSig > 0 {
-		status = model.StatusRE
-		if exitSig > 0 {
-			errorMsg = fmt.Sprintf("程序收到信号 %d 终止", exitSig)
-		} else {
-			errorMsg = "程序被信号终止"
+// parseIsolateMeta 解析isolate的meta文件内容
+func parseIsolateMeta(metaContent string) isolateMeta {
+	var meta isolateMeta
+	for _, line := range strings.Split(metaContent, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
 		}
-	} else if strings.Contains(metaContent, "status:XX") {
-		status = model.StatusSE
-		errorMsg = "沙箱内部错误"
-	} else if strings.Contains(metaContent, "status:RE") || exitCode != 0 {
-		status = model.StatusRE
-		errorMsg = fmt.Sprintf("运行时错误: 退出码 %d", exitCode)
-	}
-
-	// 根据您提供的示例，如果评测程序返回码非0，则是Wrong Answer
-	if status == model.StatusAC {
-		if strings.Contains(errOutput, "Judge return code:") {
-			// 检查是否有judge返回非0码
-			if strings.Contains(errOutput, "Judge return code: ") {
-				// 提取返回码
-				lines := strings.Split(errOutput, "\n")
-				for _, line := range lines {
-					if strings.Contains(line, "Judge return code: ") {
-						// 如果评测程序返回码非0，则是WA
-						if !strings.Contains(line, "Judge return code: 0") {
-							status = model.StatusWA
-							errorMsg = "评测程序返回非0码，视为答案错误"
-							break
-						}
-					}
-				}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "status":
+			meta.status = value
+		case "time":
+			if t, err := strconv.ParseFloat(value, 64); err == nil {
+				meta.cpuTime = time.Duration(t * float64(time.Second))
 			}
-		}
-
-		// 检查输出中是否包含正确结果的标志
-		if strings.Contains(output, "points") && strings.Contains(output, "run") {
-			// 如果包含运行和注册成功的信息，但没有错误提示，认为是AC
-			if !strings.Contains(output, "error") && !strings.Contains(output, "Error") {
-				// 根据您的示例，只有当solution和judge都返回0时才是正确答案
-				if strings.Contains(errOutput, "Solution return code: 0") && !strings.Contains(errOutput, "Judge return code: ") {
-					// 如果找不到Judge return code，或者找到的是Judge return code: 0
-					if !strings.Contains(errOutput, "Judge return code: ") || strings.Contains(errOutput, "Judge return code: 0") {
-						status = model.StatusAC
-					} else {
-						status = model.StatusWA
-						errorMsg = "评测程序返回非0码"
-					}
-				} else if strings.Contains(errOutput, "Solution return code: 0") {
-					// 如果solution返回0，但judge返回非0，则是WA
-					if strings.Contains(errOutput, "Judge return code: ") && !strings.Contains(errOutput, "Judge return code: 0") {
-						status = model.StatusWA
-						errorMsg = "评测程序返回非0码"
-					}
-				} else {
-					// 如果solution返回非0，则是RE
-					status = model.StatusRE
-					errorMsg = "选手程序返回非0码"
-				}
-			} else {
-				status = model.StatusWA
-				errorMsg = "输出中包含错误信息"
+		case "cg-mem":
+			if m, err := strconv.ParseInt(value, 10, 64); err == nil {
+				meta.memUsed = m * 1024 // convert KB to bytes
 			}
-		} else {
-			// 如果输出不符合预期格式，可能有问题
-			status = model.StatusRE
-			errorMsg = "输出格式不正确"
-		}
-	}
-
-	// 二次检查资源限制
-	if status == model.StatusAC {
-		if cpuTime > time.Duration(timeLimit*4)*time.Second {
-			status = model.StatusTLE
-			errorMsg = fmt.Sprintf("CPU时间超限: %v > %vs", cpuTime, timeLimit*4)
-		}
-		if memUsed > memoryLimit*1024*1024*2 {
-			status = model.StatusMLE
-			errorMsg = fmt.Sprintf("内存超限: %d bytes > %d MB", memUsed, memoryLimit*2)
+		case "exitcode":
+			if code, err := strconv.Atoi(value); err == nil {
+				meta.exitCode = code
+			}
+		case "exitsig":
+			if sig, err := strconv.Atoi(value); err == nil {
+				meta.exitSig = sig
+			}
+		case "killed":
+			meta.killed = true
+		case "cg-oom-killed":
+			meta.oom = true
 		}
 	}
+	return meta
+}
 
-	// 记录运行结果
-	zap.L().Info("Interactive isolate execution result",
-		zap.Int("box_id", ir.boxId),
-		zap.Int("test_case", runParams.TestCaseIndex),
-		zap.Duration("cpu_time", cpuTime),
-		zap.Duration("real_time", realTime),
-		zap.Int64("memory_bytes", memUsed),
-		zap.Float64("memory_mb", float64(memUsed)/(1024*1024)),
-		zap.String("status", string(status)),
-		zap.Int64("time_limit_sec", timeLimit),
-		zap.Int64("mem_limit_mb", memoryLimit),
-		zap.String("meta_content", metaContent),
-		zap.String("output", output),
-		zap.String("stderr", errOutput),
-	)
-
-	result := &model.TestCaseResult{
-		TestCaseIndex: runParams.TestCaseIndex,
-		Status:        status,
-		TimeUsed:      cpuTime,
-		MemUsed:       uint64(memUsed),
-		Output:        output,
-		Error:         errorMsg,
+// verdict 根据meta判定程序的运行状态，返回状态与说明，正常结束时为AC
+// 超出输出文件大小限制（SIGXFSZ）判为OLE，说明由调用方按输出限制给出
+func (meta isolateMeta) verdict(limits partyLimits) (model.JudgeStatus, string) {
+	switch {
+	case meta.oom:
+		return model.StatusMLE, fmt.Sprintf("内存超限: 限制 %d MB", limits.MemLimit)
+	case meta.exitSig == int(syscall.SIGXFSZ):
+		return model.StatusOLE, "输出超限"
+	case meta.status == "TO" || meta.killed:
+		return model.StatusTLE, "时间超限或被终止"
+	case meta.status == "SG" || meta.exitSig > 0:
+		return model.StatusRE, fmt.Sprintf("程序收到信号 %d 终止", meta.exitSig)
+	case meta.status == "XX":
+		return model.StatusSE, "沙箱内部错误"
+	case meta.status == "RE" || meta.exitCode != 0:
+		return model.StatusRE, fmt.Sprintf("运行时错误: 退出码 %d", meta.exitCode)
 	}
-
-	return result
+	// isolate按CPU时间终止程序前可能略有超出，再按限制检查一次
+	r := limits.enforce(partyResult{Status: model.StatusAC, TimeUsed: meta.cpuTime, MemUsed: uint64(meta.memUsed)})
+	return r.Status, r.Error
 }
 
-// SetBoxId 设置沙箱ID
-func (ir *IsoRunner) SetBoxId(id int) {
-	ir.boxId = id
+// party 将meta转换为交互一方的运行结果，程序以非0退出码结束视为正常结束，由调用方解释退出码
+func (meta isolateMeta) party(limits partyLimits) partyResult {
+	r := partyResult{
+		Status:   model.StatusAC,
+		ExitCode: meta.exitCode,
+		Signal:   syscall.Signal(meta.exitSig),
+		TimeUsed: meta.cpuTime,
+		MemUsed:  uint64(meta.memUsed),
+	}
+	if status, msg := meta.verdict(limits); status != model.StatusRE || meta.exitSig != 0 {
+		r.Status, r.Error = status, msg
+	}
+	return limits.enforce(r)
 }
 
-// GetBoxId 获取沙箱ID
-func (ir *IsoRunner) GetBoxId() int {
-	return ir.boxId
+func init() {
+	Register(DefaultIsolateSandboxConfig, func(sandboxPath string) Runner {
+		return &IsoRunner{IsolatePath: sandboxPath}
+	})
 }
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"hitwh-judge/internal/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsolateMetaVerdict(t *testing.T) {
+	limits := partyLimits{TimeLimit: 1, MemLimit: 256}
+	tests := []struct {
+		name      string
+		meta      string
+		want      model.JudgeStatus
+		wantParty model.JudgeStatus // 交互一方的状态，非0退出码由调用方解释
+	}{
+		{name: "正常退出", meta: "time:0.500\ncg-mem:1024\nexitcode:0\n", want: model.StatusAC, wantParty: model.StatusAC},
+		{name: "非零退出码", meta: "status:RE\nexitcode:1\n", want: model.StatusRE, wantParty: model.StatusAC},
+		{name: "超时", meta: "status:TO\nkilled:1\ntime:1.050\n", want: model.StatusTLE, wantParty: model.StatusTLE},
+		{name: "CPU时间超限", meta: "time:1.200\nexitcode:0\n", want: model.StatusTLE, wantParty: model.StatusTLE},
+		{name: "内存超限", meta: "status:SG\nexitsig:9\ncg-oom-killed:1\nkilled:1\n", want: model.StatusMLE, wantParty: model.StatusMLE},
+		{name: "段错误", meta: "status:SG\nexitsig:11\n", want: model.StatusRE, wantParty: model.StatusRE},
+		{name: "输出文件超限", meta: "status:SG\nexitsig:25\n", want: model.StatusOLE, wantParty: model.StatusOLE},
+		{name: "沙箱内部错误", meta: "status:XX\n", want: model.StatusSE, wantParty: model.StatusSE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := parseIsolateMeta(tt.meta)
+			if got, _ := meta.verdict(limits); got != tt.want {
+				t.Errorf("verdict() = %v, want %v", got, tt.want)
+			}
+			if got := meta.party(limits).Status; got != tt.wantParty {
+				t.Errorf("party().Status = %v, want %v", got, tt.wantParty)
+			}
+		})
+	}
+}
+
+func TestParseIsolateMeta(t *testing.T) {
+	meta := parseIsolateMeta("time:0.250\ntime-wall:0.300\ncg-mem:2048\nexitcode:3\nstatus:RE\n")
+	if meta.cpuTime != 250*time.Millisecond {
+		t.Errorf("cpuTime = %v, want 250ms", meta.cpuTime)
+	}
+	if meta.memUsed != 2048*1024 {
+		t.Errorf("memUsed = %d, want %d", meta.memUsed, 2048*1024)
+	}
+	if meta.exitCode != 3 || meta.status != "RE" {
+		t.Errorf("exitCode = %d, status = %q, want 3, RE", meta.exitCode, meta.status)
+	}
+}
+
+func TestIsoRunnerAcquireBoxes(t *testing.T) {
+	defer func(ids *boxIDPool) { boxIDs.Store(ids) }(boxIDs.Load())
+	boxIDs.Store(newBoxIDPool(100, 2, time.Second))
+
+	// 使用模拟的box池，ID范围只够一次交互评测同时使用
+	const isolatePath = "fake-isolate"
+	pool := newBoxPool(&fakeBoxDriver{root: t.TempDir()}, 2)
+	isolatePoolsMu.Lock()
+	isolatePools[isolatePath] = pool
+	isolatePoolsMu.Unlock()
+	defer func() {
+		isolatePoolsMu.Lock()
+		delete(isolatePools, isolatePath)
+		isolatePoolsMu.Unlock()
+		pool.stop()
+	}()
+
+	// 多个交互评测同时申请两个box，不能各持有一个互相等待直至超时
+	ir := &IsoRunner{IsolatePath: isolatePath}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			boxes, err := ir.acquireBoxes(2)
+			if err != nil {
+				t.Errorf("acquireBoxes() error = %v", err)
+				return
+			}
+			if len(boxes) != 2 || boxes[0].id == boxes[1].id {
+				t.Errorf("acquireBoxes() = %v, want 2 distinct boxes", boxes)
+			}
+			time.Sleep(10 * time.Millisecond)
+			ir.releaseBoxes(boxes)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,293 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// nativeInitArg 沙箱初始化进程的argv[0]
+// NativeRunner 以该名称重新执行当前程序，在新命名空间内完成初始化后exec选手程序，因此评测服务只需单个二进制文件
+const nativeInitArg = "hitwh-judge-sandbox-init"
+
+// 沙箱初始化进程继承的文件描述符
+const (
+	nativeSpecFd  = 3 // 读取沙箱配置
+	nativeErrorFd = 4 // 写入初始化错误，exec成功后自动关闭
+	nativeProcsFd = 5 // cgroup.procs，写入"0"加入评测cgroup
+)
+
+// 沙箱内的目录
+const (
+	nativeBoxDir   = "/box" // 工作目录（可读写）
+	nativeHostname = "judge"
+)
+
+// nativeSpec 沙箱初始化配置，由父进程通过管道传给初始化进程
+type nativeSpec struct {
+	RootDir      string     `json:"root_dir"`       // 宿主机上的新根目录挂载点
+	WorkDir      string     `json:"work_dir"`       // 宿主机工作目录，挂载为沙箱内的 /box
+	ReadOnlyDirs []string   `json:"read_only_dirs"` // 只读挂载的系统目录
+	DataFiles    []dataFile `json:"data_files"`     // 只读挂载到 /data 下的测试数据文件
+	Args         []string   `json:"args"`           // 沙箱内执行的命令及参数
+	Env          []string   `json:"env"`            // 环境变量
+	CPULimit     uint64     `json:"cpu_limit"`      // RLIMIT_CPU（秒）
+	StackLimit   uint64     `json:"stack_limit"`    // RLIMIT_STACK（字节）
+	FileLimit    uint64     `json:"file_limit"`     // RLIMIT_FSIZE（字节）
+}
+
+func init() {
+	if len(os.Args) > 0 && os.Args[0] == nativeInitArg {
+		err := nativeInit()
+		// 只有初始化失败时才会执行到这里
+		errPipe := os.NewFile(nativeErrorFd, "error")
+		fmt.Fprint(errPipe, err.Error())
+		os.Exit(1)
+	}
+}
+
+// nativeInit 沙箱初始化进程入口，成功时exec为选手程序，不会返回
+// 初始化顺序：挂载根文件系统 -> 设置主机名与rlimit -> 丢弃capabilities -> 加入cgroup -> 安装seccomp -> exec
+func nativeInit() error {
+	// no_new_privs、seccomp、capabilities均为线程属性，必须在执行exec的同一线程上设置
+	runtime.LockOSThread()
+	for _, fd := range []int{nativeSpecFd, nativeErrorFd, nativeProcsFd} {
+		unix.CloseOnExec(fd)
+	}
+
+	var spec nativeSpec
+	specPipe := os.NewFile(nativeSpecFd, "spec")
+	if err := json.NewDecoder(specPipe).Decode(&spec); err != nil {
+		return fmt.Errorf("读取沙箱配置失败: %w", err)
+	}
+	specPipe.Close()
+	if len(spec.Args) == 0 {
+		return fmt.Errorf("沙箱未指定运行的程序")
+	}
+
+	if err := setupRootfs(&spec); err != nil {
+		return err
+	}
+	if err := unix.Sethostname([]byte(nativeHostname)); err != nil {
+		return fmt.Errorf("设置主机名失败: %w", err)
+	}
+	if err := setRlimits(&spec); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("设置no_new_privs失败: %w", err)
+	}
+
+	// 在exec前才加入cgroup，使内存峰值与CPU时间不包含初始化进程自身的开销
+	procs := os.NewFile(nativeProcsFd, "cgroup.procs")
+	if _, err := procs.WriteString("0"); err != nil {
+		return fmt.Errorf("加入cgroup失败: %w", err)
+	}
+	procs.Close()
+
+	if err := installSeccompFilter(); err != nil {
+		return err
+	}
+	if err := unix.Exec(spec.Args[0], spec.Args, spec.Env); err != nil {
+		return fmt.Errorf("执行%s失败: %w", spec.Args[0], err)
+	}
+	return nil
+}
+
+// setupRootfs 构建最小根文件系统并切换根目录
+// 新根目录为tmpfs，只读绑定挂载系统目录与测试数据，工作目录挂载为 /box，另挂载 /tmp、/proc 和常用设备文件
+func setupRootfs(spec *nativeSpec) error {
+	// 挂载事件不传播到宿主机
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %w", err)
+	}
+
+	root := spec.RootDir
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=1m,mode=755"); err != nil {
+		return fmt.Errorf("挂载根目录失败: %w", err)
+	}
+
+	for _, dir := range spec.ReadOnlyDirs {
+		if err := bindReadOnly(dir, filepath.Join(root, dir)); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range spec.DataFiles {
+		if err := bindReadOnly(f.Source, filepath.Join(root, f.BoxPath())); err != nil {
+			return err
+		}
+	}
+
+	box := filepath.Join(root, nativeBoxDir)
+	if err := os.Mkdir(box, 0755); err != nil {
+		return fmt.Errorf("创建工作目录失败: %w", err)
+	}
+	if err := unix.Mount(spec.WorkDir, box, "", unix.MS_BIND|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("挂载工作目录失败: %w", err)
+	}
+
+	tmp := filepath.Join(root, "tmp")
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		return fmt.Errorf("创建/tmp失败: %w", err)
+	}
+	if err := unix.Mount("tmpfs", tmp, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=16m,mode=1777"); err != nil {
+		return fmt.Errorf("挂载/tmp失败: %w", err)
+	}
+
+	proc := filepath.Join(root, "proc")
+	if err := os.Mkdir(proc, 0755); err != nil {
+		return fmt.Errorf("创建/proc失败: %w", err)
+	}
+	if err := unix.Mount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("挂载/proc失败: %w", err)
+	}
+
+	dev := filepath.Join(root, "dev")
+	if err := os.Mkdir(dev, 0755); err != nil {
+		return fmt.Errorf("创建/dev失败: %w", err)
+	}
+	for _, name := range []string{"null", "zero", "random", "urandom"} {
+		target := filepath.Join(dev, name)
+		if err := os.WriteFile(target, nil, 0666); err != nil {
+			return fmt.Errorf("创建/dev/%s失败: %w", name, err)
+		}
+		if err := unix.Mount("/dev/"+name, target, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("挂载/dev/%s失败: %w", name, err)
+		}
+	}
+
+	// 切换根目录并卸载原根目录
+	if err := os.Chdir(root); err != nil {
+		return fmt.Errorf("切换到新根目录失败: %w", err)
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root失败: %w", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("卸载原根目录失败: %w", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("设置根目录只读失败: %w", err)
+	}
+	if err := os.Chdir(nativeBoxDir); err != nil {
+		return fmt.Errorf("切换到工作目录失败: %w", err)
+	}
+	return nil
+}
+
+// bindReadOnly 将宿主机目录或文件只读绑定挂载到target
+// 宿主机目录为符号链接（如合并/usr后的/bin）时在新根目录中创建相同的链接；目录不存在时跳过
+func bindReadOnly(source string, target string) error {
+	info, err := os.Lstat(source)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取%s失败: %w", source, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(source)
+		if err != nil {
+			return fmt.Errorf("读取符号链接%s失败: %w", source, err)
+		}
+		if err := os.Symlink(link, target); err != nil {
+			return fmt.Errorf("创建符号链接%s失败: %w", target, err)
+		}
+		return nil
+	}
+
+	if err := createMountPoint(target, info.IsDir()); err != nil {
+		return err
+	}
+	if err := unix.Mount(source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("挂载%s失败: %w", source, err)
+	}
+	// 用户命名空间内重新挂载时必须保留源挂载点被锁定的标志
+	var st unix.Statfs_t
+	if err := unix.Statfs(source, &st); err != nil {
+		return fmt.Errorf("读取%s挂载标志失败: %w", source, err)
+	}
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV)
+	for stFlag, msFlag := range map[int64]uintptr{
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if st.Flags&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := unix.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("设置%s只读失败: %w", target, err)
+	}
+	return nil
+}
+
+// createMountPoint 创建绑定挂载的目标：目录或空文件
+func createMountPoint(target string, isDir bool) error {
+	if isDir {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("创建挂载点%s失败: %w", target, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建挂载点%s失败: %w", target, err)
+	}
+	if err := os.WriteFile(target, nil, 0644); err != nil {
+		return fmt.Errorf("创建挂载点%s失败: %w", target, err)
+	}
+	return nil
+}
+
+// setRlimits 设置资源限制
+// CPU时间到达软限制时发送SIGXCPU，再过1秒到达硬限制时发送SIGKILL
+func setRlimits(spec *nativeSpec) error {
+	limits := []struct {
+		resource int
+		limit    unix.Rlimit
+	}{
+		{unix.RLIMIT_CPU, unix.Rlimit{Cur: spec.CPULimit, Max: spec.CPULimit + 1}},
+		{unix.RLIMIT_STACK, unix.Rlimit{Cur: spec.StackLimit, Max: spec.StackLimit}},
+		{unix.RLIMIT_FSIZE, unix.Rlimit{Cur: spec.FileLimit, Max: spec.FileLimit}},
+		{unix.RLIMIT_CORE, unix.Rlimit{}},
+		{unix.RLIMIT_NOFILE, unix.Rlimit{Cur: 64, Max: 64}},
+	}
+	for _, l := range limits {
+		if err := unix.Setrlimit(l.resource, &l.limit); err != nil {
+			return fmt.Errorf("设置rlimit(%d)失败: %w", l.resource, err)
+		}
+	}
+	return nil
+}
+
+// dropCapabilities 丢弃全部capabilities
+// 沙箱内进程是用户命名空间中的root，清空bounding set后exec也无法重新获得任何capability
+func dropCapabilities() error {
+	for c := 0; ; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			if err == unix.EINVAL {
+				break
+			}
+			return fmt.Errorf("丢弃capability %d失败: %w", c, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("清除ambient capabilities失败: %w", err)
+	}
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("清除capabilities失败: %w", err)
+	}
+	return nil
+}
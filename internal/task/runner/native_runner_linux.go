@@ -0,0 +1,522 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hitwh-judge/internal/constants"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/task/cgroup"
+	file_util "hitwh-judge/internal/util/file"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NativeRunner 纯Go实现的沙箱运行器，不依赖isolate、nsjail等外部沙箱程序
+// 使用user/pid/mount/net/ipc/uts命名空间隔离，cgroup v2限制内存与进程数并统计CPU时间和内存峰值，
+// 配合rlimit与seccomp过滤危险系统调用。需要cgroup v2，且评测进程对cgroup父目录有写权限。
+type NativeRunner struct {
+	CgroupRoot string // 评测cgroup的父目录
+}
+
+// DefaultNativeSandboxConfig 默认原生沙箱配置，Path为评测cgroup的父目录
+var DefaultNativeSandboxConfig = model.SandboxConfig{
+	Type: "native",
+	Path: cgroup.DefaultRoot,
+}
+
+// nativeReadOnlyDirs 只读挂载到沙箱内的系统目录
+var nativeReadOnlyDirs = []string{"/bin", "/lib", "/lib64", "/usr"}
+
+// nativeEnv 沙箱内程序的环境变量
+var nativeEnv = []string{
+	"PATH=/usr/local/bin:/usr/bin:/bin",
+	"HOME=" + nativeBoxDir,
+}
+
+// nativeOverflowUID 以root运行评测服务时，沙箱内root映射到的宿主机用户（nobody）
+const nativeOverflowUID = 65534
+
+// nativeBoxSeq 用于生成唯一的cgroup名称
+var nativeBoxSeq atomic.Int64
+
+// nativeLimits 单次运行的资源限制
+type nativeLimits struct {
+	CPUTime  time.Duration // CPU时间限制
+	WallTime time.Duration // 墙钟时间限制
+	Memory   int64         // 内存限制（字节）
+	Stack    int64         // 栈限制（字节）
+	Output   int64         // 输出大小限制（字节）
+	Pids     int64         // 最大进程（线程）数
+}
+
+// nativeProcess 在沙箱内运行的命令
+type nativeProcess struct {
+	WorkDir   string     // 宿主机工作目录，挂载为沙箱内的 /box
+	DataFiles []dataFile // 只读挂载到沙箱内 /data 的测试数据文件
+	Args      []string   // 沙箱内的命令及参数
+	Stdin     *os.File   // 标准输入（nil时为/dev/null）
+	Stdout    io.Writer  // 标准输出
+	Stderr    io.Writer  // 标准错误
+	Limits    nativeLimits
+	// CloseAfterStart 沙箱进程启动后（或启动失败时）关闭的文件，用于交互题交出管道端
+	CloseAfterStart []*os.File
+}
+
+// nativeStatus 沙箱运行结果
+type nativeStatus struct {
+	CPUTime   time.Duration  // CPU时间（cgroup cpu.stat）
+	MemPeak   int64          // 内存峰值（cgroup memory.peak）
+	OOMKilled bool           // 是否因内存超限被终止
+	TimedOut  bool           // 是否因墙钟时间超限被终止
+	ExitCode  int            // 退出码
+	Signal    syscall.Signal // 终止信号（正常退出时为0）
+}
+
+// InitSandbox 创建沙箱工作目录（运行时挂载为沙箱内的 /box），由调用方负责删除
+func (nr *NativeRunner) InitSandbox() (string, error) {
+	workDir, _, err := createTmpDir()
+	return workDir, err
+}
+
+// RunInSandbox 在原生沙箱中运行普通程序，单独创建并删除工作目录
+// 同一提交的多个测试点应通过 Open 复用同一个工作目录
+func (nr *NativeRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResult {
+	session, err := nr.Open(runParams.ExePath)
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
+	defer session.Close()
+	return session.Run(runParams)
+}
+
+// nativeSession 复用同一个工作目录运行多个测试点的会话
+type nativeSession struct {
+	runner      *NativeRunner
+	workDir     string // 宿主机工作目录，挂载为沙箱内的 /box
+	cleanup     func()
+	exeFilename string // 工作目录中的可执行文件名
+}
+
+// Open 创建沙箱工作目录并放入可执行文件，之后每个测试点只重置工作目录中的输出文件
+func (nr *NativeRunner) Open(exePath string) (Session, error) {
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return nil, fmt.Errorf("创建沙箱工作目录失败: %w", err)
+	}
+	exeFilename := filepath.Base(exePath)
+	if err := copyIntoBox(exePath, filepath.Join(workDir, exeFilename), 0755); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("复制可执行文件到沙箱失败: %w", err)
+	}
+	return &nativeSession{runner: nr, workDir: workDir, cleanup: cleanup, exeFilename: exeFilename}, nil
+}
+
+// Close 删除沙箱工作目录
+func (s *nativeSession) Close() error {
+	s.cleanup()
+	return nil
+}
+
+// Run 在会话的工作目录中运行一个测试点
+func (s *nativeSession) Run(runParams model.RunParams) *model.TestCaseResult {
+	workDir, exeFilename := s.workDir, s.exeFilename
+	// 删除上一个测试点的输出以及程序留下的文件
+	if err := resetBox(workDir, exeFilename); err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
+
+	stdin, err := openInput(runParams, workDir)
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
+	defer stdin.Close()
+
+	// 输出写入文件而非管道，使RLIMIT_FSIZE生效，避免超大输出占用评测服务内存
+	stdout, err := os.Create(filepath.Join(workDir, "output.txt"))
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "创建输出文件失败: %v", err)
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(filepath.Join(workDir, "error.txt"))
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "创建错误输出文件失败: %v", err)
+	}
+	defer stderr.Close()
+
+	limits := nativeLimits{
+		CPUTime:  time.Duration(runParams.TimeLimit) * time.Second,
+		WallTime: time.Duration(runParams.TimeLimit*2) * time.Second,
+		Memory:   runParams.MemLimit * 1024 * 1024,
+		Stack:    runParams.StackLimit,
+		Output:   outputLimit(runParams),
+		Pids:     128,
+	}
+	st, err := s.runner.run(nativeProcess{
+		WorkDir: workDir,
+		Args:    []string{filepath.Join(nativeBoxDir, exeFilename)},
+		Stdin:   stdin,
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Limits:  limits,
+	})
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
+
+	output, outputExceeded, err := collectOutput(stdout.Name(), runParams)
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
+	status, errorMsg := st.verdict(limits)
+	if outputExceeded && status != model.StatusMLE && status != model.StatusTLE {
+		status, errorMsg = model.StatusOLE, outputLimitMessage(limits.Output)
+	}
+
+	zap.L().Info("Native sandbox execution result",
+		zap.Int("test_case", runParams.TestCaseIndex),
+		zap.Duration("cpu_time", st.CPUTime),
+		zap.Int64("memory_bytes", st.MemPeak),
+		zap.String("status", string(status)),
+		zap.Int64("time_limit_sec", runParams.TimeLimit),
+		zap.Int64("mem_limit_mb", runParams.MemLimit),
+	)
+
+	return &model.TestCaseResult{
+		TestCaseIndex: runParams.TestCaseIndex,
+		Status:        status,
+		TimeUsed:      st.CPUTime,
+		MemUsed:       uint64(st.MemPeak),
+		Output:        output,
+		Error:         errorMsg,
+	}
+}
+
+// RunInteractiveInSandbox 在原生沙箱中运行交互题
+// 交互程序与选手程序分别运行在独立的命名空间和cgroup中，以管道相连，各自独立限制并统计资源
+func (nr *NativeRunner) RunInteractiveInSandbox(runParams model.RunParams) *model.TestCaseResult {
+	return runInteraction(runParams, nr.runParty)
+}
+
+// runParty 在独立的工作目录和cgroup中运行交互的一方
+func (nr *NativeRunner) runParty(spec partySpec, stdin, stdout *os.File) partyResult {
+	// 提前返回时同样需要关闭管道，重复关闭没有影响
+	defer stdin.Close()
+	defer stdout.Close()
+
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return failedParty("创建沙箱工作目录失败: %v", err)
+	}
+	defer cleanup()
+
+	exeFilename := filepath.Base(spec.ExePath)
+	if err := copyIntoBox(spec.ExePath, filepath.Join(workDir, exeFilename), 0755); err != nil {
+		return failedParty("复制%s到沙箱失败: %v", exeFilename, err)
+	}
+
+	limits := nativeLimits{
+		CPUTime:  time.Duration(spec.Limits.TimeLimit) * time.Second,
+		WallTime: time.Duration(spec.Limits.WallTime) * time.Second,
+		Memory:   spec.Limits.MemLimit * 1024 * 1024,
+		Stack:    spec.Limits.StackLimit,
+		Output:   constants.MaxOutputSize,
+		Pids:     64,
+	}
+	stderr := newLimitedBuffer()
+	st, err := nr.run(nativeProcess{
+		WorkDir:         workDir,
+		DataFiles:       spec.DataFiles,
+		Args:            append([]string{"./" + exeFilename}, spec.Args...),
+		Stdin:           stdin,
+		Stdout:          stdout,
+		Stderr:          stderr,
+		CloseAfterStart: []*os.File{stdin, stdout},
+		Limits:          limits,
+	})
+	if err != nil {
+		return failedParty("%v", err)
+	}
+
+	zap.L().Debug("Native sandbox interactive party result",
+		zap.String("exe", exeFilename),
+		zap.Any("status", st),
+	)
+
+	r := partyResult{
+		Status:   model.StatusAC,
+		ExitCode: st.ExitCode,
+		Signal:   st.Signal,
+		TimeUsed: st.CPUTime,
+		MemUsed:  uint64(st.MemPeak),
+		Stderr:   stderr.String(),
+	}
+	// 退出码由调用方解释，只有超限和被信号终止才改变状态
+	if status, msg := st.verdict(limits); status != model.StatusRE || st.Signal != 0 {
+		r.Status, r.Error = status, msg
+	}
+	return r
+}
+
+// RunCheckerInSandbox 在原生沙箱中运行特殊评测程序（checker）
+// 调用方式与testlib一致：checker input.txt user_output.txt answer.txt
+func (nr *NativeRunner) RunCheckerInSandbox(runParams model.RunParams) *model.CheckerResult {
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return &model.CheckerResult{Error: fmt.Sprintf("创建沙箱工作目录失败: %v", err)}
+	}
+	defer cleanup()
+
+	// 复制checker到工作目录，测试数据与程序输出只读挂载到沙箱内的 /data
+	checkerFilename := filepath.Base(runParams.SpecialExePath)
+	if err := copyIntoBox(runParams.SpecialExePath, filepath.Join(workDir, checkerFilename), 0755); err != nil {
+		return &model.CheckerResult{Error: fmt.Sprintf("复制%s到沙箱失败: %v", checkerFilename, err)}
+	}
+	files := checkerDataFiles(runParams)
+
+	limits := nativeLimits{
+		CPUTime:  constants.CheckerTimeLimit * time.Second,
+		WallTime: constants.CheckerTimeLimit * 2 * time.Second,
+		Memory:   constants.CheckerMemoryLimit * 1024 * 1024,
+		Output:   constants.MaxOutputSize,
+		Pids:     16,
+	}
+	var stderr bytes.Buffer
+	st, err := nr.run(nativeProcess{
+		WorkDir:   workDir,
+		DataFiles: files,
+		Args:      []string{"./" + checkerFilename, files[0].BoxPath(), files[1].BoxPath(), files[2].BoxPath()},
+		Stdout:    io.Discard,
+		Stderr:    &stderr, // testlib将评测信息写入stderr
+		Limits:    limits,
+	})
+	if err != nil {
+		return &model.CheckerResult{Error: err.Error()}
+	}
+
+	zap.L().Debug("Native sandbox checker result",
+		zap.Int("test_case", runParams.TestCaseIndex),
+		zap.Any("status", st),
+		zap.String("checker_message", stderr.String()),
+	)
+
+	switch {
+	case st.OOMKilled:
+		return &model.CheckerResult{Error: "checker内存超限"}
+	case st.TimedOut || st.CPUTime > limits.CPUTime:
+		return &model.CheckerResult{Error: "checker运行超时"}
+	case st.Signal != 0:
+		return &model.CheckerResult{Error: fmt.Sprintf("checker收到信号 %d 终止", st.Signal)}
+	}
+	return &model.CheckerResult{
+		ExitCode: st.ExitCode,
+		Message:  stderr.String(),
+	}
+}
+
+// run 在新的命名空间和cgroup中运行命令，等待其结束并读取资源使用情况
+// 返回错误表示沙箱自身出错（如cgroup不可用、初始化失败），程序运行异常通过nativeStatus体现
+func (nr *NativeRunner) run(p nativeProcess) (*nativeStatus, error) {
+	closeAfterStart := func() {
+		for _, f := range p.CloseAfterStart {
+			f.Close()
+		}
+	}
+	defer closeAfterStart()
+
+	id := fmt.Sprintf("box-%d-%d", os.Getpid(), nativeBoxSeq.Add(1))
+	cg, err := cgroup.NewCgroupManager(nr.CgroupRoot, id)
+	if err != nil {
+		return nil, fmt.Errorf("创建cgroup失败: %w", err)
+	}
+	defer func() {
+		_ = cg.Kill()
+		if err := cg.Cleanup(); err != nil {
+			zap.L().Warn("清理cgroup失败", zap.String("cgroup", cg.GetCgroupPath()), zap.Error(err))
+		}
+	}()
+	// 限制为单核，CPU时间由cpu.stat统计
+	if err := cg.SetLimits(cgroup.CPUPeriodUS, p.Limits.Memory); err != nil {
+		return nil, err
+	}
+	if err := cg.SetPidsLimit(p.Limits.Pids); err != nil {
+		return nil, err
+	}
+	procs, err := cg.OpenProcs()
+	if err != nil {
+		return nil, fmt.Errorf("打开cgroup.procs失败: %w", err)
+	}
+	defer procs.Close()
+
+	rootDir, err := os.MkdirTemp("", constants.TempDirPrefix+"root-")
+	if err != nil {
+		return nil, fmt.Errorf("创建沙箱根目录失败: %w", err)
+	}
+	defer os.RemoveAll(rootDir)
+	// 沙箱内用户映射为宿主机的其他用户，需要能访问挂载点
+	if err := os.Chmod(rootDir, 0755); err != nil {
+		return nil, fmt.Errorf("修改沙箱根目录权限失败: %w", err)
+	}
+
+	specR, specW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建管道失败: %w", err)
+	}
+	defer specW.Close()
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		specR.Close()
+		return nil, fmt.Errorf("创建管道失败: %w", err)
+	}
+	defer errR.Close()
+
+	stack := p.Limits.Stack
+	if stack <= 0 {
+		stack = p.Limits.Memory
+	}
+	spec := nativeSpec{
+		RootDir:      rootDir,
+		WorkDir:      p.WorkDir,
+		ReadOnlyDirs: nativeReadOnlyDirs,
+		DataFiles:    p.DataFiles,
+		Args:         p.Args,
+		Env:          nativeEnv,
+		CPULimit:     uint64((p.Limits.CPUTime + time.Second - 1) / time.Second),
+		StackLimit:   uint64(stack),
+		FileLimit:    uint64(p.Limits.Output + 1), // 略大于输出限制以便判定超限
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = nativeOverflowUID, nativeOverflowUID
+	}
+	cmd := &exec.Cmd{
+		Path:       "/proc/self/exe",
+		Args:       []string{nativeInitArg},
+		Stdout:     p.Stdout,
+		Stderr:     p.Stderr,
+		ExtraFiles: []*os.File{specR, errW, procs},
+		SysProcAttr: &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS |
+				syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+			UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}},
+			GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: gid, Size: 1}},
+			GidMappingsEnableSetgroups: false,
+			// 切换为命名空间内的root，否则exec初始化进程时会丢失命名空间内的capabilities
+			Credential: &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true},
+			Pdeathsig:  syscall.SIGKILL,
+		},
+	}
+	if p.Stdin != nil {
+		cmd.Stdin = p.Stdin
+	}
+
+	startErr := cmd.Start()
+	specR.Close()
+	errW.Close()
+	closeAfterStart()
+	if startErr != nil {
+		return nil, fmt.Errorf("启动沙箱失败: %w", startErr)
+	}
+	if err := json.NewEncoder(specW).Encode(spec); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("发送沙箱配置失败: %w", err)
+	}
+	specW.Close()
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(p.Limits.WallTime, func() {
+		timedOut.Store(true)
+		_ = cg.Kill()
+		_ = cmd.Process.Kill()
+	})
+	_ = cmd.Wait()
+	timer.Stop()
+
+	// exec成功后错误管道被关闭，读到内容说明初始化失败
+	if initErr, _ := io.ReadAll(errR); len(initErr) > 0 {
+		return nil, fmt.Errorf("沙箱初始化失败: %s", initErr)
+	}
+
+	usage, err := cg.ReadUsage()
+	if err != nil {
+		return nil, fmt.Errorf("读取资源使用情况失败: %w", err)
+	}
+	st := &nativeStatus{
+		CPUTime:   time.Duration(usage.CPUUsec) * time.Microsecond,
+		MemPeak:   usage.MemPeak,
+		OOMKilled: usage.OOMKilled,
+		TimedOut:  timedOut.Load(),
+	}
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+		if ws.Signaled() {
+			st.Signal = ws.Signal()
+		} else {
+			st.ExitCode = ws.ExitStatus()
+		}
+	}
+	return st, nil
+}
+
+// verdict 根据运行结果判定测试点状态
+func (st *nativeStatus) verdict(limits nativeLimits) (model.JudgeStatus, string) {
+	switch {
+	case st.OOMKilled:
+		return model.StatusMLE, fmt.Sprintf("内存超限: 峰值 %d bytes, 限制 %d MB", st.MemPeak, limits.Memory/(1024*1024))
+	case st.CPUTime > limits.CPUTime || st.Signal == syscall.SIGXCPU:
+		return model.StatusTLE, fmt.Sprintf("CPU时间超限: %v > %v", st.CPUTime, limits.CPUTime)
+	case st.TimedOut:
+		return model.StatusTLE, fmt.Sprintf("墙钟时间超限: > %v", limits.WallTime)
+	case st.Signal == syscall.SIGSYS:
+		return model.StatusRE, "程序执行了被禁止的系统调用 (SIGSYS)"
+	case st.Signal == syscall.SIGXFSZ:
+		return model.StatusOLE, outputLimitMessage(limits.Output)
+	case st.Signal != 0:
+		return model.StatusRE, fmt.Sprintf("运行时错误 (signal: %v)", st.Signal)
+	case st.ExitCode != 0:
+		return model.StatusRE, fmt.Sprintf("非零退出码: %d", st.ExitCode)
+	}
+	return model.StatusAC, ""
+}
+
+// copyIntoBox 复制文件到沙箱工作目录，并设置沙箱内用户可访问的权限
+func copyIntoBox(src string, dst string, perm os.FileMode) error {
+	if err := file_util.CopyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Chmod(dst, perm)
+}
+
+// openInput 打开测试点输入，未提供输入文件时将输入数据写入工作目录
+func openInput(runParams model.RunParams, workDir string) (*os.File, error) {
+	if runParams.InputFile != "" {
+		f, err := os.Open(runParams.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("打开输入文件失败: %w", err)
+		}
+		return f, nil
+	}
+	inputPath := filepath.Join(workDir, "input.txt")
+	if err := os.WriteFile(inputPath, []byte(runParams.Input), 0644); err != nil {
+		return nil, fmt.Errorf("写入输入文件失败: %w", err)
+	}
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开输入文件失败: %w", err)
+	}
+	return f, nil
+}
+
+func init() {
+	Register(DefaultNativeSandboxConfig, func(sandboxPath string) Runner {
+		return &NativeRunner{CgroupRoot: sandboxPath}
+	})
+}
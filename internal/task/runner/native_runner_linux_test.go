@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"bytes"
+	"hitwh-judge/internal/model"
+	"hitwh-judge/internal/task/cgroup"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNativeVerdict(t *testing.T) {
+	limits := nativeLimits{
+		CPUTime:  time.Second,
+		WallTime: 2 * time.Second,
+		Memory:   256 * 1024 * 1024,
+		Output:   1024,
+	}
+	tests := []struct {
+		name   string
+		status nativeStatus
+		want   model.JudgeStatus
+	}{
+		{name: "正常退出", status: nativeStatus{CPUTime: 500 * time.Millisecond}, want: model.StatusAC},
+		{name: "内存超限", status: nativeStatus{OOMKilled: true, Signal: syscall.SIGKILL}, want: model.StatusMLE},
+		{name: "CPU时间超限", status: nativeStatus{CPUTime: 1100 * time.Millisecond}, want: model.StatusTLE},
+		{name: "SIGXCPU", status: nativeStatus{Signal: syscall.SIGXCPU}, want: model.StatusTLE},
+		{name: "墙钟超时", status: nativeStatus{TimedOut: true, Signal: syscall.SIGKILL}, want: model.StatusTLE},
+		{name: "禁止的系统调用", status: nativeStatus{Signal: syscall.SIGSYS}, want: model.StatusRE},
+		{name: "输出超限", status: nativeStatus{Signal: syscall.SIGXFSZ}, want: model.StatusOLE},
+		{name: "段错误", status: nativeStatus{Signal: syscall.SIGSEGV}, want: model.StatusRE},
+		{name: "非零退出码", status: nativeStatus{ExitCode: 1}, want: model.StatusRE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := tt.status.verdict(limits)
+			if got != tt.want {
+				t.Errorf("verdict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSeccompFilter(t *testing.T) {
+	filter, err := buildSeccompFilter()
+	if err != nil {
+		t.Skipf("当前架构不支持seccomp: %v", err)
+	}
+	if len(filter) > 0xffff {
+		t.Fatalf("过滤器过长: %d", len(filter))
+	}
+	// 跳转偏移均不能越过过滤器末尾
+	for i, ins := range filter {
+		if ins.Code&0x07 != 0x05 { // BPF_JMP
+			continue
+		}
+		if int(ins.Jt) >= len(filter)-i-1 || int(ins.Jf) >= len(filter)-i-1 {
+			t.Errorf("第%d条指令跳转越界: %+v", i, ins)
+		}
+	}
+}
+
+// nativeTestRunner 返回可用的原生沙箱，环境不支持cgroup v2或用户命名空间时跳过测试
+func nativeTestRunner(t *testing.T) *NativeRunner {
+	t.Helper()
+	cg, err := cgroup.NewCgroupManager("", "probe")
+	if err != nil {
+		t.Skipf("cgroup v2不可用: %v", err)
+	}
+	defer cg.Cleanup()
+	if err := cg.SetLimits(cgroup.CPUPeriodUS, 64*1024*1024); err != nil {
+		t.Skipf("cgroup v2内存控制器不可用: %v", err)
+	}
+	return &NativeRunner{CgroupRoot: cgroup.DefaultRoot}
+}
+
+func TestNativeRun(t *testing.T) {
+	nr := nativeTestRunner(t)
+	limits := nativeLimits{
+		CPUTime:  time.Second,
+		WallTime: 2 * time.Second,
+		Memory:   64 * 1024 * 1024,
+		Output:   1024,
+		Pids:     16,
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want model.JudgeStatus
+	}{
+		{name: "正常退出", args: []string{"/bin/sh", "-c", "echo hello"}, want: model.StatusAC},
+		{name: "非零退出码", args: []string{"/bin/sh", "-c", "exit 3"}, want: model.StatusRE},
+		{name: "死循环", args: []string{"/bin/sh", "-c", "while :; do :; done"}, want: model.StatusTLE},
+		{name: "禁止挂载", args: []string{"/bin/sh", "-c", "mount -t tmpfs none /tmp"}, want: model.StatusRE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			st, err := nr.run(nativeProcess{
+				WorkDir: t.TempDir(),
+				Args:    tt.args,
+				Stdout:  &stdout,
+				Stderr:  os.Stderr,
+				Limits:  limits,
+			})
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if got, msg := st.verdict(limits); got != tt.want {
+				t.Errorf("verdict() = %v (%s), want %v", got, msg, tt.want)
+			}
+			if tt.want == model.StatusAC && strings.TrimSpace(stdout.String()) != "hello" {
+				t.Errorf("stdout = %q, want %q", stdout.String(), "hello")
+			}
+		})
+	}
+}
+
+func TestNativeSession(t *testing.T) {
+	nr := nativeTestRunner(t)
+	dir := t.TempDir()
+	// 上一个测试点留下的文件存在时以非零退出码结束，用于检查每次运行前是否重置了工作目录
+	exePath := filepath.Join(dir, "main")
+	script := "#!/bin/sh\n[ -e " + nativeBoxDir + "/left.txt ] && exit 1\ntouch " + nativeBoxDir + "/left.txt\ncat\n"
+	if err := os.WriteFile(exePath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := nr.Open(exePath)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer session.Close()
+	for i := 0; i < 3; i++ {
+		input := filepath.Join(dir, "input.txt")
+		if err := os.WriteFile(input, []byte(strings.Repeat("x", i+1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result := session.Run(model.RunParams{TestCaseIndex: i, InputFile: input, TimeLimit: 1, MemLimit: 64})
+		if result.Status != model.StatusAC || result.Output != strings.Repeat("x", i+1) {
+			t.Errorf("Run(%d) = %s %q (%s)", i, result.Status, result.Output, result.Error)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompDeniedSyscalls 沙箱内禁止的系统调用，调用时直接终止进程（SIGSYS）
+var seccompDeniedSyscalls = []uintptr{
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_CHROOT,
+	unix.SYS_UNSHARE,
+	unix.SYS_SETNS,
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_REBOOT,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_KEYCTL,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_ACCT,
+	unix.SYS_QUOTACTL,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_FSOPEN,
+	unix.SYS_FSCONFIG,
+	unix.SYS_FSMOUNT,
+	unix.SYS_FSPICK,
+	unix.SYS_MOUNT_SETATTR,
+	unix.SYS_SYSLOG,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_ADJTIMEX,
+}
+
+// cloneNamespaceFlags clone时创建新命名空间的标志位，沙箱内禁止使用
+const cloneNamespaceFlags = unix.CLONE_NEWNS | unix.CLONE_NEWUTS | unix.CLONE_NEWIPC | unix.CLONE_NEWUSER |
+	unix.CLONE_NEWPID | unix.CLONE_NEWNET | unix.CLONE_NEWCGROUP
+
+// seccomp_data 中各字段的偏移量
+const (
+	seccompDataNrOffset   = 0
+	seccompDataArchOffset = 4
+	seccompDataArg0Offset = 16 // args[0]的低32位（小端序）
+)
+
+// x32 ABI的系统调用号标志位，amd64上一律禁止
+const x32SyscallBit = 0x40000000
+
+// auditArch 返回当前架构对应的AUDIT_ARCH值
+func auditArch() (uint32, error) {
+	switch runtime.GOARCH {
+	case "amd64":
+		return unix.AUDIT_ARCH_X86_64, nil
+	case "arm64":
+		return unix.AUDIT_ARCH_AARCH64, nil
+	default:
+		return 0, fmt.Errorf("seccomp不支持当前架构: %s", runtime.GOARCH)
+	}
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt uint8, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// buildSeccompFilter 构建seccomp BPF过滤器
+// 架构不匹配、调用禁止的系统调用或clone新命名空间时终止进程；clone3的参数无法检查，返回ENOSYS使libc回退到clone
+func buildSeccompFilter() ([]unix.SockFilter, error) {
+	arch, err := auditArch()
+	if err != nil {
+		return nil, err
+	}
+
+	const (
+		ld   = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq  = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge  = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		jset = unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K
+		ret  = unix.BPF_RET | unix.BPF_K
+	)
+	kill := bpfStmt(ret, unix.SECCOMP_RET_KILL_PROCESS)
+
+	filter := []unix.SockFilter{
+		bpfStmt(ld, seccompDataArchOffset),
+		bpfJump(jeq, arch, 1, 0),
+		kill,
+		bpfStmt(ld, seccompDataNrOffset),
+	}
+	if runtime.GOARCH == "amd64" {
+		filter = append(filter, bpfJump(jge, x32SyscallBit, 0, 1), kill)
+	}
+	filter = append(filter,
+		bpfJump(jeq, unix.SYS_CLONE3, 0, 1),
+		bpfStmt(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+		bpfJump(jeq, unix.SYS_CLONE, 0, 3),
+		bpfStmt(ld, seccompDataArg0Offset),
+		bpfJump(jset, cloneNamespaceFlags, 0, 1),
+		kill,
+		// 重新加载系统调用号，供后续比较使用
+		bpfStmt(ld, seccompDataNrOffset),
+	)
+	for _, nr := range seccompDeniedSyscalls {
+		filter = append(filter, bpfJump(jeq, uint32(nr), 0, 1), kill)
+	}
+	filter = append(filter, bpfStmt(ret, unix.SECCOMP_RET_ALLOW))
+	return filter, nil
+}
+
+// installSeccompFilter 为当前线程安装seccomp过滤器，调用前必须设置 no_new_privs
+func installSeccompFilter() error {
+	filter, err := buildSeccompFilter()
+	if err != nil {
+		return err
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("安装seccomp过滤器失败: %w", err)
+	}
+	return nil
+}
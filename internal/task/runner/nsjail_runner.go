@@ -3,8 +3,10 @@ package runner
 import (
 	"bytes"
 	"fmt"
+	"hitwh-judge/internal/constants"
 	"hitwh-judge/internal/model"
-	"io/ioutil"
+	file_util "hitwh-judge/internal/util/file"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -38,6 +40,14 @@ type ResourceUsage struct {
 	Memory   int64         // 内存使用（字节）
 }
 
+// nsjailNormalScript 普通题运行脚本
+const nsjailNormalScript = "normal_judge.sh"
+
+// Open 打开沙箱会话，nsjail每个测试点单独准备工作目录并启动沙箱
+func (nr *NsJailRunner) Open(exePath string) (Session, error) {
+	return &perRunSession{runner: nr, exePath: exePath}, nil
+}
+
 // RunInSandbox 在NsJail沙箱中运行普通程序，返回详细的资源使用信息
 func (nr *NsJailRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResult {
 	exePath := runParams.ExePath
@@ -62,72 +72,56 @@ func (nr *NsJailRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseR
 			Error:         fmt.Sprintf("获取可执行文件绝对路径失败: %v", err),
 		}
 	}
-	fmt.Println("absExePath", absExePath)
 	exeDir := filepath.Dir(absExePath)
 
+	// 沙箱以单独的工作目录为根目录，其中只有可执行文件和运行脚本
+	// 不能直接chroot到程序所在的任务目录，否则选手程序可以读取其中的checker、交互程序和其他测试点的输出
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "创建沙箱工作目录失败: %v", err)
+	}
+	defer cleanup()
+
+	exeFilename := filepath.Base(absExePath)
+	if err := file_util.CopyFile(absExePath, filepath.Join(workDir, exeFilename)); err != nil {
+		return systemError(runParams.TestCaseIndex, "复制可执行文件到沙箱失败: %v", err)
+	}
 	// 普通题使用normal_judge.sh脚本
-	scriptSrcPath := "./scripts/runner/normal_judge.sh"
-	scriptDstPath := filepath.Join(exeDir, "normal_judge.sh")
+	if _, err := copyScript(nsjailNormalScript, workDir); err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
+	}
 
-	scriptContent, err := ioutil.ReadFile(scriptSrcPath)
+	// 程序输出写入文件而非管道，使rlimit_fsize生效，避免超大输出占用评测服务内存
+	// 输出文件放在沙箱之外，程序只能通过标准输出写入
+	maxOutput := outputLimit(runParams)
+	outputFile, err := os.CreateTemp(exeDir, "output-*.txt")
 	if err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("无法读取normal_judge.sh脚本: %v", err),
-		}
+		return systemError(runParams.TestCaseIndex, "创建输出文件失败: %v", err)
 	}
+	defer os.Remove(outputFile.Name())
+	defer outputFile.Close()
 
-	if err := ioutil.WriteFile(scriptDstPath, scriptContent, 0755); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("无法写入normal_judge.sh脚本到执行目录: %v", err),
-		}
+	// 构建NsJail命令，输入文件只读挂载到沙箱内的 /data
+	input := dataFile{Source: runParams.InputFile, Name: "input.txt"}
+	bindArgs, err := nsjailDataArgs([]dataFile{input})
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
-
-	// 构建NsJail命令
-	// 添加资源限制参数
-	cmd := exec.Command(
-		"sudo",
-		nr.NsJailPath,
-		"-Mo",                  // 一次性模式
-		"-N",                   // 禁用网络
-		"--rlimit_nproc", "32", // 进程数限制
-		"--rlimit_as", fmt.Sprintf("%d", memoryLimit*1024*1024), // 内存限制（字节）
-		"--rlimit_cpu", fmt.Sprintf("%d", timeLimit+1), // CPU时间限制（秒）
-		"--time_limit", fmt.Sprintf("%d", timeLimit*2), // 墙钟时间限制（秒）
-		"--chroot", exeDir, // chroot到可执行文件目录
-		"--user", "99999", // 使用非特权用户
-		"--group", "99999", // 使用非特权组
-		"--disable_clone_newuser", // 禁用user namespace
-		"--bindmount_ro", "/bin",  // 挂载/bin目录
-		"--bindmount_ro", "/lib", // 挂载/lib目录
-		"--bindmount_ro", "/lib64", // 挂载/lib64目录
+	args := nsjailArgs(workDir, timeLimit+1, timeLimit*2, memoryLimit)
+	args = append(args, bindArgs...)
+	args = append(args,
+		"--rlimit_fsize", fmt.Sprintf("%d", maxOutput/(1024*1024)+1), // 文件大小限制（MB），略大于输出限制以便判定超限
 		"--",
 		"/bin/bash",
-		"normal_judge.sh",
-		filepath.Base(absExePath),
-		filepath.Base(runParams.InputFile),
+		nsjailNormalScript,
+		exeFilename,
+		input.BoxPath(),
 	)
-
-	// 为普通题设置输入
-	inputFileReader, err := ioutil.ReadFile(runParams.InputFile)
-	if err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("读取临时输入文件失败: %v", err),
-		}
-	}
-
-	var stdin bytes.Buffer
-	stdin.Write(inputFileReader)
-	cmd.Stdin = &stdin
+	cmd := exec.Command("sudo", append([]string{nr.NsJailPath}, args...)...)
 
 	// 捕获输出和错误
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	var stderr bytes.Buffer
+	cmd.Stdout = outputFile
 	cmd.Stderr = &stderr
 
 	// 记录开始时间（墙钟时间）
@@ -140,33 +134,22 @@ func (nr *NsJailRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseR
 	realTime := time.Since(startTime)
 
 	// 获取资源使用情况
-	var rusage syscall.Rusage
-	var cpuTime time.Duration
-	var memUsed int64
-
-	if cmd.ProcessState != nil {
-		// 获取进程的资源使用统计
-		sysUsage := cmd.ProcessState.SysUsage()
-		if sysUsage != nil {
-			if usage, ok := sysUsage.(*syscall.Rusage); ok {
-				rusage = *usage
-				// CPU时间 = 用户态时间 + 内核态时间
-				cpuTime = time.Duration(rusage.Utime.Sec)*time.Second + time.Duration(rusage.Utime.Usec)*time.Microsecond +
-					time.Duration(rusage.Stime.Sec)*time.Second + time.Duration(rusage.Stime.Usec)*time.Microsecond
-				// 最大常驻集大小（RSS），单位是KB，需要转换为字节
-				memUsed = rusage.Maxrss * 1024
-			}
-		}
-	}
+	cpuTime, memUsed := processUsage(cmd)
 
-	output := normalizeString(stdout.String())
+	output, outputExceeded, readErr := collectOutput(outputFile.Name(), runParams)
+	if readErr != nil {
+		return systemError(runParams.TestCaseIndex, "%v", readErr)
+	}
 	errOutput := stderr.String()
 
 	// 解析错误类型和状态
 	status := model.StatusAC
 	var errorMsg string
 
-	if err != nil {
+	if outputExceeded {
+		status = model.StatusOLE
+		errorMsg = outputLimitMessage(maxOutput)
+	} else if err != nil {
 		zap.L().Error("NsJail execution error", zap.Error(err), zap.String("stderr", errOutput))
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			status, errorMsg = parseNsJailError(errOutput, exitErr, cpuTime, timeLimit, memUsed, memoryLimit)
@@ -224,6 +207,8 @@ func parseNsJailError(stderr string, exitErr *exec.ExitError, cpuTime time.Durat
 				switch signal {
 				case syscall.SIGXCPU:
 					return model.StatusTLE, "CPU时间超限信号 (SIGXCPU)"
+				case syscall.SIGXFSZ:
+					return model.StatusOLE, "输出超限 (SIGXFSZ)"
 				case syscall.SIGKILL:
 					// SIGKILL可能是内存超限或时间超限
 					if strings.Contains(stderr, "memory limit exceeded") || strings.Contains(stderr, "rlimit_as") {
@@ -266,3 +251,226 @@ func parseNsJailError(stderr string, exitErr *exec.ExitError, cpuTime time.Durat
 
 	return model.StatusRE, fmt.Sprintf("运行时错误: %s", stderr)
 }
+
+// nsjailArgs 构建NsJail公共参数
+// cpuLimit、wallLimit 单位为秒，memLimit 单位为MB；沙箱以chrootDir为根目录，并以只读方式挂载系统目录
+func nsjailArgs(chrootDir string, cpuLimit int64, wallLimit int64, memLimit int64) []string {
+	return []string{
+		"-Mo",                  // 一次性模式
+		"-N",                   // 禁用网络
+		"--rlimit_nproc", "32", // 进程数限制
+		"--rlimit_as", fmt.Sprintf("%d", memLimit), // 内存限制（MB）
+		"--rlimit_cpu", fmt.Sprintf("%d", cpuLimit), // CPU时间限制（秒）
+		"--time_limit", fmt.Sprintf("%d", wallLimit), // 墙钟时间限制（秒）
+		"--chroot", chrootDir, // chroot到工作目录
+		"--user", "99999", // 使用非特权用户
+		"--group", "99999", // 使用非特权组
+		"--disable_clone_newuser", // 禁用user namespace
+		"--bindmount_ro", "/bin",  // 挂载/bin目录
+		"--bindmount_ro", "/lib", // 挂载/lib目录
+		"--bindmount_ro", "/lib64", // 挂载/lib64目录
+		"--bindmount_ro", "/usr", // 挂载/usr目录
+	}
+}
+
+// nsjailDataArgs 构建将测试数据文件只读绑定挂载到沙箱内 /data 的NsJail参数
+func nsjailDataArgs(files []dataFile) ([]string, error) {
+	args := make([]string, 0, 2*len(files))
+	for _, f := range files {
+		source, err := filepath.Abs(f.Source)
+		if err != nil {
+			return nil, fmt.Errorf("获取%s绝对路径失败: %w", f.Name, err)
+		}
+		args = append(args, "--bindmount_ro", source+":"+f.BoxPath())
+	}
+	return args, nil
+}
+
+// processUsage 获取已结束进程的资源使用情况
+func processUsage(cmd *exec.Cmd) (cpuTime time.Duration, memUsed int64) {
+	if cmd.ProcessState == nil {
+		return 0, 0
+	}
+	if usage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		// CPU时间 = 用户态时间 + 内核态时间
+		cpuTime = time.Duration(usage.Utime.Sec)*time.Second + time.Duration(usage.Utime.Usec)*time.Microsecond +
+			time.Duration(usage.Stime.Sec)*time.Second + time.Duration(usage.Stime.Usec)*time.Microsecond
+		// 最大常驻集大小（RSS），单位是KB，需要转换为字节
+		memUsed = usage.Maxrss * 1024
+	}
+	return cpuTime, memUsed
+}
+
+// InitSandbox 创建NsJail沙箱工作目录（运行时作为chroot根目录），由调用方负责删除
+func (nr *NsJailRunner) InitSandbox() (string, error) {
+	workDir, _, err := createTmpDir()
+	return workDir, err
+}
+
+// RunInteractiveInSandbox 在NsJail沙箱中运行交互题
+// 交互程序与选手程序分别运行在两个NsJail沙箱中，以管道相连，各自独立限制并统计资源
+func (nr *NsJailRunner) RunInteractiveInSandbox(runParams model.RunParams) *model.TestCaseResult {
+	return runInteraction(runParams, nr.runParty)
+}
+
+// runParty 在独立的工作目录中运行交互的一方
+func (nr *NsJailRunner) runParty(spec partySpec, stdin, stdout *os.File) partyResult {
+	// 提前返回时同样需要关闭管道，重复关闭没有影响
+	defer stdin.Close()
+	defer stdout.Close()
+
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return failedParty("创建沙箱工作目录失败: %v", err)
+	}
+	defer cleanup()
+
+	exeFilename := filepath.Base(spec.ExePath)
+	if err := file_util.CopyFile(spec.ExePath, filepath.Join(workDir, exeFilename)); err != nil {
+		return failedParty("复制%s到沙箱失败: %v", exeFilename, err)
+	}
+	// 测试数据只读挂载到沙箱内的 /data
+	bindArgs, err := nsjailDataArgs(spec.DataFiles)
+	if err != nil {
+		return failedParty("%v", err)
+	}
+
+	limits := spec.Limits
+	args := nsjailArgs(workDir, limits.TimeLimit, limits.WallTime, limits.MemLimit)
+	args = append(args, bindArgs...)
+	if spec.Trusted {
+		args = append(args, "--rw") // 交互程序需要写入输出文件
+	}
+	args = append(args,
+		"--really_quiet", // 仅输出致命错误，stderr留给testlib的评测信息
+		"--",
+		"./"+exeFilename,
+	)
+	args = append(args, spec.Args...)
+	cmd := exec.Command("sudo", append([]string{nr.NsJailPath}, args...)...)
+	stderr := newLimitedBuffer()
+	cmd.Stderr = stderr
+
+	startTime := time.Now()
+	if err := startWithPipes(cmd, stdin, stdout); err != nil {
+		return failedParty("启动沙箱失败: %v", err)
+	}
+	err = cmd.Wait()
+	realTime := time.Since(startTime)
+	cpuTime, memUsed := processUsage(cmd)
+
+	r := partyResult{
+		Status:   model.StatusAC,
+		TimeUsed: cpuTime,
+		MemUsed:  uint64(memUsed),
+		Stderr:   stderr.String(),
+	}
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return failedParty("沙箱执行异常: %v", err)
+		}
+		r.ExitCode = exitErr.ExitCode()
+	}
+
+	// nsjail以程序的退出码退出，被信号终止时退出码为128+信号值
+	switch {
+	case realTime >= time.Duration(limits.WallTime)*time.Second:
+		r.Status = model.StatusTLE
+		r.Error = fmt.Sprintf("墙钟时间超限: > %ds", limits.WallTime)
+	case r.ExitCode > 128:
+		r.Signal = syscall.Signal(r.ExitCode - 128)
+		r.ExitCode = 0
+		r.Status, r.Error = nsjailSignalVerdict(r.Signal, cpuTime, limits)
+	}
+
+	zap.L().Debug("NsJail interactive party result",
+		zap.String("exe", exeFilename),
+		zap.Duration("cpu_time", cpuTime),
+		zap.Duration("real_time", realTime),
+		zap.Int64("memory_bytes", memUsed),
+		zap.Int("exit_code", r.ExitCode),
+		zap.Int("signal", int(r.Signal)),
+	)
+	return limits.enforce(r)
+}
+
+// nsjailSignalVerdict 判定被信号终止的交互一方的状态
+func nsjailSignalVerdict(signal syscall.Signal, cpuTime time.Duration, limits partyLimits) (model.JudgeStatus, string) {
+	switch {
+	case signal == syscall.SIGXCPU:
+		return model.StatusTLE, "CPU时间超限信号 (SIGXCPU)"
+	case signal == syscall.SIGKILL && cpuTime > time.Duration(limits.TimeLimit)*time.Second:
+		return model.StatusTLE, fmt.Sprintf("时间超限 (SIGKILL): %v > %vs", cpuTime, limits.TimeLimit)
+	case signal == syscall.SIGXFSZ:
+		return model.StatusOLE, "输出超限 (SIGXFSZ)"
+	}
+	return model.StatusRE, fmt.Sprintf("运行时错误 (signal: %v)", signal)
+}
+
+// RunCheckerInSandbox 在NsJail沙箱中运行特殊评测程序（checker）
+// 调用方式与testlib一致：checker input.txt user_output.txt answer.txt
+func (nr *NsJailRunner) RunCheckerInSandbox(runParams model.RunParams) *model.CheckerResult {
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return &model.CheckerResult{Error: fmt.Sprintf("创建沙箱工作目录失败: %v", err)}
+	}
+	defer cleanup()
+
+	// 复制checker到工作目录，测试数据与程序输出只读挂载到沙箱内的 /data
+	checkerFilename := filepath.Base(runParams.SpecialExePath)
+	if err := file_util.CopyFile(runParams.SpecialExePath, filepath.Join(workDir, checkerFilename)); err != nil {
+		return &model.CheckerResult{Error: fmt.Sprintf("复制%s到沙箱失败: %v", checkerFilename, err)}
+	}
+	files := checkerDataFiles(runParams)
+	bindArgs, err := nsjailDataArgs(files)
+	if err != nil {
+		return &model.CheckerResult{Error: err.Error()}
+	}
+
+	args := nsjailArgs(workDir, constants.CheckerTimeLimit, constants.CheckerTimeLimit*2, constants.CheckerMemoryLimit)
+	args = append(args, bindArgs...)
+	args = append(args,
+		"--really_quiet", // 仅输出致命错误，stderr留给testlib的评测信息
+		"--",
+		"./"+checkerFilename,
+		files[0].BoxPath(),
+		files[1].BoxPath(),
+		files[2].BoxPath(),
+	)
+	cmd := exec.Command("sudo", append([]string{nr.NsJailPath}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// nsjail以checker的退出码退出，被信号终止时退出码为128+信号值
+	err = cmd.Run()
+	exitCode := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return &model.CheckerResult{Error: fmt.Sprintf("沙箱执行异常: %v", err)}
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	checkerMsg := stderr.String()
+
+	zap.L().Debug("NsJail checker result",
+		zap.Int("test_case", runParams.TestCaseIndex),
+		zap.Int("exit_code", exitCode),
+		zap.String("checker_message", checkerMsg),
+	)
+
+	if exitCode > 128 {
+		return &model.CheckerResult{Error: fmt.Sprintf("checker收到信号 %d 终止", exitCode-128)}
+	}
+	return &model.CheckerResult{
+		ExitCode: exitCode,
+		Message:  checkerMsg,
+	}
+}
+
+func init() {
+	Register(DefaultNsJailSandboxConfig, func(sandboxPath string) Runner {
+		return &NsJailRunner{NsJailPath: sandboxPath}
+	})
+}
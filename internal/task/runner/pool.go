@@ -0,0 +1,471 @@
+package runner
+
+import (
+	"fmt"
+	"hitwh-judge/internal/constants"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultBoxWaitTimeout box ID耗尽时默认的最长等待时间
+const defaultBoxWaitTimeout = 30 * time.Second
+
+// leakedBoxCleanupWorkers 启动时并行清理遗留box的最大并发数
+const leakedBoxCleanupWorkers = 16
+
+// boxIDPool isolate box ID池，ID范围为 [base, base+size)
+// 全部ID被占用时分配方阻塞等待，超过等待时间返回错误
+type boxIDPool struct {
+	base    int // 第一个box ID
+	free    chan int
+	mu      sync.Mutex
+	inUse   []bool
+	timeout atomic.Int64 // 等待时间（纳秒）
+
+	waits     atomic.Int64 // 需要等待的分配次数
+	timeouts  atomic.Int64 // 等待超时次数
+	waitNanos atomic.Int64 // 累计等待时间（纳秒）
+}
+
+// newBoxIDPool 创建包含 [base, base+size) 共size个ID的box ID池
+func newBoxIDPool(base int, size int, timeout time.Duration) *boxIDPool {
+	p := &boxIDPool{
+		base:  base,
+		free:  make(chan int, size),
+		inUse: make([]bool, size),
+	}
+	for i := 0; i < size; i++ {
+		p.free <- base + i
+	}
+	p.timeout.Store(int64(timeout))
+	return p
+}
+
+// boxIDs 全局box ID池，SetBoxIDRange 可能在其他协程读取时替换，须通过Load读取
+var boxIDs atomic.Pointer[boxIDPool]
+
+func init() {
+	boxIDs.Store(newBoxIDPool(0, constants.BoxIDPoolSize, defaultBoxWaitTimeout))
+}
+
+// SetBoxIDRange 设置本进程使用的box ID范围 [start, start+count)，须在开始评测与启动预热池之前调用
+// 同一台机器上运行多个评测进程时，各进程应使用互不重叠的范围，启动时也只清理本进程范围内的box
+func SetBoxIDRange(start int, count int) {
+	if start < 0 || count <= 0 {
+		return
+	}
+	boxIDs.Store(newBoxIDPool(start, count, time.Duration(boxIDs.Load().timeout.Load())))
+}
+
+// allocateBoxID 分配一个空闲的box ID，全部占用时最多等待 SetBoxWaitTimeout 设置的时间
+func allocateBoxID() (int, error) {
+	return boxIDs.Load().allocate()
+}
+
+// releaseBoxID 释放指定的box ID，重复释放时忽略
+func releaseBoxID(id int) {
+	boxIDs.Load().release(id)
+}
+
+// SetBoxWaitTimeout 设置box ID耗尽时的最长等待时间
+func SetBoxWaitTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		boxIDs.Load().timeout.Store(int64(timeout))
+	}
+}
+
+func (p *boxIDPool) allocate() (int, error) {
+	id, _, err := p.allocateOrTake(nil)
+	return id, err
+}
+
+// allocateOrTake 分配一个空闲的box ID；ID耗尽时同时等待ready中归还的已初始化box，
+// 先得到哪个就返回哪个（得到box时ID为-1），超过等待时间返回错误
+func (p *boxIDPool) allocateOrTake(ready <-chan *sandboxBox) (int, *sandboxBox, error) {
+	select {
+	case id := <-p.free:
+		p.setInUse(id, true)
+		return id, nil, nil
+	default:
+	}
+
+	// 没有空闲ID，等待其他评测释放ID或归还box
+	p.waits.Add(1)
+	start := time.Now()
+	timeout := time.Duration(p.timeout.Load())
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case id := <-p.free:
+		p.waitNanos.Add(int64(time.Since(start)))
+		p.setInUse(id, true)
+		return id, nil, nil
+	case box := <-ready:
+		p.waitNanos.Add(int64(time.Since(start)))
+		return -1, box, nil
+	case <-timer.C:
+		p.waitNanos.Add(int64(time.Since(start)))
+		p.timeouts.Add(1)
+		zap.L().Warn("等待空闲沙箱超时", zap.Duration("timeout", timeout), zap.Int("box_ids", cap(p.free)))
+		return -1, nil, fmt.Errorf("等待沙箱超时: %v内没有空闲的box ID", timeout)
+	}
+}
+
+func (p *boxIDPool) release(id int) {
+	p.mu.Lock()
+	i := id - p.base
+	if i < 0 || i >= len(p.inUse) || !p.inUse[i] {
+		p.mu.Unlock()
+		return
+	}
+	p.inUse[i] = false
+	p.mu.Unlock()
+	p.free <- id
+}
+
+func (p *boxIDPool) setInUse(id int, inUse bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inUse[id-p.base] = inUse
+}
+
+// stats 返回box ID池的统计信息
+func (p *boxIDPool) stats() map[string]interface{} {
+	waits := p.waits.Load()
+	var avgWaitMs int64
+	if waits > 0 {
+		avgWaitMs = time.Duration(p.waitNanos.Load() / waits).Milliseconds()
+	}
+	return map[string]interface{}{
+		"box_id_start":       p.base,
+		"box_ids_total":      cap(p.free),
+		"box_ids_in_use":     cap(p.free) - len(p.free),
+		"box_id_waits":       waits,
+		"box_id_timeouts":    p.timeouts.Load(),
+		"box_id_avg_wait_ms": avgWaitMs,
+	}
+}
+
+// boxDriver 初始化与清理沙箱box的操作
+type boxDriver interface {
+	// Init 初始化指定ID的box，返回box工作目录
+	Init(id int) (string, error)
+	// Cleanup 清理指定ID的box
+	Cleanup(id int) error
+}
+
+// isolateDriver 通过isolate命令初始化与清理box
+type isolateDriver struct {
+	path string // isolate可执行文件路径
+}
+
+func (d isolateDriver) Init(id int) (string, error) {
+	initCmd := exec.Command(d.path, "--init", "--cg", fmt.Sprintf("--box-id=%d", id))
+	initOutput, err := initCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("初始化沙箱失败: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(string(initOutput)), "box"), nil
+}
+
+func (d isolateDriver) Cleanup(id int) error {
+	cleanupCmd := exec.Command(d.path, "--cleanup", "--cg", fmt.Sprintf("--box-id=%d", id))
+	if err := cleanupCmd.Run(); err != nil {
+		return fmt.Errorf("清理沙箱失败: %w", err)
+	}
+	return nil
+}
+
+// sandboxBox 已初始化、可以直接运行程序的box
+type sandboxBox struct {
+	id   int
+	path string // box工作目录
+}
+
+// newBox 分配box ID并初始化box，初始化失败时释放ID
+func newBox(driver boxDriver) (*sandboxBox, error) {
+	id, err := allocateBoxID()
+	if err != nil {
+		return nil, err
+	}
+	return initBox(driver, id)
+}
+
+// initBox 初始化已分配ID的box，初始化失败时释放ID
+func initBox(driver boxDriver, id int) (*sandboxBox, error) {
+	path, err := driver.Init(id)
+	if err != nil {
+		releaseBoxID(id)
+		return nil, err
+	}
+	return &sandboxBox{id: id, path: path}, nil
+}
+
+// destroyBox 清理box并释放box ID
+func destroyBox(driver boxDriver, box *sandboxBox) error {
+	err := driver.Cleanup(box.id)
+	releaseBoxID(box.id)
+	return err
+}
+
+// boxPool 预先初始化的box池
+// 取出的box用完后在后台清理并重新初始化，检查通过后放回池中；后台定期检查池中box的健康状况并补足数量
+type boxPool struct {
+	driver boxDriver
+	size   int
+	ready  chan *sandboxBox
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	hits      atomic.Int64 // 直接取到预热box的次数
+	misses    atomic.Int64 // 池为空而现场初始化box的次数
+	recycled  atomic.Int64 // 回收复用的box数
+	unhealthy atomic.Int64 // 健康检查不通过的box数
+}
+
+// newBoxPool 创建容量为size的box池，调用start后开始预热
+func newBoxPool(driver boxDriver, size int) *boxPool {
+	return &boxPool{
+		driver: driver,
+		size:   size,
+		ready:  make(chan *sandboxBox, size),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// start 在后台预热box，并按interval定期检查健康状况
+func (p *boxPool) start(interval time.Duration) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.fill()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.checkHealth()
+				p.fill()
+			}
+		}
+	}()
+}
+
+// stop 停止后台任务并清理池中的全部box
+func (p *boxPool) stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+	for {
+		select {
+		case box := <-p.ready:
+			if err := destroyBox(p.driver, box); err != nil {
+				zap.L().Warn("清理预热沙箱失败", zap.Int("box_id", box.id), zap.Error(err))
+			}
+		default:
+			return
+		}
+	}
+}
+
+// get 取出一个box，池为空时现场初始化
+// box ID耗尽时同时等待其他评测释放ID与回收后放回池中的box
+func (p *boxPool) get() (*sandboxBox, error) {
+	select {
+	case box := <-p.ready:
+		p.hits.Add(1)
+		return box, nil
+	default:
+	}
+	id, box, err := boxIDs.Load().allocateOrTake(p.ready)
+	if err != nil {
+		return nil, err
+	}
+	if box != nil {
+		p.hits.Add(1)
+		return box, nil
+	}
+	p.misses.Add(1)
+	return initBox(p.driver, id)
+}
+
+// put 归还box，在后台清理并重新初始化后放回池中
+func (p *boxPool) put(box *sandboxBox) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.recycle(box)
+	}()
+}
+
+// recycle 清理并重新初始化box，检查通过且池未满时放回池中，否则释放
+func (p *boxPool) recycle(box *sandboxBox) {
+	if err := p.driver.Cleanup(box.id); err != nil {
+		zap.L().Warn("回收沙箱时清理失败", zap.Int("box_id", box.id), zap.Error(err))
+	}
+	path, err := p.driver.Init(box.id)
+	if err != nil {
+		zap.L().Warn("回收沙箱时初始化失败", zap.Int("box_id", box.id), zap.Error(err))
+		releaseBoxID(box.id)
+		return
+	}
+	box.path = path
+	if err := checkBox(box); err != nil {
+		p.unhealthy.Add(1)
+		zap.L().Warn("回收的沙箱未通过健康检查", zap.Int("box_id", box.id), zap.Error(err))
+		if err := destroyBox(p.driver, box); err != nil {
+			zap.L().Warn("清理沙箱失败", zap.Int("box_id", box.id), zap.Error(err))
+		}
+		return
+	}
+	p.recycled.Add(1)
+	p.offer(box)
+}
+
+// offer 将box放回池中，池已满或已停止时清理并释放
+func (p *boxPool) offer(box *sandboxBox) {
+	select {
+	case <-p.stopCh:
+	default:
+		select {
+		case p.ready <- box:
+			return
+		default:
+		}
+	}
+	if err := destroyBox(p.driver, box); err != nil {
+		zap.L().Warn("清理沙箱失败", zap.Int("box_id", box.id), zap.Error(err))
+	}
+}
+
+// fill 初始化box直到池满
+func (p *boxPool) fill() {
+	for len(p.ready) < p.size {
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+		box, err := newBox(p.driver)
+		if err != nil {
+			zap.L().Warn("预热沙箱失败", zap.Error(err))
+			return
+		}
+		p.offer(box)
+	}
+}
+
+// checkHealth 检查池中的每个box，不健康的box在后台回收
+func (p *boxPool) checkHealth() {
+	for n := len(p.ready); n > 0; n-- {
+		var box *sandboxBox
+		select {
+		case box = <-p.ready:
+		default:
+			return
+		}
+		if err := checkBox(box); err != nil {
+			p.unhealthy.Add(1)
+			zap.L().Warn("预热沙箱未通过健康检查", zap.Int("box_id", box.id), zap.Error(err))
+			p.put(box)
+			continue
+		}
+		p.offer(box)
+	}
+}
+
+// checkBox 检查box是否可用：工作目录存在且为空
+func checkBox(box *sandboxBox) error {
+	entries, err := os.ReadDir(box.path)
+	if err != nil {
+		return fmt.Errorf("读取box目录失败: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("box目录非空: %d个文件", len(entries))
+	}
+	return nil
+}
+
+// stats 返回box池的统计信息
+func (p *boxPool) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"size":      p.size,
+		"ready":     len(p.ready),
+		"hits":      p.hits.Load(),
+		"misses":    p.misses.Load(),
+		"recycled":  p.recycled.Load(),
+		"unhealthy": p.unhealthy.Load(),
+	}
+}
+
+// cleanupLeakedBoxes 清理ID在 [start, start+size) 内的box，用于启动时清理本进程上次运行遗留（如进程崩溃未清理）的box
+// 范围之外的ID可能正被同一台机器上的其他评测进程使用，不能清理
+func cleanupLeakedBoxes(driver boxDriver, start int, size int) {
+	var g errgroup.Group
+	g.SetLimit(leakedBoxCleanupWorkers)
+	var failed atomic.Int64
+	for id := start; id < start+size; id++ {
+		g.Go(func() error {
+			if err := driver.Cleanup(id); err != nil {
+				failed.Add(1)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	zap.L().Info("已清理遗留的沙箱", zap.Int("box_id_start", start), zap.Int("box_ids", size), zap.Int64("failed", failed.Load()))
+}
+
+// isolatePools 按isolate路径索引的预热box池
+var (
+	isolatePoolsMu sync.RWMutex
+	isolatePools   = make(map[string]*boxPool)
+)
+
+// InitIsolatePool 清理本进程box ID范围内上次运行遗留的isolate box，并在后台预热size个box
+// 之后该路径的isolate运行器优先使用预热的box，用完后在后台回收；size为0时只清理不预热
+func InitIsolatePool(isolatePath string, size int, healthCheckInterval time.Duration) {
+	driver := isolateDriver{path: isolatePath}
+	ids := boxIDs.Load()
+	cleanupLeakedBoxes(driver, ids.base, cap(ids.free))
+	if size <= 0 {
+		return
+	}
+	pool := newBoxPool(driver, size)
+	isolatePoolsMu.Lock()
+	if old, ok := isolatePools[isolatePath]; ok {
+		defer old.stop()
+	}
+	isolatePools[isolatePath] = pool
+	isolatePoolsMu.Unlock()
+	pool.start(healthCheckInterval)
+}
+
+// getIsolatePool 返回isolate路径对应的预热box池，未启用时返回nil
+func getIsolatePool(isolatePath string) *boxPool {
+	isolatePoolsMu.RLock()
+	defer isolatePoolsMu.RUnlock()
+	return isolatePools[isolatePath]
+}
+
+// GetSandboxPoolStats 返回box ID池与各预热box池的统计信息
+func GetSandboxPoolStats() map[string]interface{} {
+	stats := boxIDs.Load().stats()
+	isolatePoolsMu.RLock()
+	defer isolatePoolsMu.RUnlock()
+	pools := make(map[string]interface{}, len(isolatePools))
+	for path, pool := range isolatePools {
+		pools[path] = pool.stats()
+	}
+	stats["isolate_pools"] = pools
+	return stats
+}
@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBoxIDPool(t *testing.T) {
+	p := newBoxIDPool(10, 2, 50*time.Millisecond)
+	a, err := p.allocate()
+	if err != nil {
+		t.Fatalf("allocate() error = %v", err)
+	}
+	b, err := p.allocate()
+	if err != nil || a == b {
+		t.Fatalf("allocate() = %d, %v, want id different from %d", b, err, a)
+	}
+
+	// ID耗尽时等待超时
+	if id, err := p.allocate(); err == nil {
+		t.Fatalf("allocate() on exhausted pool = %d, want error", id)
+	}
+
+	// 等待期间释放的ID可以被取得
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.release(a)
+	}()
+	if id, err := p.allocate(); err != nil || id != a {
+		t.Errorf("allocate() after release = %d, %v, want %d", id, err, a)
+	}
+
+	// 重复释放与越界释放被忽略
+	if a < 10 || a > 11 || b < 10 || b > 11 {
+		t.Errorf("allocate() = %d, %d, want ids in [10, 12)", a, b)
+	}
+	p.release(b)
+	p.release(b)
+	p.release(-1)
+	p.release(0)
+	stats := p.stats()
+	if stats["box_ids_in_use"] != 1 || stats["box_id_waits"] != int64(2) || stats["box_id_timeouts"] != int64(1) {
+		t.Errorf("stats() = %v", stats)
+	}
+}
+
+// fakeBoxDriver 用临时目录模拟isolate box
+type fakeBoxDriver struct {
+	root     string
+	mu       sync.Mutex
+	inits    int
+	cleanups int
+}
+
+func (d *fakeBoxDriver) Init(id int) (string, error) {
+	d.mu.Lock()
+	d.inits++
+	d.mu.Unlock()
+	path := filepath.Join(d.root, strconv.Itoa(id), "box")
+	if err := os.RemoveAll(path); err != nil {
+		return "", err
+	}
+	return path, os.MkdirAll(path, 0755)
+}
+
+func (d *fakeBoxDriver) Cleanup(id int) error {
+	d.mu.Lock()
+	d.cleanups++
+	d.mu.Unlock()
+	return os.RemoveAll(filepath.Join(d.root, strconv.Itoa(id)))
+}
+
+// waitFor 等待cond成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBoxPool(t *testing.T) {
+	driver := &fakeBoxDriver{root: t.TempDir()}
+	pool := newBoxPool(driver, 2)
+	pool.start(20 * time.Millisecond)
+	waitFor(t, "预热", func() bool { return len(pool.ready) == 2 })
+
+	box, err := pool.get()
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	if err := checkBox(box); err != nil {
+		t.Errorf("预热的box不可用: %v", err)
+	}
+
+	// 用过的box回收后重新初始化为空目录
+	if err := os.WriteFile(filepath.Join(box.path, "output.txt"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool.put(box)
+	waitFor(t, "回收", func() bool { return pool.recycled.Load() == 1 })
+
+	// 池中不健康的box在健康检查时被回收
+	waitFor(t, "补足", func() bool { return len(pool.ready) == 2 })
+	bad := <-pool.ready
+	if err := os.WriteFile(filepath.Join(bad.path, "leftover"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool.offer(bad)
+	waitFor(t, "健康检查", func() bool { return pool.unhealthy.Load() >= 1 })
+	waitFor(t, "重新补足", func() bool { return len(pool.ready) == 2 })
+
+	stats := pool.stats()
+	if stats["hits"] != int64(1) || stats["misses"] != int64(0) {
+		t.Errorf("stats() = %v", stats)
+	}
+
+	pool.stop()
+	entries, err := os.ReadDir(driver.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("stop() 后仍有%d个box未清理", len(entries))
+	}
+	if in := boxIDs.Load().stats()["box_ids_in_use"]; in != 0 {
+		t.Errorf("stop() 后仍占用%v个box ID", in)
+	}
+}
+
+func TestBoxPoolGetWhenEmpty(t *testing.T) {
+	driver := &fakeBoxDriver{root: t.TempDir()}
+	pool := newBoxPool(driver, 1)
+	// 未预热时现场初始化
+	box, err := pool.get()
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	if pool.misses.Load() != 1 {
+		t.Errorf("misses = %d, want 1", pool.misses.Load())
+	}
+	if err := destroyBox(driver, box); err != nil {
+		t.Errorf("destroyBox() error = %v", err)
+	}
+}
+
+func TestBoxPoolGetWaitsForRecycledBox(t *testing.T) {
+	defer func(ids *boxIDPool) { boxIDs.Store(ids) }(boxIDs.Load())
+	boxIDs.Store(newBoxIDPool(100, 1, 2*time.Second))
+
+	driver := &fakeBoxDriver{root: t.TempDir()}
+	pool := newBoxPool(driver, 1)
+	defer pool.stop()
+	box, err := pool.get()
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+
+	// 唯一的ID被占用，等待中的调用方应被回收后放回池中的box唤醒，而不是等到超时
+	got := make(chan *sandboxBox, 1)
+	go func() {
+		b, err := pool.get()
+		if err != nil {
+			t.Errorf("blocked get() error = %v", err)
+		}
+		got <- b
+	}()
+	time.Sleep(20 * time.Millisecond)
+	pool.put(box)
+
+	select {
+	case b := <-got:
+		if b == nil || b.id != 100 {
+			t.Fatalf("blocked get() = %+v, want recycled box 100", b)
+		}
+		if err := destroyBox(driver, b); err != nil {
+			t.Errorf("destroyBox() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked get() was not woken by the recycled box")
+	}
+}
+
+func TestCleanupLeakedBoxes(t *testing.T) {
+	driver := &fakeBoxDriver{root: t.TempDir()}
+	for _, id := range []int{3, 5, 9, 10} {
+		if _, err := driver.Init(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 只清理本进程的ID范围 [5, 10)，其他进程的box保持不变
+	cleanupLeakedBoxes(driver, 5, 5)
+	for id, wantExist := range map[int]bool{3: true, 5: false, 9: false, 10: true} {
+		_, err := os.Stat(filepath.Join(driver.root, strconv.Itoa(id)))
+		if exist := err == nil; exist != wantExist {
+			t.Errorf("box %d exists = %v, want %v", id, exist, wantExist)
+		}
+	}
+	if driver.cleanups != 5 {
+		t.Errorf("cleanups = %d, want 5", driver.cleanups)
+	}
+}
@@ -1,7 +1,9 @@
 package runner
 
 import (
+	"fmt"
 	"hitwh-judge/internal/model"
+	"sync"
 )
 
 type SandboxType int
@@ -15,8 +17,11 @@ const (
 // Runner 沙箱运行器接口
 type Runner interface {
 	InitSandbox() (string, error)
+	// Open 为一次提交打开沙箱会话，exePath只放入沙箱一次，之后通过会话运行全部测试点
+	Open(exePath string) (Session, error)
 	RunInSandbox(runParams model.RunParams) *model.TestCaseResult
 	RunInteractiveInSandbox(runParams model.RunParams) *model.TestCaseResult
+	RunCheckerInSandbox(runParams model.RunParams) *model.CheckerResult
 }
 
 // RunResult 异步运行结果
@@ -27,9 +32,56 @@ type RunResult struct {
 	Err       error
 }
 
+// Factory 沙箱运行器构造函数，sandboxPath 为沙箱可执行文件路径
+type Factory func(sandboxPath string) Runner
+
+// registration 已注册的沙箱运行器
+type registration struct {
+	config  model.SandboxConfig
+	factory Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register 注册沙箱运行器，名称为 config.Type，重复注册时覆盖
+func Register(config model.SandboxConfig, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[config.Type] = registration{config: config, factory: factory}
+}
+
+// IsRegistered 判断沙箱运行器是否已注册
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// New 按名称创建沙箱运行器，sandboxPath 为空时使用该沙箱的默认路径
+func New(name string, sandboxPath string) (Runner, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的沙箱类型: %s", name)
+	}
+	if sandboxPath == "" {
+		sandboxPath = reg.config.Path
+	}
+	return reg.factory(sandboxPath), nil
+}
+
 // NewRunner 创建沙箱运行器实例
 func NewRunner(sandboxType SandboxType, sandboxPath string) Runner {
 	switch sandboxType {
+	case NsJail:
+		return &NsJailRunner{NsJailPath: sandboxPath}
+	case SDUSandbox:
+		return &SDUSandboxRunner{SandboxPath: sandboxPath}
 	case Isolate:
 		return &IsoRunner{
 			IsolatePath: sandboxPath,
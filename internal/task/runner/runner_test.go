@@ -1 +1,375 @@
 package runner
+
+import (
+	"hitwh-judge/internal/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		sandboxPath string
+		wantPath    string
+		wantErr     bool
+	}{
+		{name: "isolate", wantPath: DefaultIsolateSandboxConfig.Path},
+		{name: "nsjail", sandboxPath: "/usr/local/bin/nsjail", wantPath: "/usr/local/bin/nsjail"},
+		{name: "sdu_sandbox", wantPath: DefaultSDUSandboxConfig.Path},
+		{name: "docker", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.name, tt.sandboxPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var gotPath string
+			switch r := r.(type) {
+			case *IsoRunner:
+				gotPath = r.IsolatePath
+			case *NsJailRunner:
+				gotPath = r.NsJailPath
+			case *SDUSandboxRunner:
+				gotPath = r.SandboxPath
+			default:
+				t.Fatalf("New(%q) returned unexpected runner %T", tt.name, r)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("New(%q) path = %q, want %q", tt.name, gotPath, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestInteractiveVerdict(t *testing.T) {
+	exited := func(code int) partyResult {
+		return partyResult{Status: model.StatusAC, ExitCode: code}
+	}
+	killed := func(sig syscall.Signal) partyResult {
+		return partyResult{Status: model.StatusRE, Signal: sig, Error: "被信号终止"}
+	}
+	tests := []struct {
+		name       string
+		interactor partyResult
+		solution   partyResult
+		wantStatus model.JudgeStatus
+		wantRatio  float64
+	}{
+		{
+			name:       "正确",
+			interactor: exited(0),
+			solution:   exited(0),
+			wantStatus: model.StatusAC,
+			wantRatio:  1,
+		},
+		{
+			name:       "交互程序判定错误",
+			interactor: partyResult{Status: model.StatusAC, ExitCode: 1, Stderr: "wrong answer"},
+			solution:   exited(0),
+			wantStatus: model.StatusWA,
+		},
+		{
+			name:       "部分得分",
+			interactor: exited(100),
+			solution:   exited(0),
+			wantStatus: model.StatusPC,
+			wantRatio:  0.5,
+		},
+		{
+			name:       "选手程序非0退出",
+			interactor: exited(1),
+			solution:   exited(139),
+			wantStatus: model.StatusRE,
+		},
+		{
+			name:       "选手程序被信号终止",
+			interactor: exited(0),
+			solution:   killed(syscall.SIGSEGV),
+			wantStatus: model.StatusRE,
+		},
+		{
+			name:       "选手程序超时",
+			interactor: exited(1),
+			solution:   partyResult{Status: model.StatusTLE},
+			wantStatus: model.StatusTLE,
+		},
+		{
+			name:       "交互程序先退出导致选手程序SIGPIPE",
+			interactor: exited(1),
+			solution:   killed(syscall.SIGPIPE),
+			wantStatus: model.StatusWA,
+		},
+		{
+			name:       "选手程序提前结束导致交互程序SIGPIPE",
+			interactor: killed(syscall.SIGPIPE),
+			solution:   exited(0),
+			wantStatus: model.StatusWA,
+		},
+		{
+			name:       "交互程序崩溃",
+			interactor: killed(syscall.SIGSEGV),
+			solution:   partyResult{Status: model.StatusTLE},
+			wantStatus: model.StatusSE,
+		},
+		{
+			name:       "交互程序超时",
+			interactor: partyResult{Status: model.StatusTLE},
+			solution:   exited(0),
+			wantStatus: model.StatusSE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, ratio, _ := interactiveVerdict(tt.interactor, tt.solution)
+			if status != tt.wantStatus {
+				t.Errorf("interactiveVerdict() status = %q, want %q", status, tt.wantStatus)
+			}
+			if ratio != tt.wantRatio {
+				t.Errorf("interactiveVerdict() ratio = %v, want %v", ratio, tt.wantRatio)
+			}
+		})
+	}
+}
+
+// shellParty 不使用沙箱直接以/bin/sh运行交互的一方
+func shellParty(script string) party {
+	return func(stdin, stdout *os.File) partyResult {
+		cmd := exec.Command("/bin/sh", "-c", script)
+		if err := startWithPipes(cmd, stdin, stdout); err != nil {
+			return failedParty("%v", err)
+		}
+		r := partyResult{Status: model.StatusAC}
+		if err := cmd.Wait(); err != nil {
+			ws := err.(*exec.ExitError).Sys().(syscall.WaitStatus)
+			if ws.Signaled() {
+				r.Status = model.StatusRE
+				r.Signal = ws.Signal()
+			} else {
+				r.ExitCode = ws.ExitStatus()
+			}
+		}
+		return r
+	}
+}
+
+func TestInteract(t *testing.T) {
+	tests := []struct {
+		name       string
+		interactor string
+		solution   string
+		wantStatus model.JudgeStatus
+	}{
+		{
+			name:       "正确",
+			interactor: `echo 3; read x; [ "$x" = 6 ]`,
+			solution:   `read n; echo $((n * 2))`,
+			wantStatus: model.StatusAC,
+		},
+		{
+			name:       "答案错误",
+			interactor: `echo 3; read x; [ "$x" = 6 ]`,
+			solution:   `read n; echo $((n + 1))`,
+			wantStatus: model.StatusWA,
+		},
+		{
+			name:       "交互程序提前退出",
+			interactor: `exit 1`,
+			solution:   `read n; echo x`,
+			wantStatus: model.StatusWA,
+		},
+		{
+			name:       "选手程序异常退出",
+			interactor: `echo 3; read x; [ "$x" = 6 ]`,
+			solution:   `exit 3`,
+			wantStatus: model.StatusRE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor, solution, err := interact(shellParty(tt.interactor), shellParty(tt.solution))
+			if err != nil {
+				t.Fatalf("interact() error = %v", err)
+			}
+			status, errorMsg, _, _ := interactiveVerdict(interactor, solution)
+			if status != tt.wantStatus {
+				t.Errorf("interactiveVerdict() status = %q (%s), want %q; interactor = %+v, solution = %+v",
+					status, errorMsg, tt.wantStatus, interactor, solution)
+			}
+		})
+	}
+}
+
+func TestReadOutput(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name         string
+		content      string
+		limit        int64
+		wantOutput   string
+		wantExceeded bool
+	}{
+		{name: "未超限", content: "1 2 3\r\n", limit: 16, wantOutput: "1 2 3"},
+		{name: "恰好达到限制", content: "12345678", limit: 8, wantOutput: "12345678"},
+		{name: "超限截断", content: strings.Repeat("a", 100), limit: 8, wantOutput: "aaaaaaaa", wantExceeded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "output.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			output, exceeded, err := readOutput(path, tt.limit)
+			if err != nil {
+				t.Fatalf("readOutput() error = %v", err)
+			}
+			if output != tt.wantOutput || exceeded != tt.wantExceeded {
+				t.Errorf("readOutput() = (%q, %v), want (%q, %v)", output, exceeded, tt.wantOutput, tt.wantExceeded)
+			}
+		})
+	}
+
+	t.Run("文件不存在", func(t *testing.T) {
+		output, exceeded, err := readOutput(filepath.Join(dir, "missing.txt"), 8)
+		if err != nil || output != "" || exceeded {
+			t.Errorf("readOutput() = (%q, %v, %v), want empty output", output, exceeded, err)
+		}
+	})
+}
+
+func TestCollectOutput(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(src, []byte(strings.Repeat("x", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		limit        int64
+		wantSaved    int
+		wantExceeded bool
+	}{
+		{name: "未超限", limit: 100, wantSaved: 100},
+		{name: "超限截断", limit: 10, wantSaved: 10, wantExceeded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(dir, "user_output.txt")
+			output, exceeded, err := collectOutput(src, model.RunParams{OutputLimit: tt.limit, OutputFile: dst})
+			if err != nil {
+				t.Fatalf("collectOutput() error = %v", err)
+			}
+			if exceeded != tt.wantExceeded {
+				t.Errorf("collectOutput() exceeded = %v, want %v", exceeded, tt.wantExceeded)
+			}
+			saved, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(saved) != tt.wantSaved || output != string(saved) {
+				t.Errorf("collectOutput() saved %d bytes, output %q", len(saved), output)
+			}
+		})
+	}
+}
+
+func TestStageDataFiles(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "case.in")
+	if err := os.WriteFile(src, []byte("1 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []dataFile{{Source: src, Name: "input.txt"}}
+	dir, cleanup, err := stageDataFiles(files)
+	if err != nil {
+		t.Fatalf("stageDataFiles() error = %v", err)
+	}
+	staged := filepath.Join(dir, "input.txt")
+	if data, err := os.ReadFile(staged); err != nil || string(data) != "1 2\n" {
+		t.Errorf("staged file = %q, %v", data, err)
+	}
+	if got := files[0].BoxPath(); got != "/data/input.txt" {
+		t.Errorf("BoxPath() = %s, want /data/input.txt", got)
+	}
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cleanup() 后目录仍存在: %v", err)
+	}
+	// 清理暂存目录不影响源文件
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("源文件被删除: %v", err)
+	}
+
+	if _, _, err := stageDataFiles([]dataFile{{Source: src + ".missing", Name: "input.txt"}}); err == nil {
+		t.Error("stageDataFiles() with missing source error = nil, want error")
+	}
+}
+
+func TestResetBox(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main", "normal_judge.sh", "output.txt", "meta.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tmp", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := resetBox(dir, "main", "normal_judge.sh"); err != nil {
+		t.Fatalf("resetBox() error = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := strings.Join(names, ","); got != "main,normal_judge.sh" {
+		t.Errorf("resetBox() 后剩余文件 = %s, want main,normal_judge.sh", got)
+	}
+}
+
+// recordingRunner 记录RunInSandbox调用参数的运行器
+type recordingRunner struct {
+	NsJailRunner
+	exePaths []string
+}
+
+func (r *recordingRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResult {
+	r.exePaths = append(r.exePaths, runParams.ExePath)
+	return &model.TestCaseResult{TestCaseIndex: runParams.TestCaseIndex, Status: model.StatusAC}
+}
+
+func TestPerRunSession(t *testing.T) {
+	r := &recordingRunner{}
+	session := &perRunSession{runner: r, exePath: "/tmp/main"}
+	for i := 0; i < 3; i++ {
+		// 会话始终运行打开时指定的程序
+		result := session.Run(model.RunParams{TestCaseIndex: i, ExePath: "/tmp/other"})
+		if result.TestCaseIndex != i || result.Status != model.StatusAC {
+			t.Errorf("Run(%d) = %+v", i, result)
+		}
+	}
+	if err := session.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if got := strings.Join(r.exePaths, ","); got != "/tmp/main,/tmp/main,/tmp/main" {
+		t.Errorf("RunInSandbox exe paths = %s", got)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"hitwh-judge/internal/constants"
 	"hitwh-judge/internal/model"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
@@ -53,6 +57,11 @@ var resultMapping = map[int]string{
 	6: "OLE", // Output Limit Exceeded
 }
 
+// Open 打开沙箱会话，SDU sandbox直接运行宿主机上的程序，每个测试点单独启动沙箱
+func (csr *SDUSandboxRunner) Open(exePath string) (Session, error) {
+	return &perRunSession{runner: csr, exePath: exePath}, nil
+}
+
 // RunInSandbox 在自定义沙箱中运行程序
 func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.TestCaseResult {
 	// 提取参数
@@ -60,6 +69,7 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 	input := runParams.Input
 	timeLimit := runParams.TimeLimit
 	memoryLimit := runParams.MemLimit
+	maxOutput := outputLimit(runParams)
 
 	// 检查sandbox是否存在
 	if _, err := exec.LookPath(csr.SandboxPath); err != nil {
@@ -73,29 +83,19 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 		}
 	}
 
-	// 创建临时文件用于输入和输出
-	// tempDir, err := ioutil.TempDir("", "sandbox_*")
-	// if err != nil {
-	// 	return "", "", "", fmt.Errorf("创建临时目录失败: %w", err)
-	// }
-	// defer os.RemoveAll(tempDir)
-	tempDir := filepath.Join(os.Getenv("HOME"), "tmp/sandbox_*")
-	// 创建临时目录
-	if err := os.MkdirAll(tempDir, 0700); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("创建临时目录失败: %v", err),
-		}
+	// 创建临时目录用于输入和输出
+	tempDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
-	defer os.RemoveAll(tempDir)
-	zap.L().Info("Sandbox temp dir", zap.String("tempDir", tempDir))
+	defer cleanup()
 
-	inputPath := filepath.Join(tempDir, "input.txt")
 	outputPath := filepath.Join(tempDir, "output.txt")
 
-	// 写入输入数据
-	if input != "" {
+	// 优先使用输入文件，未提供时写入输入数据
+	inputPath := runParams.InputFile
+	if inputPath == "" {
+		inputPath = filepath.Join(tempDir, "input.txt")
 		if err := ioutil.WriteFile(inputPath, []byte(normalizeString(input)), 0644); err != nil {
 			return &model.TestCaseResult{
 				TestCaseIndex: runParams.TestCaseIndex,
@@ -103,15 +103,8 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 				Error:         fmt.Sprintf("写入输入文件失败: %v", err),
 			}
 		}
-	} else {
-		// 创建空输入文件
-		if err := ioutil.WriteFile(inputPath, []byte(""), 0644); err != nil {
-			return &model.TestCaseResult{
-				TestCaseIndex: runParams.TestCaseIndex,
-				Status:        model.StatusSE,
-				Error:         fmt.Sprintf("创建输入文件失败: %v", err),
-			}
-		}
+	} else if inputPath, err = filepath.Abs(inputPath); err != nil {
+		return systemError(runParams.TestCaseIndex, "获取输入文件绝对路径失败: %v", err)
 	}
 
 	// 获取可执行文件的绝对路径
@@ -134,6 +127,7 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 		"--seccomp_rules=general",
 		fmt.Sprintf("--max_memory=%d", memoryLimit*1024*1024),  // 转换为字节
 		fmt.Sprintf("--max_real_time=%d", int(timeLimit)*1200), // 转换为毫秒
+		fmt.Sprintf("--max_output_size=%d", maxOutput+1),       // 略大于输出限制以便判定超限
 	)
 
 	// 捕获标准输出和错误输出
@@ -149,44 +143,16 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 			Error:         fmt.Sprintf("沙箱运行失败: %v, 错误输出: %s", err, stderr.String()),
 		}
 	}
-	outputBytes := normalizeString(stdout.String())
-
-	// 解析沙箱返回的JSON结果
-	var result SandboxResult
-	jsonStr := string(outputBytes)
-	zap.L().Info("Sandbox command output", zap.String("output", jsonStr))
-
-	// 从输出中提取JSON部分
-	lines := strings.Split(jsonStr, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "{") && strings.HasSuffix(line, "}") {
-			jsonStr = line
-			break
-		}
-	}
-
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		return &model.TestCaseResult{
-			TestCaseIndex: runParams.TestCaseIndex,
-			Status:        model.StatusSE,
-			Error:         fmt.Sprintf("解析沙箱结果失败: %v, 输出: %s", err, jsonStr),
-		}
+	result, err := parseSandboxResult(stdout.String())
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
 	zap.L().Info("Sandbox result", zap.Any("result", result))
 
 	// 读取输出文件内容
-	var output string
-	if _, err := os.Stat(outputPath); err == nil {
-		outputBytes, err := ioutil.ReadFile(outputPath)
-		if err != nil {
-			return &model.TestCaseResult{
-				TestCaseIndex: runParams.TestCaseIndex,
-				Status:        model.StatusSE,
-				Error:         fmt.Sprintf("读取输出文件失败: %v", err),
-			}
-		}
-		output = normalizeString(string(outputBytes))
+	output, outputExceeded, err := collectOutput(outputPath, runParams)
+	if err != nil {
+		return systemError(runParams.TestCaseIndex, "%v", err)
 	}
 
 	// 获取错误输出
@@ -222,6 +188,10 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 			status = model.StatusTLE
 		}
 	}
+	if outputExceeded {
+		status = model.StatusOLE
+		errOutput = outputLimitMessage(maxOutput)
+	}
 	testCaseResult := &model.TestCaseResult{
 		TestCaseIndex: runParams.TestCaseIndex,
 		Status:        model.JudgeStatus(status),
@@ -232,3 +202,292 @@ func (csr *SDUSandboxRunner) RunInSandbox(runParams model.RunParams) *model.Test
 	}
 	return testCaseResult
 }
+
+// parseSandboxResult 从沙箱标准输出中解析JSON运行结果
+func parseSandboxResult(stdout string) (SandboxResult, error) {
+	var result SandboxResult
+	jsonStr := normalizeString(stdout)
+	zap.L().Info("Sandbox command output", zap.String("output", jsonStr))
+
+	// 从输出中提取JSON部分
+	for _, line := range strings.Split(jsonStr, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "{") && strings.HasSuffix(line, "}") {
+			jsonStr = line
+			break
+		}
+	}
+
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return result, fmt.Errorf("解析沙箱结果失败: %v, 输出: %s", err, jsonStr)
+	}
+	return result, nil
+}
+
+// InitSandbox 创建沙箱工作目录，由调用方负责删除
+func (csr *SDUSandboxRunner) InitSandbox() (string, error) {
+	workDir, _, err := createTmpDir()
+	return workDir, err
+}
+
+// runSandbox 以root权限运行沙箱并解析其运行结果
+func (csr *SDUSandboxRunner) runSandbox(args []string) (SandboxResult, error) {
+	cmd := exec.Command("sudo", append([]string{csr.SandboxPath}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return SandboxResult{}, fmt.Errorf("沙箱运行失败: %v, 错误输出: %s", err, stderr.String())
+	}
+	return parseSandboxResult(stdout.String())
+}
+
+// RunInteractiveInSandbox 在沙箱中运行交互题
+// 交互程序与选手程序分别运行在两个沙箱中，经命名管道转发相连，各自独立限制并统计资源。
+// 交互程序由出题人提供，不启用seccomp规则；选手程序使用general规则。
+func (csr *SDUSandboxRunner) RunInteractiveInSandbox(runParams model.RunParams) *model.TestCaseResult {
+	return runInteraction(runParams, csr.runParty)
+}
+
+// runParty 在独立的工作目录中运行交互的一方
+func (csr *SDUSandboxRunner) runParty(spec partySpec, stdin, stdout *os.File) partyResult {
+	// 提前返回时同样需要关闭管道，重复关闭没有影响
+	defer stdin.Close()
+	defer stdout.Close()
+
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return failedParty("创建沙箱工作目录失败: %v", err)
+	}
+	defer cleanup()
+
+	absExePath, err := filepath.Abs(spec.ExePath)
+	if err != nil {
+		return failedParty("获取可执行文件绝对路径失败: %v", err)
+	}
+	args, err := sduPartyArgs(spec, workDir)
+	if err != nil {
+		return failedParty("%v", err)
+	}
+	relay, err := newFifoRelay(workDir, stdin, stdout)
+	if err != nil {
+		return failedParty("%v", err)
+	}
+	errorPath := filepath.Join(workDir, "error.txt")
+
+	limits := spec.Limits
+	sandboxArgs := []string{
+		"--exe_path=" + absExePath,
+		"--input_path=" + relay.inPath,
+		"--output_path=" + relay.outPath,
+		"--error_path=" + errorPath,
+		fmt.Sprintf("--max_cpu_time=%d", limits.TimeLimit*1000),
+		fmt.Sprintf("--max_real_time=%d", limits.WallTime*1000),
+		fmt.Sprintf("--max_memory=%d", limits.MemLimit*1024*1024),
+	}
+	for _, arg := range args {
+		sandboxArgs = append(sandboxArgs, "--args="+arg)
+	}
+	if !spec.Trusted {
+		sandboxArgs = append(sandboxArgs, "--seccomp_rules=general")
+	}
+	result, err := csr.runSandbox(sandboxArgs)
+	relay.close()
+	if err != nil {
+		return failedParty("%v", err)
+	}
+
+	zap.L().Debug("Sandbox interactive party result",
+		zap.String("exe", absExePath),
+		zap.Any("result", result),
+	)
+
+	r := partyResult{
+		Status:   model.StatusAC,
+		ExitCode: result.ExitCode,
+		Signal:   syscall.Signal(result.Signal),
+		TimeUsed: time.Duration(result.CpuTime) * time.Millisecond,
+		MemUsed:  uint64(result.Memory),
+	}
+	r.Stderr, _, _ = readOutput(errorPath, constants.MaxErrorSize)
+	// 退出码非0时沙箱返回RE，此时以退出码为准，由调用方解释
+	switch status := resultMapping[result.Result]; {
+	case status == "":
+		r.Status = model.StatusSE
+		r.Error = fmt.Sprintf("沙箱返回未知结果: %d", result.Result)
+	case status == model.StatusSE:
+		r.Status = model.StatusSE
+		r.Error = fmt.Sprintf("沙箱内部错误: %d", result.Error)
+	case status == model.StatusRE && result.Signal != 0:
+		r.Status = model.StatusRE
+		r.Error = fmt.Sprintf("运行时错误 (signal: %v)", r.Signal)
+	case status != model.StatusRE:
+		r.Status = status
+		r.Error = fmt.Sprintf("沙箱判定 %s", status)
+	}
+	return limits.enforce(r)
+}
+
+// sduPartyArgs 将程序参数中沙箱内的路径替换为宿主机路径：SDU sandbox不挂载 /data，程序直接访问宿主机文件
+func sduPartyArgs(spec partySpec, workDir string) ([]string, error) {
+	args := make([]string, 0, len(spec.Args))
+	for _, arg := range spec.Args {
+		if arg == interactorOutputFile {
+			arg = filepath.Join(workDir, interactorOutputFile)
+		}
+		for _, f := range spec.DataFiles {
+			if arg != f.BoxPath() {
+				continue
+			}
+			absPath, err := filepath.Abs(f.Source)
+			if err != nil {
+				return nil, fmt.Errorf("获取%s绝对路径失败: %w", f.Name, err)
+			}
+			arg = absPath
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// fifoRelay 经命名管道在沙箱与交互管道之间转发数据
+// SDU sandbox只能以文件路径指定标准输入输出，且sudo会关闭额外的文件描述符，无法直接传入管道，
+// 因此在工作目录中创建两个命名管道，由评测进程在命名管道与交互管道之间双向复制
+type fifoRelay struct {
+	inPath  string // 沙箱的标准输入
+	outPath string // 沙箱的标准输出
+	stdin   *os.File
+	ends    []fifoEnd
+	wg      sync.WaitGroup
+}
+
+// fifoEnd 评测进程打开的一个命名管道端，opened在打开返回后关闭
+type fifoEnd struct {
+	path   string
+	opened chan struct{}
+}
+
+// newFifoRelay 在dir中创建命名管道并开始转发：stdin的数据写入沙箱的标准输入，沙箱的标准输出写入stdout
+func newFifoRelay(dir string, stdin, stdout *os.File) (*fifoRelay, error) {
+	r := &fifoRelay{
+		inPath:  filepath.Join(dir, "stdin.fifo"),
+		outPath: filepath.Join(dir, "stdout.fifo"),
+		stdin:   stdin,
+	}
+	for _, path := range []string{r.inPath, r.outPath} {
+		if err := syscall.Mkfifo(path, 0666); err != nil {
+			return nil, fmt.Errorf("创建命名管道失败: %w", err)
+		}
+		// 不受umask影响，保证沙箱内的用户可以读写
+		if err := os.Chmod(path, 0666); err != nil {
+			return nil, fmt.Errorf("修改命名管道权限失败: %w", err)
+		}
+	}
+	r.pump(r.inPath, os.O_WRONLY, func(fifo *os.File) {
+		_, _ = io.Copy(fifo, stdin)
+	})
+	r.pump(r.outPath, os.O_RDONLY, func(fifo *os.File) {
+		_, _ = io.Copy(stdout, fifo)
+		// 沙箱的输出结束后立即关闭，另一方随之读到EOF
+		stdout.Close()
+	})
+	return r, nil
+}
+
+// pump 在后台打开命名管道（阻塞至沙箱打开另一端）并执行复制
+func (r *fifoRelay) pump(path string, flag int, copyFn func(fifo *os.File)) {
+	end := fifoEnd{path: path, opened: make(chan struct{})}
+	r.ends = append(r.ends, end)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		fifo, err := os.OpenFile(path, flag, 0)
+		close(end.opened)
+		if err != nil {
+			zap.L().Warn("打开命名管道失败", zap.String("path", path), zap.Error(err))
+			return
+		}
+		defer fifo.Close()
+		copyFn(fifo)
+	}()
+}
+
+// close 在沙箱结束后调用，结束转发并等待剩余的输出复制完成
+// 沙箱可能没有打开命名管道就退出了，此时以读写方式打开命名管道，使仍阻塞在打开上的一端返回
+func (r *fifoRelay) close() {
+	for _, end := range r.ends {
+		if f, err := os.OpenFile(end.path, os.O_RDWR, 0); err == nil {
+			<-end.opened
+			f.Close()
+		} else {
+			zap.L().Warn("打开命名管道失败", zap.String("path", end.path), zap.Error(err))
+		}
+	}
+	r.stdin.Close()
+	r.wg.Wait()
+}
+
+// RunCheckerInSandbox 在沙箱中运行特殊评测程序（checker）
+// 调用方式与testlib一致：checker input.txt user_output.txt answer.txt
+func (csr *SDUSandboxRunner) RunCheckerInSandbox(runParams model.RunParams) *model.CheckerResult {
+	workDir, cleanup, err := createTmpDir()
+	if err != nil {
+		return &model.CheckerResult{Error: fmt.Sprintf("创建沙箱工作目录失败: %v", err)}
+	}
+	defer cleanup()
+
+	paths := make([]string, 0, 4)
+	for _, p := range []string{runParams.SpecialExePath, runParams.InputFile, runParams.UserOutFile, runParams.AnswerFile} {
+		absPath, err := filepath.Abs(p)
+		if err != nil {
+			return &model.CheckerResult{Error: fmt.Sprintf("获取文件绝对路径失败: %v", err)}
+		}
+		paths = append(paths, absPath)
+	}
+	errorPath := filepath.Join(workDir, "checker.txt")
+
+	args := []string{
+		"--exe_path=" + paths[0],
+		"--args=" + paths[1],
+		"--args=" + paths[2],
+		"--args=" + paths[3],
+		"--error_path=" + errorPath, // testlib将评测信息写入stderr
+		"--seccomp_rules=general",
+		fmt.Sprintf("--max_cpu_time=%d", constants.CheckerTimeLimit*1000),
+		fmt.Sprintf("--max_real_time=%d", constants.CheckerTimeLimit*2*1000),
+		fmt.Sprintf("--max_memory=%d", constants.CheckerMemoryLimit*1024*1024),
+	}
+	result, err := csr.runSandbox(args)
+	if err != nil {
+		return &model.CheckerResult{Error: err.Error()}
+	}
+	checkerMsg, _ := os.ReadFile(errorPath)
+
+	zap.L().Debug("Sandbox checker result",
+		zap.Int("test_case", runParams.TestCaseIndex),
+		zap.Any("result", result),
+		zap.String("checker_message", string(checkerMsg)),
+	)
+
+	// 退出码非0时沙箱返回RE，此时以退出码为准
+	switch {
+	case result.Signal != 0:
+		return &model.CheckerResult{Error: fmt.Sprintf("checker收到信号 %d 终止", result.Signal)}
+	case resultMapping[result.Result] == model.StatusTLE:
+		return &model.CheckerResult{Error: "checker运行超时"}
+	case resultMapping[result.Result] == model.StatusMLE:
+		return &model.CheckerResult{Error: "checker内存超限"}
+	case resultMapping[result.Result] == model.StatusSE:
+		return &model.CheckerResult{Error: fmt.Sprintf("沙箱内部错误: %d", result.Error)}
+	}
+	return &model.CheckerResult{
+		ExitCode: result.ExitCode,
+		Message:  string(checkerMsg),
+	}
+}
+
+func init() {
+	Register(DefaultSDUSandboxConfig, func(sandboxPath string) Runner {
+		return &SDUSandboxRunner{SandboxPath: sandboxPath}
+	})
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"hitwh-judge/internal/model"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestSDUSandboxRunner_BasicExecution 测试基本的程序执行
@@ -634,3 +636,75 @@ int main() {
 		}
 	}
 }
+
+func TestFifoRelay(t *testing.T) {
+	t.Run("转发数据", func(t *testing.T) {
+		dir := t.TempDir()
+		inR, inW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outR, outW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer outR.Close()
+		relay, err := newFifoRelay(dir, inR, outW)
+		if err != nil {
+			t.Fatalf("newFifoRelay() error = %v", err)
+		}
+
+		// 以cat模拟沙箱：从标准输入命名管道读取并写入标准输出命名管道
+		cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("cat < %s > %s", relay.inPath, relay.outPath))
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := inW.WriteString("hello\n"); err != nil {
+			t.Fatal(err)
+		}
+		inW.Close()
+		got, err := io.ReadAll(outR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("cat error = %v", err)
+		}
+		relay.close()
+		if string(got) != "hello\n" {
+			t.Errorf("relayed output = %q, want %q", got, "hello\n")
+		}
+	})
+
+	t.Run("沙箱未打开命名管道", func(t *testing.T) {
+		dir := t.TempDir()
+		inR, inW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer inW.Close()
+		outR, outW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		relay, err := newFifoRelay(dir, inR, outW)
+		if err != nil {
+			t.Fatalf("newFifoRelay() error = %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			relay.close()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("fifoRelay.close() did not return")
+		}
+		// 转发结束后stdout被关闭，另一方读到EOF
+		if got, _ := io.ReadAll(outR); len(got) != 0 {
+			t.Errorf("unexpected output %q", got)
+		}
+	})
+}
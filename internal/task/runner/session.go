@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"fmt"
+	"hitwh-judge/internal/model"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Session 沙箱会话，同一提交的全部测试点复用一个沙箱
+// 打开会话时准备沙箱并放入程序，每次Run只重置输出文件、meta等单次运行的状态
+// Run不可并发调用，使用完毕后须调用Close释放沙箱
+type Session interface {
+	Run(runParams model.RunParams) *model.TestCaseResult
+	Close() error
+}
+
+// CheckerSession 可以在会话自身的沙箱中运行checker的会话
+// 会话占用沙箱期间若再为checker申请一个沙箱，并发评测之间会因持有并等待而互相阻塞
+type CheckerSession interface {
+	Session
+	RunChecker(runParams model.RunParams) *model.CheckerResult
+}
+
+// perRunSession 不复用沙箱的会话，每次Run仍由运行器完整地创建并清理沙箱
+// 用于每次运行本身没有准备开销的沙箱（如nsjail）
+type perRunSession struct {
+	runner  Runner
+	exePath string
+}
+
+// Run 运行一个测试点，忽略runParams.ExePath，始终运行打开会话时的程序
+func (s *perRunSession) Run(runParams model.RunParams) *model.TestCaseResult {
+	runParams.ExePath = s.exePath
+	return s.runner.RunInSandbox(runParams)
+}
+
+// Close 没有需要释放的资源
+func (s *perRunSession) Close() error {
+	return nil
+}
+
+// resetBox 删除沙箱工作目录中除keep以外的全部文件，清除上一个测试点留下的输出、meta与临时文件
+func resetBox(dir string, keep ...string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("读取沙箱目录失败: %w", err)
+	}
+	for _, entry := range entries {
+		if slices.Contains(keep, entry.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return fmt.Errorf("重置沙箱目录失败: %w", err)
+		}
+	}
+	return nil
+}
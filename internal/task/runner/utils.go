@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"hitwh-judge/internal/constants"
+	"hitwh-judge/internal/model"
+	file_util "hitwh-judge/internal/util/file"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
-	"sync"
 
 	"go.uber.org/zap"
 )
@@ -51,6 +54,131 @@ func createTmpDir() (string, func(), error) {
 	return tempDir, cleanup, nil
 }
 
+// boxDataDir 测试数据在沙箱内的只读目录
+const boxDataDir = "/data"
+
+// dataFile 只读放入沙箱的测试数据文件
+// 测试数据是缓存或题目包中文件的引用，运行器将其只读绑定挂载（或硬链接）到沙箱内，不复制内容
+type dataFile struct {
+	Source string `json:"source"` // 宿主机上的文件路径
+	Name   string `json:"name"`   // 沙箱内 /data 下的文件名
+}
+
+// BoxPath 返回文件在沙箱内的路径
+func (f dataFile) BoxPath() string {
+	return boxDataDir + "/" + f.Name
+}
+
+// checkerDataFiles 返回checker所需的测试数据：输入、程序输出与期望输出，顺序与testlib的参数一致
+func checkerDataFiles(runParams model.RunParams) []dataFile {
+	return []dataFile{
+		{Source: runParams.InputFile, Name: "input.txt"},
+		{Source: runParams.UserOutFile, Name: "user_output.txt"},
+		{Source: runParams.AnswerFile, Name: "answer.txt"},
+	}
+}
+
+// linkFile 将只读文件硬链接到dst，跨文件系统等无法硬链接时退回复制
+func linkFile(src string, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return file_util.CopyFile(src, dst)
+}
+
+// stageDataFiles 创建只包含指定测试数据的临时目录，供只能挂载目录的沙箱挂载为 /data
+// 文件以硬链接放入目录，跨文件系统时退回复制；返回的清理函数删除该目录
+func stageDataFiles(files []dataFile) (string, func(), error) {
+	dir, cleanup, err := createTmpDir()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, f := range files {
+		if err := linkFile(f.Source, filepath.Join(dir, f.Name)); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("放入测试数据%s失败: %w", f.Name, err)
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// outputLimit 返回运行参数中的输出大小限制（字节），未设置时使用默认限制
+func outputLimit(runParams model.RunParams) int64 {
+	if runParams.OutputLimit > 0 {
+		return runParams.OutputLimit
+	}
+	return constants.MaxOutputSize
+}
+
+// readOutput 读取沙箱写入的输出文件，最多读取limit字节
+// 文件超过limit时返回exceeded为true；文件不存在时视为空输出
+func readOutput(path string, limit int64) (output string, exceeded bool, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("读取输出文件失败: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return "", false, fmt.Errorf("读取输出文件失败: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return normalizeString(string(data[:limit])), true, nil
+	}
+	return normalizeString(string(data)), false, nil
+}
+
+// collectOutput 收集沙箱写入path的程序输出，返回的输出最多为输出大小限制
+// 设置了runParams.OutputFile时将输出保存到该文件，只返回开头部分作为预览，避免大输出占用内存
+func collectOutput(path string, runParams model.RunParams) (output string, exceeded bool, err error) {
+	limit := outputLimit(runParams)
+	if runParams.OutputFile == "" {
+		return readOutput(path, limit)
+	}
+	if exceeded, err = saveOutput(path, runParams.OutputFile, limit); err != nil {
+		return "", false, err
+	}
+	output, _, err = readOutput(runParams.OutputFile, constants.OutputPreviewSize)
+	return output, exceeded, err
+}
+
+// saveOutput 将输出文件src的前limit字节复制到dst，src超过limit时返回exceeded为true
+// src不存在时视为空输出
+func saveOutput(src string, dst string, limit int64) (exceeded bool, err error) {
+	out, err := os.Create(dst)
+	if err != nil {
+		return false, fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	defer out.Close()
+
+	in, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取输出文件失败: %w", err)
+	}
+	defer in.Close()
+
+	n, err := io.Copy(out, io.LimitReader(in, limit+1))
+	if err != nil {
+		return false, fmt.Errorf("保存输出文件失败: %w", err)
+	}
+	if n > limit {
+		return true, out.Truncate(limit)
+	}
+	return false, nil
+}
+
+// outputLimitMessage 输出超限时的错误信息
+func outputLimitMessage(limit int64) string {
+	return fmt.Sprintf("输出超限: 超过 %d bytes", limit)
+}
+
 // truncateOutput 截断输出（防止输出过大）
 func truncateOutput(output string, maxSize int) string {
 	if len(output) <= maxSize {
@@ -105,35 +233,11 @@ func sanitizeError(errMsg string) string {
 	return errMsg
 }
 
-// 全局box ID池，用于管理0-999范围内的ID分配
-var (
-	boxIDPoolMu sync.Mutex
-	boxIDPool   = make([]bool, constants.BoxIDPoolSize) // 索引0-999，false表示未占用，true表示已占用
-)
-
-// allocateBoxID 动态分配一个0-999范围内的box ID
-func allocateBoxID() int {
-	boxIDPoolMu.Lock()
-	defer boxIDPoolMu.Unlock()
-
-	// 查找第一个未占用的ID
-	for i := 0; i < constants.BoxIDPoolSize; i++ {
-		if !boxIDPool[i] {
-			boxIDPool[i] = true // 标记为已占用
-			return i
-		}
-	}
-
-	// 如果所有ID都被占用，返回-1表示分配失败
-	// 实际上这种情况不应该发生，因为我们会在defer中释放ID
-	return -1
-}
-
-// releaseBoxID 释放指定的box ID
-func releaseBoxID(id int) {
-	if id >= 0 && id < constants.BoxIDPoolSize {
-		boxIDPoolMu.Lock()
-		defer boxIDPoolMu.Unlock()
-		boxIDPool[id] = false // 标记为未占用
+// systemError 构造沙箱系统错误的测试点结果
+func systemError(testCaseIndex int, format string, args ...interface{}) *model.TestCaseResult {
+	return &model.TestCaseResult{
+		TestCaseIndex: testCaseIndex,
+		Status:        model.StatusSE,
+		Error:         fmt.Sprintf(format, args...),
 	}
 }
@@ -0,0 +1,119 @@
+package testdata
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrChecksumMismatch 下载内容的MD5与请求的MD5不一致（通常是下载被截断）
+var ErrChecksumMismatch = errors.New("测试数据MD5校验失败")
+
+// 下载重试默认配置
+const (
+	DefaultMaxAttempts    = 3                      // 最大下载次数
+	DefaultInitialBackoff = 500 * time.Millisecond // 首次重试间隔，之后每次翻倍
+	DefaultMaxBackoff     = 5 * time.Second        // 最大重试间隔
+)
+
+// Downloader 按MD5下载测试数据：边下载边计算MD5，校验通过后原子地重命名到目标路径，
+// 临时错误与校验失败按指数退避重试，对象不存在时不重试
+type Downloader struct {
+	Store          TestDataStore
+	Layout         KeyLayout
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // 单次下载（含读取内容）的超时时间
+}
+
+// NewDownloader 使用默认重试配置创建下载器
+func NewDownloader(store TestDataStore, layout KeyLayout) *Downloader {
+	return &Downloader{
+		Store:          store,
+		Layout:         layout,
+		MaxAttempts:    DefaultMaxAttempts,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		Timeout:        DefaultTimeout,
+	}
+}
+
+// Download 下载MD5为md5sum的测试数据到dst，返回文件大小
+// dst所在目录中会创建临时文件，失败时dst保持不变
+func (d *Downloader) Download(ctx context.Context, bucket, md5sum, dst string) (int64, error) {
+	key := d.Layout.Key(md5sum)
+	backoff := d.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var size int64
+		size, err = d.downloadOnce(ctx, bucket, key, md5sum, dst)
+		if err == nil {
+			return size, nil
+		}
+		if errors.Is(err, ErrObjectNotFound) || ctx.Err() != nil || attempt >= d.MaxAttempts {
+			break
+		}
+		zap.L().Warn("下载测试数据失败，稍后重试",
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		backoff *= 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+	return 0, err
+}
+
+// downloadOnce 下载一次：写入临时文件并计算MD5，校验通过后重命名为dst
+func (d *Downloader) downloadOnce(ctx context.Context, bucket, key, md5sum, dst string) (int64, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	reader, err := d.Store.Get(ctx, bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".download-*")
+	if err != nil {
+		return 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后临时文件已不存在，删除失败可忽略
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("下载测试数据%s/%s失败: %w", bucket, key, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != md5sum {
+		return 0, fmt.Errorf("%w: %s/%s 期望%s, 实际%s（%d字节）", ErrChecksumMismatch, bucket, key, md5sum, got, size)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return 0, fmt.Errorf("保存测试数据失败: %w", err)
+	}
+	return size, nil
+}
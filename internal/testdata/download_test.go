@@ -0,0 +1,121 @@
+package testdata
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyStore 前failures次读取返回截断的内容
+type flakyStore struct {
+	TestDataStore
+	content  string
+	failures int
+	calls    int
+	keys     []string
+}
+
+func (s *flakyStore) Get(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	s.calls++
+	s.keys = append(s.keys, key)
+	if bucket != "bucket" {
+		return nil, ErrObjectNotFound
+	}
+	if s.calls <= s.failures {
+		return io.NopCloser(strings.NewReader(s.content[:len(s.content)/2])), nil
+	}
+	return io.NopCloser(strings.NewReader(s.content)), nil
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestKeyLayout(t *testing.T) {
+	md5sum := "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		mode    string
+		prefix  string
+		want    string
+		wantErr bool
+	}{
+		{mode: "", want: md5sum},
+		{mode: LayoutFlat, prefix: "cases/", want: "cases/" + md5sum},
+		{mode: LayoutSharded, want: "01/23456789abcdef0123456789abcdef"},
+		{mode: LayoutSharded, prefix: "cases/", want: "cases/01/23456789abcdef0123456789abcdef"},
+		{mode: "nested", wantErr: true},
+		{mode: LayoutFlat, prefix: "/cases/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		layout, err := ParseKeyLayout(tt.mode, tt.prefix)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseKeyLayout(%q, %q) error = %v, wantErr %v", tt.mode, tt.prefix, err, tt.wantErr)
+		}
+		if err == nil && layout.Key(md5sum) != tt.want {
+			t.Errorf("ParseKeyLayout(%q, %q).Key() = %q, want %q", tt.mode, tt.prefix, layout.Key(md5sum), tt.want)
+		}
+	}
+}
+
+func TestDownloader_Download(t *testing.T) {
+	content := "1 2 3\n4 5 6\n"
+	md5sum := md5Hex(content)
+
+	tests := []struct {
+		name      string
+		bucket    string
+		failures  int
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "一次成功", bucket: "bucket", wantCalls: 1},
+		{name: "截断后重试成功", bucket: "bucket", failures: 2, wantCalls: 3},
+		{name: "多次校验失败", bucket: "bucket", failures: 3, wantErr: ErrChecksumMismatch, wantCalls: 3},
+		{name: "对象不存在不重试", bucket: "missing", wantErr: ErrObjectNotFound, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dst := filepath.Join(dir, "cached")
+			store := &flakyStore{content: content, failures: tt.failures}
+			layout, _ := ParseKeyLayout(LayoutSharded, "")
+			d := NewDownloader(store, layout)
+			d.InitialBackoff = time.Millisecond
+
+			size, err := d.Download(context.Background(), tt.bucket, md5sum, dst)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Download() error = %v, want %v", err, tt.wantErr)
+			}
+			if store.calls != tt.wantCalls {
+				t.Errorf("Download() calls = %d, want %d", store.calls, tt.wantCalls)
+			}
+			if store.keys[0] != md5sum[:2]+"/"+md5sum[2:] {
+				t.Errorf("Download() key = %q, want sharded key", store.keys[0])
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if tt.wantErr != nil {
+				if len(entries) != 0 {
+					t.Errorf("Download() left files after failure: %v", entries)
+				}
+				return
+			}
+			if data, err := os.ReadFile(dst); err != nil || string(data) != content || size != int64(len(content)) {
+				t.Errorf("Download() = %d, file %q, %v", size, data, err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("Download() left temporary files: %v", entries)
+			}
+		})
+	}
+}
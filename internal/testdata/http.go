@@ -0,0 +1,101 @@
+package testdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPStore HTTP文件服务器存储
+//
+// 对象地址为 <baseURL>/<bucket>/<key>，读取使用GET、获取信息使用HEAD；
+// 列出对象时请求 <baseURL>/<bucket>/?prefix=<prefix>，服务端返回 ObjectInfo 的JSON数组。
+type HTTPStore struct {
+	baseURL string
+	token   string // 非空时以 Authorization: Bearer <token> 发送
+	client  *http.Client
+}
+
+// NewHTTPStore 创建HTTP测试数据存储，timeout为单次请求（含读取内容）的超时时间
+func NewHTTPStore(baseURL, token string, timeout time.Duration) *HTTPStore {
+	return &HTTPStore{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// objectURL 构造对象地址，各级名称分别转义
+func (s *HTTPStore) objectURL(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return s.baseURL + "/" + url.PathEscape(bucket) + "/" + strings.Join(segments, "/")
+}
+
+// do 发送请求，非2xx响应转换为错误（404为 ErrObjectNotFound）
+func (s *HTTPStore) do(ctx context.Context, method, rawURL, name string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建测试数据请求失败: %w", err)
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求测试数据%s失败: %w", name, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	return nil, fmt.Errorf("请求测试数据%s失败: HTTP %d", name, resp.StatusCode)
+}
+
+// Get 读取对象内容
+func (s *HTTPStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(bucket, key), bucket+"/"+key)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat 获取对象信息
+func (s *HTTPStore) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, s.objectURL(bucket, key), bucket+"/"+key)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	info := &ObjectInfo{Key: key, Size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info, nil
+}
+
+// List 列出存储桶中以prefix开头的对象
+func (s *HTTPStore) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	rawURL := s.baseURL + "/" + url.PathEscape(bucket) + "/?prefix=" + url.QueryEscape(prefix)
+	resp, err := s.do(ctx, http.MethodGet, rawURL, bucket+"/"+prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var objects []ObjectInfo
+	if err := json.NewDecoder(resp.Body).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("解析测试数据列表失败: %w", err)
+	}
+	return objects, nil
+}
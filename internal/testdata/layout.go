@@ -0,0 +1,41 @@
+package testdata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 对象名称布局
+const (
+	LayoutFlat    = "flat"    // 直接使用MD5作为对象名称（默认）
+	LayoutSharded = "sharded" // 以MD5前两位作为目录，如 ab/cdef...
+)
+
+// KeyLayout 测试数据对象名称布局，由MD5生成对象名称
+type KeyLayout struct {
+	Mode   string // flat/sharded
+	Prefix string // 对象名称前缀，如 "testcases/"
+}
+
+// ParseKeyLayout 解析对象名称布局配置，mode为空时使用flat
+func ParseKeyLayout(mode, prefix string) (KeyLayout, error) {
+	switch mode {
+	case "":
+		mode = LayoutFlat
+	case LayoutFlat, LayoutSharded:
+	default:
+		return KeyLayout{}, fmt.Errorf("对象名称布局无效: %s (应为%s/%s)", mode, LayoutFlat, LayoutSharded)
+	}
+	if strings.HasPrefix(prefix, "/") {
+		return KeyLayout{}, fmt.Errorf("对象名称前缀不能以/开头: %s", prefix)
+	}
+	return KeyLayout{Mode: mode, Prefix: prefix}, nil
+}
+
+// Key 返回MD5对应的对象名称
+func (l KeyLayout) Key(md5 string) string {
+	if l.Mode == LayoutSharded && len(md5) > 2 {
+		return l.Prefix + md5[:2] + "/" + md5[2:]
+	}
+	return l.Prefix + md5
+}
@@ -0,0 +1,103 @@
+package testdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore 本地目录存储，对象路径为 <root>/<bucket>/<key>
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore 创建本地目录测试数据存储
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+// path 返回对象的本地路径，拒绝越出存储桶目录的名称
+func (s *LocalStore) path(bucket, key string) (string, error) {
+	if !filepath.IsLocal(bucket) || strings.ContainsRune(bucket, filepath.Separator) {
+		return "", fmt.Errorf("存储桶名称无效: %q", bucket)
+	}
+	if key == "" || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("对象名称无效: %q", key)
+	}
+	return filepath.Join(s.root, bucket, filepath.FromSlash(key)), nil
+}
+
+// Get 读取对象内容
+func (s *LocalStore) Get(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	path, err := s.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取测试数据失败: %w", err)
+	}
+	return f, nil
+}
+
+// Stat 获取对象信息
+func (s *LocalStore) Stat(_ context.Context, bucket, key string) (*ObjectInfo, error) {
+	path, err := s.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取测试数据信息失败: %w", err)
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List 列出存储桶中以prefix开头的对象，对象名称使用/分隔
+func (s *LocalStore) List(_ context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	bucketDir, err := s.path(bucket, ".")
+	if err != nil {
+		return nil, err
+	}
+	var objects []ObjectInfo
+	err = filepath.WalkDir(bucketDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(bucketDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("列出测试数据失败: %w", err)
+	}
+	return objects, nil
+}
@@ -0,0 +1,63 @@
+package testdata
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// MinIOStore MinIO对象存储
+type MinIOStore struct {
+	client *minio.Client
+}
+
+// NewMinIOStore 创建MinIO测试数据存储
+func NewMinIOStore(client *minio.Client) *MinIOStore {
+	return &MinIOStore{client: client}
+}
+
+// Get 读取对象内容
+func (s *MinIOStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertMinIOError(bucket, key, err)
+	}
+	// GetObject不会立即发起请求，通过Stat尽早发现对象不存在等错误
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, convertMinIOError(bucket, key, err)
+	}
+	return object, nil
+}
+
+// Stat 获取对象信息
+func (s *MinIOStore) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, convertMinIOError(bucket, key, err)
+	}
+	return &ObjectInfo{Key: info.Key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+// List 列出存储桶中以prefix开头的对象
+func (s *MinIOStore) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for info := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("列出MinIO对象失败: %w", info.Err)
+		}
+		objects = append(objects, ObjectInfo{Key: info.Key, Size: info.Size, ModTime: info.LastModified})
+	}
+	return objects, nil
+}
+
+// convertMinIOError 将对象或存储桶不存在的错误转换为 ErrObjectNotFound
+func convertMinIOError(bucket, key string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	return fmt.Errorf("读取MinIO对象%s/%s失败: %w", bucket, key, err)
+}
@@ -0,0 +1,75 @@
+package testdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/spf13/viper"
+)
+
+// ErrObjectNotFound 测试数据不存在
+var ErrObjectNotFound = errors.New("测试数据不存在")
+
+// 测试数据存储类型
+const (
+	TypeMinIO = "minio" // MinIO对象存储（默认）
+	TypeLocal = "local" // 本地目录，用于离线赛场与测试
+	TypeHTTP  = "http"  // HTTP文件服务器
+)
+
+// 默认配置
+const (
+	DefaultLocalDir = "./testdata"     // 本地目录存储的默认根目录
+	DefaultTimeout  = 30 * time.Second // HTTP存储单次请求的默认超时时间
+)
+
+// ObjectInfo 测试数据对象信息
+type ObjectInfo struct {
+	Key     string    `json:"key"`      // 对象名称
+	Size    int64     `json:"size"`     // 对象大小（字节）
+	ModTime time.Time `json:"mod_time"` // 最后修改时间
+}
+
+// TestDataStore 测试数据存储，按存储桶和对象名称访问
+type TestDataStore interface {
+	// Get 读取对象内容，调用方负责关闭；对象不存在时返回 ErrObjectNotFound
+	Get(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// Stat 获取对象信息，对象不存在时返回 ErrObjectNotFound
+	Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+	// List 列出存储桶中以prefix开头的对象
+	List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
+}
+
+// New 根据配置创建测试数据存储
+// 配置项：testdata.type（默认minio）、testdata.local_dir、testdata.http_url、testdata.http_token、testdata.timeout（秒）
+func New(cfg *viper.Viper, client *minio.Client) (TestDataStore, error) {
+	switch storeType := cfg.GetString("testdata.type"); storeType {
+	case "", TypeMinIO:
+		if client == nil {
+			return nil, fmt.Errorf("MinIO测试数据存储需要先初始化MinIO连接")
+		}
+		return NewMinIOStore(client), nil
+	case TypeLocal:
+		dir := cfg.GetString("testdata.local_dir")
+		if dir == "" {
+			dir = DefaultLocalDir
+		}
+		return NewLocalStore(dir), nil
+	case TypeHTTP:
+		baseURL := cfg.GetString("testdata.http_url")
+		if baseURL == "" {
+			return nil, fmt.Errorf("HTTP测试数据存储需要配置testdata.http_url")
+		}
+		timeout := time.Duration(cfg.GetInt("testdata.timeout")) * time.Second
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		return NewHTTPStore(baseURL, cfg.GetString("testdata.http_token"), timeout), nil
+	default:
+		return nil, fmt.Errorf("不支持的测试数据存储类型: %s", storeType)
+	}
+}
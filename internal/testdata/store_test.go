@@ -0,0 +1,159 @@
+package testdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// readAll 读取对象全部内容
+func readAll(t *testing.T, s TestDataStore, bucket, key string) (string, error) {
+	t.Helper()
+	r, err := s.Get(context.Background(), bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	return string(data), err
+}
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"problems/abc":      "1 2",
+		"problems/sub/def":  "3",
+		"other/abc":         "other",
+		"problems-copy/abc": "copy",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewLocalStore(root)
+	ctx := context.Background()
+
+	if got, err := readAll(t, s, "problems", "sub/def"); err != nil || got != "3" {
+		t.Errorf("Get() = %q, %v, want \"3\"", got, err)
+	}
+	if _, err := s.Get(ctx, "problems", "missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrObjectNotFound", err)
+	}
+	for _, tt := range []struct{ bucket, key string }{{"..", "abc"}, {"problems", "../other/abc"}, {"problems/sub", "def"}, {"problems", ""}} {
+		if _, err := s.Get(ctx, tt.bucket, tt.key); err == nil || errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Get(%q, %q) error = %v, want invalid name", tt.bucket, tt.key, err)
+		}
+	}
+
+	info, err := s.Stat(ctx, "problems", "abc")
+	if err != nil || info.Size != 3 || info.Key != "abc" {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+	if _, err := s.Stat(ctx, "problems", "sub"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat(dir) error = %v, want ErrObjectNotFound", err)
+	}
+
+	objects, err := s.List(ctx, "problems", "")
+	if err != nil || len(objects) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 objects", objects, err)
+	}
+	if objects, err := s.List(ctx, "problems", "sub/"); err != nil || len(objects) != 1 || objects[0].Key != "sub/def" {
+		t.Errorf("List(sub/) = %+v, %v", objects, err)
+	}
+	if objects, err := s.List(ctx, "missing", ""); err != nil || len(objects) != 0 {
+		t.Errorf("List(missing bucket) = %+v, %v, want empty", objects, err)
+	}
+}
+
+func TestHTTPStore(t *testing.T) {
+	modTime := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/problems/abc":
+			w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+			w.Write([]byte("1 2"))
+		case "/problems/":
+			json.NewEncoder(w).Encode([]ObjectInfo{{Key: r.URL.Query().Get("prefix") + "x", Size: 1}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	s := NewHTTPStore(server.URL+"/", "secret", time.Second)
+	ctx := context.Background()
+
+	if got, err := readAll(t, s, "problems", "abc"); err != nil || got != "1 2" {
+		t.Errorf("Get() = %q, %v, want \"1 2\"", got, err)
+	}
+	if _, err := s.Get(ctx, "problems", "missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrObjectNotFound", err)
+	}
+	info, err := s.Stat(ctx, "problems", "abc")
+	if err != nil || info.Size != 3 || !info.ModTime.Equal(modTime) {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+	if objects, err := s.List(ctx, "problems", "ab"); err != nil || len(objects) != 1 || objects[0].Key != "abx" {
+		t.Errorf("List() = %+v, %v", objects, err)
+	}
+
+	unauthorized := NewHTTPStore(server.URL, "", time.Second)
+	if _, err := unauthorized.Get(ctx, "problems", "abc"); err == nil || errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get() without token error = %v, want HTTP 401", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		wantType string
+		wantErr  string
+	}{
+		{name: "默认MinIO未初始化", settings: map[string]any{}, wantErr: "MinIO"},
+		{name: "本地目录", settings: map[string]any{"testdata.type": TypeLocal}, wantType: "*testdata.LocalStore"},
+		{name: "HTTP", settings: map[string]any{"testdata.type": TypeHTTP, "testdata.http_url": "http://127.0.0.1"}, wantType: "*testdata.HTTPStore"},
+		{name: "HTTP缺少地址", settings: map[string]any{"testdata.type": TypeHTTP}, wantErr: "http_url"},
+		{name: "未知类型", settings: map[string]any{"testdata.type": "ftp"}, wantErr: "不支持"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := viper.New()
+			for k, v := range tt.settings {
+				cfg.Set(k, v)
+			}
+			s, err := New(cfg, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("New() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := fmt.Sprintf("%T", s); got != tt.wantType {
+				t.Errorf("New() type = %s, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxDeadLetters 死信记录保留的最大条数
+const maxDeadLetters = 1000
+
+// DeadLetter 多次投递失败的回调记录
+type DeadLetter struct {
+	TaskID     int64           `json:"task_id"`     // 任务ID
+	URL        string          `json:"url"`         // 回调地址
+	Payload    json.RawMessage `json:"payload"`     // 回调内容
+	Attempts   int             `json:"attempts"`    // 已投递次数
+	LastError  string          `json:"last_error"`  // 最后一次失败原因
+	CreateTime time.Time       `json:"create_time"` // 记录时间
+}
+
+// DeadLetterStore 死信记录存储
+type DeadLetterStore interface {
+	// Save 保存死信记录
+	Save(ctx context.Context, dl *DeadLetter) error
+	// List 按时间倒序列出死信记录
+	List(ctx context.Context) ([]DeadLetter, error)
+}
+
+// MemoryDeadLetterStore 进程内死信记录存储，超过上限时丢弃最早的记录
+type MemoryDeadLetterStore struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// NewMemoryDeadLetterStore 创建进程内死信记录存储
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{}
+}
+
+// Save 保存死信记录
+func (s *MemoryDeadLetterStore) Save(_ context.Context, dl *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.letters = append(s.letters, *dl)
+	if len(s.letters) > maxDeadLetters {
+		s.letters = s.letters[len(s.letters)-maxDeadLetters:]
+	}
+	return nil
+}
+
+// List 按时间倒序列出死信记录
+func (s *MemoryDeadLetterStore) List(_ context.Context) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := make([]DeadLetter, len(s.letters))
+	for i, dl := range s.letters {
+		letters[len(s.letters)-1-i] = dl
+	}
+	return letters, nil
+}
+
+// RedisDeadLetterStore 基于Redis列表的死信记录存储
+type RedisDeadLetterStore struct {
+	rdb *redis.Client
+	key string
+}
+
+// NewRedisDeadLetterStore 创建Redis死信记录存储
+func NewRedisDeadLetterStore(rdb *redis.Client, keyPrefix string) *RedisDeadLetterStore {
+	return &RedisDeadLetterStore{rdb: rdb, key: keyPrefix + ":webhook:dead_letters"}
+}
+
+// Save 保存死信记录
+func (s *RedisDeadLetterStore) Save(ctx context.Context, dl *DeadLetter) error {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("序列化死信记录失败: %w", err)
+	}
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, s.key, data)
+		pipe.LTrim(ctx, s.key, 0, maxDeadLetters-1)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("保存死信记录失败: %w", err)
+	}
+	return nil
+}
+
+// List 按时间倒序列出死信记录
+func (s *RedisDeadLetterStore) List(ctx context.Context) ([]DeadLetter, error) {
+	items, err := s.rdb.LRange(ctx, s.key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取死信记录失败: %w", err)
+	}
+	letters := make([]DeadLetter, 0, len(items))
+	for _, item := range items {
+		var dl DeadLetter
+		if err := json.Unmarshal([]byte(item), &dl); err != nil {
+			continue
+		}
+		letters = append(letters, dl)
+	}
+	return letters, nil
+}
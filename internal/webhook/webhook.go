@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 回调请求头
+const (
+	SignatureHeader = "X-Judge-Signature" // 时间戳与请求体的HMAC-SHA256签名，格式为 "sha256=<十六进制>"
+	TimestampHeader = "X-Judge-Timestamp" // 发送时间（Unix秒），包含在签名中
+	TaskIDHeader    = "X-Judge-Task-Id"   // 任务ID
+	AttemptHeader   = "X-Judge-Attempt"   // 第几次投递（从1开始）
+)
+
+// MaxTimestampSkew 接收方接受的发送时间与本地时间的最大偏差，超出视为重放
+const MaxTimestampSkew = 5 * time.Minute
+
+// 回调地址校验错误
+var (
+	ErrSecretNotConfigured = errors.New("评测服务未配置webhook.secret，不支持callback_url")
+	ErrHostNotAllowed      = errors.New("回调地址的主机不在webhook.allowed_hosts中")
+)
+
+// 默认配置
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultTimeout        = 10 * time.Second
+)
+
+// Config 回调配置
+type Config struct {
+	Secret         string        // 签名密钥（与接收方共享）
+	MaxAttempts    int           // 最大投递次数
+	InitialBackoff time.Duration // 首次重试间隔，之后每次翻倍
+	MaxBackoff     time.Duration // 最大重试间隔
+	Timeout        time.Duration // 单次请求超时
+	AllowedHosts   []string      // 允许回调的主机名（不含端口，不区分大小写）
+}
+
+// normalize 填充未设置的配置项
+func (c Config) normalize() Config {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	return c
+}
+
+// Sign 计算签名，签名内容为 "<时间戳>.<请求体>"
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验回调签名（供接收方参考）
+// timestamp为 X-Judge-Timestamp 请求头，与本地时间相差超过 MaxTimestampSkew 时视为重放的旧请求，校验失败；
+// 同一任务可能因重试收到多次回调，接收方应按任务ID幂等处理
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > MaxTimestampSkew || skew < -MaxTimestampSkew {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// ValidateURL 校验回调地址：须已配置签名密钥，仅支持http/https，且主机在允许列表中
+func (n *Notifier) ValidateURL(callbackURL string) error {
+	if n.cfg.Secret == "" {
+		return ErrSecretNotConfigured
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("回调地址无效: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("回调地址无效: %s (仅支持http/https)", callbackURL)
+	}
+	for _, host := range n.cfg.AllowedHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Hostname())
+}
+
+// parseHosts 解析逗号分隔的主机列表
+func parseHosts(s string) []string {
+	var hosts []string
+	for _, host := range strings.Split(s, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// New 根据配置创建回调通知器
+// 配置项：webhook.secret、webhook.max_attempts、webhook.initial_backoff（秒）、webhook.max_backoff（秒）、
+// webhook.timeout（秒）、webhook.allowed_hosts（逗号分隔）、webhook.dead_letter_store（memory/redis）、webhook.key_prefix
+func New(cfg *viper.Viper, rdb *redis.Client) (*Notifier, error) {
+	var deadLetters DeadLetterStore
+	switch storeType := cfg.GetString("webhook.dead_letter_store"); storeType {
+	case "", "memory":
+		deadLetters = NewMemoryDeadLetterStore()
+	case "redis":
+		if rdb == nil {
+			return nil, fmt.Errorf("Redis死信存储需要先初始化Redis连接")
+		}
+		keyPrefix := cfg.GetString("webhook.key_prefix")
+		if keyPrefix == "" {
+			keyPrefix = "judge"
+		}
+		deadLetters = NewRedisDeadLetterStore(rdb, keyPrefix)
+	default:
+		return nil, fmt.Errorf("不支持的死信存储类型: %s", storeType)
+	}
+
+	return NewNotifier(Config{
+		Secret:         cfg.GetString("webhook.secret"),
+		MaxAttempts:    cfg.GetInt("webhook.max_attempts"),
+		InitialBackoff: time.Duration(cfg.GetInt("webhook.initial_backoff")) * time.Second,
+		MaxBackoff:     time.Duration(cfg.GetInt("webhook.max_backoff")) * time.Second,
+		Timeout:        time.Duration(cfg.GetInt("webhook.timeout")) * time.Second,
+		AllowedHosts:   parseHosts(cfg.GetString("webhook.allowed_hosts")),
+	}, deadLetters), nil
+}
+
+// Notifier 评测结果回调
+type Notifier struct {
+	cfg         Config
+	client      *http.Client
+	deadLetters DeadLetterStore
+}
+
+// NewNotifier 创建回调通知器
+func NewNotifier(cfg Config, deadLetters DeadLetterStore) *Notifier {
+	cfg = cfg.normalize()
+	return &Notifier{
+		cfg:         cfg,
+		client:      &http.Client{Timeout: cfg.Timeout},
+		deadLetters: deadLetters,
+	}
+}
+
+// Deliver 将payload以JSON格式POST到回调地址
+// 失败时按指数退避重试，全部失败后写入死信记录并返回最后一次的错误
+func (n *Notifier) Deliver(ctx context.Context, callbackURL string, taskID int64, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化回调内容失败: %w", err)
+	}
+
+	backoff := n.cfg.InitialBackoff
+	var lastErr error
+	attempt := 1
+	for ; ; attempt++ {
+		if lastErr = n.post(ctx, callbackURL, taskID, attempt, body); lastErr == nil {
+			return nil
+		}
+		zap.L().Warn("评测结果回调失败",
+			zap.Int64("task_id", taskID),
+			zap.String("url", callbackURL),
+			zap.Int("attempt", attempt),
+			zap.Error(lastErr),
+		)
+		if attempt >= n.cfg.MaxAttempts || !sleepContext(ctx, backoff) {
+			break
+		}
+		backoff = min(backoff*2, n.cfg.MaxBackoff)
+	}
+
+	deadLetter := &DeadLetter{
+		TaskID:     taskID,
+		URL:        callbackURL,
+		Payload:    body,
+		Attempts:   attempt,
+		LastError:  lastErr.Error(),
+		CreateTime: time.Now(),
+	}
+	if err := n.deadLetters.Save(context.WithoutCancel(ctx), deadLetter); err != nil {
+		zap.L().Error("保存回调死信记录失败", zap.Int64("task_id", taskID), zap.Error(err))
+	}
+	return fmt.Errorf("评测结果回调失败（共投递%d次）: %w", attempt, lastErr)
+}
+
+// sleepContext 等待指定时间，ctx提前结束时返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// post 发送一次回调请求，2xx视为成功
+func (n *Notifier) post(ctx context.Context, callbackURL string, taskID int64, attempt int, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TaskIDHeader, strconv.FormatInt(taskID, 10))
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+	if n.cfg.Secret != "" {
+		// 每次投递使用新的时间戳，重试的请求同样处于接收方的有效期内
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(n.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("回调地址返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNotifier_Deliver(t *testing.T) {
+	const secret = "s3cret"
+
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("signature %q does not match body %s", r.Header.Get(SignatureHeader), body)
+		}
+		if r.Header.Get(TaskIDHeader) != "42" {
+			t.Errorf("%s = %q, want 42", TaskIDHeader, r.Header.Get(TaskIDHeader))
+		}
+		received.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := NewNotifier(Config{Secret: secret}, NewMemoryDeadLetterStore())
+	if err := n.Deliver(context.Background(), server.URL, 42, map[string]string{"status": "AC"}); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if received.Load() != 1 {
+		t.Errorf("received %d requests, want 1", received.Load())
+	}
+}
+
+func TestNotifier_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	deadLetters := NewMemoryDeadLetterStore()
+	n := NewNotifier(Config{MaxAttempts: 5, InitialBackoff: time.Millisecond}, deadLetters)
+	if err := n.Deliver(context.Background(), server.URL, 1, "result"); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("attempts = %d, want 3", attempts.Load())
+	}
+	if letters, _ := deadLetters.List(context.Background()); len(letters) != 0 {
+		t.Errorf("dead letters = %d, want 0", len(letters))
+	}
+}
+
+func TestNotifier_DeadLetter(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	deadLetters := NewMemoryDeadLetterStore()
+	n := NewNotifier(Config{MaxAttempts: 3, InitialBackoff: time.Millisecond}, deadLetters)
+	if err := n.Deliver(context.Background(), server.URL, 7, "result"); err == nil {
+		t.Fatal("Deliver() error = nil, want error")
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("attempts = %d, want 3", attempts.Load())
+	}
+
+	letters, _ := deadLetters.List(context.Background())
+	if len(letters) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(letters))
+	}
+	if letters[0].TaskID != 7 || letters[0].Attempts != 3 || string(letters[0].Payload) != `"result"` {
+		t.Errorf("dead letter = %+v", letters[0])
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "s3cret"
+	body := []byte(`{"status":"AC"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-MaxTimestampSkew-time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(MaxTimestampSkew+time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "有效签名", timestamp: now, body: body, signature: Sign(secret, now, body), want: true},
+		{name: "请求体被篡改", timestamp: now, body: []byte(`{"status":"WA"}`), signature: Sign(secret, now, body)},
+		{name: "时间戳被替换", timestamp: now, body: body, signature: Sign(secret, stale, body)},
+		{name: "重放的旧请求", timestamp: stale, body: body, signature: Sign(secret, stale, body)},
+		{name: "时间戳超前", timestamp: future, body: body, signature: Sign(secret, future, body)},
+		{name: "时间戳无效", timestamp: "abc", body: body, signature: Sign(secret, "abc", body)},
+		{name: "密钥错误", timestamp: now, body: body, signature: Sign("other", now, body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifier_ValidateURL(t *testing.T) {
+	n := NewNotifier(Config{Secret: "s3cret", AllowedHosts: parseHosts("oj.example.com, 127.0.0.1")}, NewMemoryDeadLetterStore())
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{url: "https://oj.example.com/judge/callback"},
+		{url: "http://OJ.example.com:8080/cb"},
+		{url: "http://127.0.0.1:8080/cb"},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: ErrHostNotAllowed},
+		{url: "http://oj.example.com.evil.com/cb", wantErr: ErrHostNotAllowed},
+		{url: "ftp://oj.example.com/cb", wantErr: errAny},
+		{url: "/relative/path", wantErr: errAny},
+		{url: "://bad", wantErr: errAny},
+	}
+	for _, tt := range tests {
+		err := n.ValidateURL(tt.url)
+		if (err != nil) != (tt.wantErr != nil) || (tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+			t.Errorf("ValidateURL(%q) error = %v, want %v", tt.url, err, tt.wantErr)
+		}
+	}
+
+	unsigned := NewNotifier(Config{AllowedHosts: []string{"oj.example.com"}}, NewMemoryDeadLetterStore())
+	if err := unsigned.ValidateURL("https://oj.example.com/cb"); !errors.Is(err, ErrSecretNotConfigured) {
+		t.Errorf("ValidateURL() without secret error = %v, want ErrSecretNotConfigured", err)
+	}
+}
+
+// errAny 表示期望任意错误
+var errAny = errors.New("any error")